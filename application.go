@@ -1,10 +1,28 @@
 package application
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"./config"
+	"./controller"
+	"./event"
+	"./fault"
 	"./filesystem"
+	"./health"
+	"./journal"
+	"./kv"
+	"./log"
+	"./metrics"
+	"./model"
+	"./rpc"
+	"./scheduler"
+	"./server"
 )
 
 type Application struct {
@@ -14,6 +32,53 @@ type Application struct {
 	IO      *IO
 	Data    filesystem.Directory
 	Config  filesystem.Directory
+	State   filesystem.Directory
+	// NOTE: Runtime holds sockets and other files that must not outlive the
+	// session, it is never created under the data directories.
+	Runtime filesystem.Directory
+	Started time.Time
+
+	Settings *config.Config
+	Server   *server.Server
+	// NOTE: RPC is the control socket; application methods are registered on
+	// it the same way as the built-in ones.
+	RPC *rpc.Server
+	// NOTE: Health is where subsystems register liveness and readiness
+	// checks, reported by `status` and the optional /healthz and /readyz.
+	Health *health.Registry
+	// NOTE: Log is for diagnostics, IO.Error stays the channel to the user of
+	// a command. It writes to stderr until OpenLog adds the configured sinks.
+	Log *log.Logger
+	// NOTE: Metrics holds the process, server and control socket metrics;
+	// subsystems register their own on it.
+	Metrics *metrics.Registry
+	// NOTE: Jobs is the periodic work of a daemon, jobs start running once
+	// the daemon is Ready.
+	Jobs *scheduler.Scheduler
+	// NOTE: Events is the bus components notify each other on, the built-in
+	// topics are in events.go. Clients stream it with `app-cli events`.
+	Events *event.Bus
+	// NOTE: Actions is where controllers register the application logic,
+	// dispatched by name by whatever presents it.
+	Actions *controller.Registry
+	// NOTE: Store is where repositories keep models, a key-value store under
+	// Data; it is safe to use from the cli and the daemon at the same time.
+	Store model.Backend
+	// NOTE: Models lists the repositories on Store, so `app-cli migrate` can
+	// upgrade their documents to the current schema versions.
+	Models *model.Registry
+	// NOTE: Journal keeps what actions changed so `app-cli undo` can put it
+	// back, see journal/journal.go.
+	Journal *journal.Journal
+	// NOTE: Listeners holds the effective address of every bound listener by
+	// name, it is filled in by the server as listeners come up.
+	Listeners map[string]net.Addr
+
+	mu       sync.Mutex
+	context  context.Context
+	cancel   context.CancelFunc
+	shutdown []func(context.Context) error
+	reload   []func(*config.Config) error
 }
 
 func Initialize(name string, version Version) *Application {
@@ -21,25 +86,134 @@ func Initialize(name string, version Version) *Application {
 		Name:    name,
 		Version: version,
 		IO: &IO{
-			Output: os.Stdout(),
-			Input:  os.Stdin(),
-			Error:  os.Stderr(),
+			Output: os.Stdout,
+			Input:  os.Stdin,
+			Error:  os.Stderr,
 		},
 		Data: filesystem.Directory{
-			Path: filesystem.Path{fmt.Sprintf("/home/%s/%s/%s", os.Getenv("USER"), ".local/share", name)},
+			Path: filesystem.Path(fmt.Sprintf("/home/%s/%s/%s", os.Getenv("USER"), ".local/share", name)),
 		},
 		Config: filesystem.Directory{
-			Path: filesystem.Path{fmt.Sprintf("/home/%s/%s/%s", os.Getenv("USER"), ".config", name)},
+			Path: filesystem.Path(fmt.Sprintf("/home/%s/%s/%s", os.Getenv("USER"), ".config", name)),
+		},
+		// NOTE: State holds what is not worth backing up but should survive a
+		// restart: history, logs, crash reports.
+		State: filesystem.Directory{
+			Path: filesystem.Path(fmt.Sprintf("/home/%s/%s/%s", os.Getenv("USER"), ".local/state", name)),
 		},
+		Runtime: filesystem.Directory{
+			Path: filesystem.Path(fmt.Sprintf("%s/%s-%d", os.TempDir(), name, os.Getuid())),
+		},
+		Started: time.Now(),
+		RPC:     rpc.NewServer(),
+		Health:  health.New(),
+		Metrics: metrics.NewRegistry(),
+		Actions: controller.NewRegistry(),
+		Models:  model.NewRegistry(),
+	}
+	app.Log = log.New(log.Info, log.NewWriterSink(app.IO.Error, log.TextEncoder{}))
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		app.Runtime.Path = filesystem.Path(fmt.Sprintf("%s/%s", runtime, name))
 	}
+	app.systemDirectories()
+	app.Store = model.NewKV(kv.New(app.StorePath()), model.JSON{})
+	app.Journal = journal.New(kv.New(app.JournalPath()), app.Store)
 
-	if _, err := os.Stat(app.Data.Path); os.IsNotExist(err) {
-		_ = os.MkdirAll(app.Data.Path, os.FileMode(0770))
+	app.context, app.cancel = context.WithCancel(context.Background())
+	app.Jobs = scheduler.New(app.context, scheduler.NewHistory(kv.New(app.JobHistory())), app.Log.Named("jobs"))
+	app.Events = event.New(app.Log.Named("events"))
+	// NOTE: Every action, whatever presents it, recovers from panics, is
+	// logged, honours its `timeout` metadata, can be dry run and is journaled
+	// to be undone.
+	app.Actions.Use(
+		controller.Recover(app.Crashes()),
+		controller.Logging(app.Log.Named("actions")),
+		controller.Timeout(0),
+		controller.DryRun(),
+		app.Journal.Middleware(),
+	)
+	if err := app.Actions.Add(append(app.Journal.Actions(), app.queryAction())...); err != nil {
+		app.Log.Error("registering the built-in actions failed", "error", err)
+	}
+	app.Jobs.OnRun = app.publishRun
+	app.Listeners = make(map[string]net.Addr)
+	app.Server = server.New(server.DefaultConfig())
+	app.Server.OnListen = func(listener *server.Listener) {
+		app.mu.Lock()
+		defer app.mu.Unlock()
+		app.Listeners[listener.Name] = listener.Addr()
 	}
+	app.instrument()
 
-	if _, err := os.Stat(app.Config.Path); os.IsNotExist(err) {
-		_ = os.MkdirAll(app.Config.Path, os.FileMode(0770))
+	for _, directory := range []filesystem.Directory{app.Data, app.Config, app.State} {
+		if _, err := os.Stat(string(directory.Path)); os.IsNotExist(err) {
+			_ = os.MkdirAll(string(directory.Path), os.FileMode(0770))
+		}
 	}
+	_ = os.MkdirAll(string(app.Runtime.Path), os.FileMode(0700))
+	app.registerControl()
 
 	return app
 }
+
+// systemDirectories switches to system mode when the service manager passes
+// the directories in the environment, as systemd does for StateDirectory= and
+// friends and the generated OpenRC and runit scripts do (see service/).
+func (self *Application) systemDirectories() {
+	if directories := os.Getenv("CONFIGURATION_DIRECTORY"); directories != "" {
+		self.Config.Path = filesystem.Path(strings.Split(directories, ":")[0])
+	}
+	if directories := os.Getenv("STATE_DIRECTORY"); directories != "" {
+		paths := strings.Split(directories, ":")
+		self.Data.Path = filesystem.Path(paths[0])
+		self.State.Path = filesystem.Path(paths[len(paths)-1])
+	}
+	if directories := os.Getenv("RUNTIME_DIRECTORY"); directories != "" {
+		self.Runtime.Path = filesystem.Path(strings.Split(directories, ":")[0])
+	}
+}
+
+// Crashes is where crash reports of recovered panics are written.
+func (self *Application) Crashes() string {
+	return string(self.State.Path) + "/crashes"
+}
+
+// JobHistory is the store the runs of scheduled jobs are kept in.
+func (self *Application) JobHistory() string {
+	return string(self.State.Path) + "/jobs"
+}
+
+// JournalPath is the store the undo journal is kept in.
+func (self *Application) JournalPath() string {
+	return string(self.State.Path) + "/journal"
+}
+
+// StorePath is the store models are kept in.
+func (self *Application) StorePath() string {
+	return string(self.Data.Path) + "/store"
+}
+
+func (self *Application) ConfigFile() string {
+	return string(self.Config.Path) + "/config.yaml"
+}
+
+// LoadConfig loads the config file into Settings. A missing config file is not
+// an error, the application falls back to the defaults.
+func (self *Application) LoadConfig() error {
+	settings, err := config.LoadConfig(self.ConfigFile())
+	switch {
+	case os.IsNotExist(err):
+		self.Settings = config.Default()
+		self.Settings.Log.Apply(self.Log)
+		return nil
+	case err != nil:
+		self.Settings = config.Default()
+		self.Server.Config = self.Settings.Server
+		self.Settings.Log.Apply(self.Log)
+		return fault.Wrap(err, fault.Config, "config.invalid", "failed to load %s", self.ConfigFile())
+	}
+	self.Settings = settings
+	self.Server.Config = settings.Server
+	settings.Log.Apply(self.Log)
+	return nil
+}
@@ -0,0 +1,277 @@
+package binding
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"../controller"
+	"../fault"
+	"../validate"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Bindings present every registered action in each interface, from its
+// declaration alone: a cli command, an HTTP route and a control socket
+// method. The fields of the input struct are the parameters, named as in
+// their json tag:
+//
+//   type AddNote struct {
+//     Title string   `json:"title" bind:"arg" help:"the title" validate:"required"`
+//     Tags  []string `json:"tags" help:"comma separated tags"`
+//   }
+//
+//   notes.add            app-cli notes add <title> [--tags a,b]
+//                        POST /api/notes/add  {"title": "..."} or ?title=...
+//                        control socket method notes.add
+//
+// Fields tagged `bind:"arg"` are positional on the command-line, in order,
+// and optional unless they are validated as required; `default:"..."` is the
+// value of a flag not given; `bind:"-"` hides a field from the command-line
+// and query strings. Every interface dispatches through the registry, so the
+// input is validated the same way, runs inside the same middleware and errors
+// keep their class: the exit code, the HTTP status and the RPC error follow
+// from it. `--dry-run`, `?dry_run=true`, dry run an action, see
+// controller.DryRun.
+////////////////////////////////////////////////////////////////////////////////
+
+type field struct {
+	name     string
+	index    []int
+	kind     reflect.Type
+	argument bool
+	help     string
+	value    string
+	required bool
+}
+
+// fields are the bindable fields of an input type, embedded structs
+// flattened; a type that is not a struct has none.
+func fields(input reflect.Type) []field {
+	if input.Kind() == reflect.Ptr {
+		input = input.Elem()
+	}
+	if input.Kind() != reflect.Struct {
+		return nil
+	}
+	var found []field
+	for index := 0; index < input.NumField(); index++ {
+		structField := input.Field(index)
+		if structField.PkgPath != "" && !structField.Anonymous {
+			continue
+		}
+		bind := structField.Tag.Get("bind")
+		name := strings.Split(structField.Tag.Get("json"), ",")[0]
+		if bind == "-" || name == "-" {
+			continue
+		}
+		if structField.Anonymous && name == "" {
+			for _, embedded := range fields(structField.Type) {
+				embedded.index = append([]int{index}, embedded.index...)
+				found = append(found, embedded)
+			}
+			continue
+		}
+		if name == "" {
+			name = structField.Name
+		}
+		found = append(found, field{
+			name:     name,
+			index:    []int{index},
+			kind:     structField.Type,
+			argument: bind == "arg",
+			help:     structField.Tag.Get("help"),
+			value:    structField.Tag.Get("default"),
+			required: required(structField.Tag.Get("validate")),
+		})
+	}
+	return found
+}
+
+func required(rules string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// Bind returns a pointer to a new input of the action with its fields set
+// from named values, such as flags or a query string, and positional
+// arguments. Values that do not parse are reported per field.
+func Bind(action *controller.Action, values map[string][]string, arguments []string) (interface{}, error) {
+	input := reflect.New(action.Input())
+	var errs validate.Errors
+	var positional []field
+	for _, field := range fields(action.Input()) {
+		target := input.Elem().FieldByIndex(field.index)
+		given, ok := values[field.name]
+		// NOTE: Arguments can be named too, as in a query string.
+		if field.argument && !ok {
+			positional = append(positional, field)
+			continue
+		}
+		if !ok && field.value != "" {
+			given, ok = []string{field.value}, true
+		}
+		if !ok {
+			continue
+		}
+		if err := set(target, given); err != nil {
+			errs = append(errs, validate.FieldError{Field: field.name, Rule: "type", Message: err.Error()})
+		}
+	}
+	for index, field := range positional {
+		if len(arguments) <= index {
+			if field.value != "" {
+				set(input.Elem().FieldByIndex(field.index), []string{field.value})
+			}
+			continue
+		}
+		given := arguments[index : index+1]
+		// NOTE: The last positional slice takes the remaining arguments.
+		if index == len(positional)-1 && field.kind.Kind() == reflect.Slice {
+			given = arguments[index:]
+		}
+		if err := set(input.Elem().FieldByIndex(field.index), given); err != nil {
+			errs = append(errs, validate.FieldError{Field: field.name, Rule: "type", Message: err.Error()})
+		}
+	}
+	if len(positional) < len(arguments) && (len(positional) == 0 || positional[len(positional)-1].kind.Kind() != reflect.Slice) {
+		return nil, fault.UsageError("usage.unexpected_argument", "unexpected argument %q", arguments[len(positional)]).
+			WithHint("usage: %s", usage(action))
+	}
+	if len(errs) != 0 {
+		return nil, fault.Wrap(errs, fault.Usage, "usage.invalid", "invalid input for %s", action.Name).
+			WithHint("fix the fields listed and retry")
+	}
+	return input.Interface(), nil
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// set parses text values into a field; slices take every value, and each
+// value may list several separated by commas.
+func set(target reflect.Value, values []string) error {
+	if target.Kind() == reflect.Ptr {
+		target.Set(reflect.New(target.Type().Elem()))
+		target = target.Elem()
+	}
+	if target.Kind() == reflect.Slice && target.Type().Elem().Kind() != reflect.Uint8 {
+		var items []string
+		for _, value := range values {
+			items = append(items, strings.Split(value, ",")...)
+		}
+		slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for index, item := range items {
+			if err := parse(slice.Index(index), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+		return nil
+	}
+	return parse(target, values[len(values)-1])
+}
+
+func parse(target reflect.Value, text string) error {
+	switch {
+	case target.Type() == durationType:
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("must be a duration, such as 90s or 1h30m")
+		}
+		target.SetInt(int64(duration))
+		return nil
+	case target.Type() == timeType:
+		parsed, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return fmt.Errorf("must be a time, such as 2006-01-02T15:04:05Z")
+		}
+		target.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.String:
+		target.SetString(text)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		target.SetBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err := strconv.ParseInt(text, 10, target.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		target.SetInt(value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err := strconv.ParseUint(text, 10, target.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a positive integer")
+		}
+		target.SetUint(value)
+	case reflect.Float32, reflect.Float64:
+		value, err := strconv.ParseFloat(text, target.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		target.SetFloat(value)
+	default:
+		// NOTE: Anything else, such as a map, is given as JSON.
+		if err := json.Unmarshal([]byte(text), target.Addr().Interface()); err != nil {
+			return fmt.Errorf("must be JSON for %s", target.Type())
+		}
+	}
+	return nil
+}
+
+// Description is an action as the bindings expose it.
+type Description struct {
+	Name        string            `json:"name" yaml:"name"`
+	Description string            `json:"description" yaml:"description"`
+	Usage       string            `json:"usage" yaml:"usage"`
+	Method      string            `json:"method" yaml:"method"`
+	Path        string            `json:"path" yaml:"path"`
+	Metadata    map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+}
+
+type Descriptions []Description
+
+func (self Descriptions) String() string {
+	var text strings.Builder
+	for _, description := range self {
+		fmt.Fprintf(&text, "%-32s %-5s %s\n", description.Usage, description.Method, description.Description)
+	}
+	return text.String()
+}
+
+// Describe lists the actions that are not hidden, with the HTTP routes they
+// have under a prefix.
+func Describe(registry *controller.Registry, prefix string) Descriptions {
+	actions := registry.Actions()
+	descriptions := make(Descriptions, 0, len(actions))
+	for _, action := range actions {
+		if action.Metadata["hidden"] == "true" {
+			continue
+		}
+		descriptions = append(descriptions, Description{
+			Name:        action.Name,
+			Description: action.Description,
+			Usage:       usage(action),
+			Method:      method(action),
+			Path:        strings.TrimSuffix(prefix, "/") + "/" + strings.ReplaceAll(action.Name, ".", "/"),
+			Metadata:    action.Metadata,
+		})
+	}
+	return descriptions
+}
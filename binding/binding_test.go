@@ -0,0 +1,250 @@
+package binding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"../cli"
+	"../controller"
+	"../fault"
+	"../rpc"
+	"../validate"
+)
+
+type paging struct {
+	Limit int `json:"limit" default:"10"`
+}
+
+type addNote struct {
+	Title  string            `json:"title" bind:"arg" help:"the title" validate:"required,max=20"`
+	Words  []string          `json:"words" bind:"arg"`
+	Tags   []string          `json:"tags" help:"comma separated tags"`
+	Pinned bool              `json:"pinned"`
+	Remind time.Duration     `json:"remind_in"`
+	Labels map[string]string `json:"labels"`
+	Secret string            `json:"secret" bind:"-"`
+	paging
+	private string
+}
+
+type result struct {
+	Input  addNote `json:"input"`
+	DryRun bool    `json:"dry_run"`
+}
+
+type notes struct{ controller.Base }
+
+func (notes) Actions() controller.Actions {
+	return controller.Actions{
+		controller.NewAction("notes.add", "add a note", func(ctx context.Context, input addNote) (result, error) {
+			return result{Input: input, DryRun: controller.IsDryRun(ctx)}, nil
+		}),
+		controller.NewAction("notes.count", "count the notes", func(ctx context.Context, input paging) (int, error) {
+			return input.Limit, nil
+		}).With("method", "GET"),
+		controller.NewAction("notes.purge", "", func(ctx context.Context, input struct{}) (bool, error) {
+			return true, nil
+		}).With("hidden", "true"),
+	}
+}
+
+func testRegistry(t *testing.T) (*controller.Registry, *controller.Action) {
+	t.Helper()
+	registry := controller.NewRegistry()
+	if err := registry.Register(notes{}); err != nil {
+		t.Fatal(err)
+	}
+	action, _ := registry.Action("notes.add")
+	return registry, action
+}
+
+func TestBind(t *testing.T) {
+	_, action := testRegistry(t)
+	tests := []struct {
+		name      string
+		values    map[string][]string
+		arguments []string
+		expected  addNote
+		code      string
+		fields    []string
+	}{
+		{"defaults", nil, nil, addNote{paging: paging{Limit: 10}}, "", nil},
+		{"arguments", nil, []string{"a", "b", "c"}, addNote{Title: "a", Words: []string{"b", "c"}, paging: paging{Limit: 10}}, "", nil},
+		{
+			"values",
+			map[string][]string{"title": {"named"}, "tags": {"a,b", "c"}, "pinned": {"true"}, "remind_in": {"1h"},
+				"labels": {`{"k":"v"}`}, "limit": {"3"}},
+			nil,
+			addNote{Title: "named", Tags: []string{"a", "b", "c"}, Pinned: true, Remind: time.Hour,
+				Labels: map[string]string{"k": "v"}, paging: paging{Limit: 3}},
+			"", nil,
+		},
+		{"hidden fields", map[string][]string{"secret": {"x"}, "private": {"x"}}, nil, addNote{paging: paging{Limit: 10}}, "", nil},
+		{
+			"invalid values",
+			map[string][]string{"pinned": {"maybe"}, "remind_in": {"soon"}, "limit": {"many"}},
+			nil, addNote{}, "usage.invalid", []string{"pinned", "remind_in", "limit"},
+		},
+	}
+	for _, test := range tests {
+		input, err := Bind(action, test.values, test.arguments)
+		if test.code != "" {
+			var fields []string
+			for _, field := range validate.Fields(err) {
+				fields = append(fields, field.Field)
+			}
+			if fault.As(err).Code != test.code || !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("%s: failed with %v on %v, expected %s on %v", test.name, err, fields, test.code, test.fields)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if !reflect.DeepEqual(*input.(*addNote), test.expected) {
+			t.Errorf("%s: bound %+v, expected %+v", test.name, *input.(*addNote), test.expected)
+		}
+	}
+}
+
+func TestUnexpectedArgument(t *testing.T) {
+	registry, _ := testRegistry(t)
+	action, _ := registry.Action("notes.count")
+	_, err := Bind(action, nil, []string{"extra"})
+	if fault.As(err).Code != "usage.unexpected_argument" || !strings.Contains(fault.As(err).HintText(), "notes count [--limit limit]") {
+		t.Errorf("an extra argument returned %v, %q", err, fault.As(err).HintText())
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	registry, _ := testRegistry(t)
+	expected := Descriptions{
+		{
+			Name: "notes.add", Description: "add a note", Method: "POST", Path: "/api/notes/add",
+			Usage:    "notes add <title> [words...] [--tags tags] [--pinned] [--remind-in remind_in] [--labels labels] [--limit limit]",
+			Metadata: map[string]string{},
+		},
+		{
+			Name: "notes.count", Description: "count the notes", Method: "GET", Path: "/api/notes/count",
+			Usage: "notes count [--limit limit]", Metadata: map[string]string{"method": "GET"},
+		},
+	}
+	if descriptions := Describe(registry, "/api/"); !reflect.DeepEqual(descriptions, expected) {
+		t.Errorf("described\n%+v\nexpected\n%+v", descriptions, expected)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	registry, _ := testRegistry(t)
+	tests := []struct {
+		arguments []string
+		expected  string
+		code      string
+	}{
+		{[]string{"notes", "add", "hello", "--tags", "a,b", "--remind-in", "90s"}, `"title":"hello","words":null,"tags":["a","b"]`, ""},
+		{[]string{"notes", "add", "--pinned", "hello", "--dry-run"}, `"dry_run":true`, ""},
+		{[]string{"notes", "add"}, "", "usage.invalid"},
+		{[]string{"notes", "add", "far too long for a title"}, "", "usage.invalid"},
+		{[]string{"notes", "count", "--limit", "3"}, "3", ""},
+		{[]string{"notes", "count", "--secret", "x"}, "", "usage.unknown_flag"},
+	}
+	for _, test := range tests {
+		output := new(bytes.Buffer)
+		router := cli.New("app", "1.0.0", strings.NewReader(""), output, new(bytes.Buffer))
+		commands, err := Commands(registry, router.Commands)
+		if err != nil {
+			t.Fatal(err)
+		}
+		router.Command(commands...)
+		err = router.Run(append(test.arguments, "--output", "json"))
+		if test.code != "" {
+			if fault.As(err).Code != test.code {
+				t.Errorf("%v: returned %v, expected %s", test.arguments, err, test.code)
+			}
+			continue
+		}
+		compact := new(bytes.Buffer)
+		json.Compact(compact, output.Bytes())
+		if err != nil || !strings.Contains(compact.String(), test.expected) {
+			t.Errorf("%v: printed %s, %v, expected %s", test.arguments, compact, err, test.expected)
+		}
+	}
+}
+
+func TestCommandClash(t *testing.T) {
+	registry, _ := testRegistry(t)
+	tests := []struct {
+		builtin *cli.Command
+		code    string
+	}{
+		{&cli.Command{Name: "status"}, ""},
+		{&cli.Command{Name: "notes"}, "internal.action"},
+		{&cli.Command{Name: "notebook", Aliases: []string{"notes"}}, "internal.action"},
+	}
+	for _, test := range tests {
+		commands, err := Commands(registry, []*cli.Command{test.builtin})
+		switch {
+		case test.code == "" && (err != nil || len(commands) != 1):
+			t.Errorf("%s: returned %d commands, %v", test.builtin.Name, len(commands), err)
+		case test.code != "" && (fault.As(err) == nil || fault.As(err).Code != test.code || commands != nil):
+			t.Errorf("%s: returned %d commands, %v, expected %s", test.builtin.Name, len(commands), err, test.code)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	registry, _ := testRegistry(t)
+	mux := http.NewServeMux()
+	Mount(registry, mux, "/api/")
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		status   int
+		expected string
+	}{
+		{"GET", "/api/", "", 200, `"name": "notes.count"`},
+		{"POST", "/api/notes/add", `{"title": "body"}`, 200, `"title": "body"`},
+		{"POST", "/api/notes/add?title=query&tags=a,b", `{"title": "body"}`, 200, `"title": "body"`},
+		{"POST", "/api/notes/add?title=query&tags=a,b", "", 200, `"b"`},
+		{"POST", "/api/notes/add?title=a&dry_run=true", "", 200, `"dry_run": true`},
+		{"POST", "/api/notes/add", `{"title":`, 400, `"usage.invalid_input"`},
+		{"POST", "/api/notes/add", `{}`, 422, `"field": "title"`},
+		{"GET", "/api/notes/add", "", 405, `"usage.method_not_allowed"`},
+		{"GET", "/api/notes/count?limit=4", "", 200, "4"},
+		{"GET", "/api/notes/count?limit=x", "", 422, `"field": "limit"`},
+		{"GET", "/api/notes/missing", "", 404, `"usage.unknown_action"`},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+		if recorder.Code != test.status || !strings.Contains(recorder.Body.String(), test.expected) {
+			t.Errorf("%s %s: answered %d %s, expected %d with %s", test.method, test.path,
+				recorder.Code, recorder.Body, test.status, test.expected)
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	registry, _ := testRegistry(t)
+	server := rpc.NewServer()
+	if err := Methods(registry, server); err != nil {
+		t.Fatal(err)
+	}
+	output, err := server.Call(context.Background(), "notes.add", json.RawMessage(`{"title": "rpc"}`))
+	if err != nil || output.(result).Input.Title != "rpc" {
+		t.Errorf("called as %v, %v", output, err)
+	}
+	if _, err := server.Call(context.Background(), "notes.add", json.RawMessage(`{}`)); fault.As(err).Code != "usage.invalid" {
+		t.Errorf("an invalid call returned %v", err)
+	}
+	if err := Methods(registry, server); fault.As(err).Code != "internal.action" {
+		t.Errorf("registering twice returned %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package binding
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"../cli"
+	"../controller"
+	"../fault"
+)
+
+// Commands returns a command for every action, grouped by the dots of their
+// names: `notes.add` is `notes add`. Fields are flags, with underscores as
+// dashes, and `bind:"arg"` fields are the arguments. A top-level name taken
+// by one of the builtin commands is an error, and then none are returned.
+func Commands(registry *controller.Registry, builtin []*cli.Command) ([]*cli.Command, error) {
+	var commands []*cli.Command
+	for _, action := range registry.Actions() {
+		path := strings.Split(action.Name, ".")
+		for _, command := range builtin {
+			if command.Is(path[0]) {
+				return nil, fault.InternalError("internal.action", "action %q is also the command %q", action.Name, command.Name)
+			}
+		}
+		siblings := &commands
+		var command *cli.Command
+		for _, name := range path {
+			command = nil
+			for _, existing := range *siblings {
+				if existing.Name == name {
+					command = existing
+				}
+			}
+			if command == nil {
+				command = &cli.Command{Name: name}
+				*siblings = append(*siblings, command)
+			}
+			siblings = &command.Subcommands
+		}
+		bindCommand(command, registry, action)
+	}
+	return commands, nil
+}
+
+func bindCommand(command *cli.Command, registry *controller.Registry, action *controller.Action) {
+	command.Description = action.Description
+	command.Hidden = action.Metadata["hidden"] == "true"
+	for _, field := range fields(action.Input()) {
+		if field.argument {
+			continue
+		}
+		command.Flags = append(command.Flags, cli.Flag{
+			Name:        flagName(field.name),
+			Description: field.help,
+			Default:     field.value,
+			Boolean:     field.kind.Kind() == reflect.Bool,
+		})
+	}
+	command.Flags = append(command.Flags, cli.Flag{
+		Name:        "dry-run",
+		Description: "show what the action would do, without doing it",
+		Boolean:     true,
+	})
+	command.Usage = usage(action)
+	command.Action = func(context *cli.Context) (interface{}, error) {
+		return runCommand(registry, action, context)
+	}
+}
+
+// usage is the cli usage line of an action, its positional arguments then
+// its flags.
+func usage(action *controller.Action) string {
+	usage := strings.ReplaceAll(action.Name, ".", " ")
+	var flags string
+	for _, field := range fields(action.Input()) {
+		switch {
+		case field.argument && field.required:
+			usage += " <" + field.name + ellipsis(field) + ">"
+		case field.argument:
+			usage += " [" + field.name + ellipsis(field) + "]"
+		case field.kind.Kind() == reflect.Bool:
+			flags += " [--" + flagName(field.name) + "]"
+		default:
+			flags += " [--" + flagName(field.name) + " " + field.name + "]"
+		}
+	}
+	usage += flags
+	return usage
+}
+
+func ellipsis(field field) string {
+	if field.kind.Kind() == reflect.Slice {
+		return "..."
+	}
+	return ""
+}
+
+func runCommand(registry *controller.Registry, action *controller.Action, given *cli.Context) (interface{}, error) {
+	values := make(map[string][]string)
+	for _, field := range fields(action.Input()) {
+		if !field.argument && given.IsSet(flagName(field.name)) {
+			values[field.name] = []string{given.Flag(flagName(field.name))}
+		}
+	}
+	input, err := Bind(action, values, given.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if given.IsSet("dry-run") {
+		ctx = controller.WithDryRun(ctx)
+	}
+	return registry.Dispatch(ctx, action.Name, input)
+}
+
+func flagName(field string) string { return strings.ReplaceAll(field, "_", "-") }
@@ -0,0 +1,88 @@
+package binding
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"../cli"
+	"../controller"
+	"../fault"
+)
+
+// maxBody is the largest JSON body a route accepts.
+const maxBody = 1 << 20
+
+// Mount serves every action under a prefix, `notes.add` at `<prefix>/notes/add`;
+// the prefix itself lists them. Actions take POST, with the input as a JSON
+// body and, or, a query string; actions declared `.With("method", "GET")`
+// only read and take GET with a query string; `?dry_run=true` dry runs any.
+// Errors are the json error document of the cli, with the status of their
+// class.
+func Mount(registry *controller.Registry, mux *http.ServeMux, prefix string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux.HandleFunc(prefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		if path == "" {
+			writeJSON(w, http.StatusOK, Describe(registry, prefix))
+			return
+		}
+		name := strings.ReplaceAll(path, "/", ".")
+		action, ok := registry.Action(name)
+		if !ok {
+			cli.WriteHTTPError(w, fault.UsageError("usage.unknown_action", "unknown action %q", name).
+				WithHint("list the actions with: GET %s/", prefix))
+			return
+		}
+		if r.Method != method(action) {
+			w.Header().Set("Allow", method(action))
+			cli.WriteHTTPError(w, fault.UsageError("usage.method_not_allowed", "%s takes %s, not %s", name, method(action), r.Method).
+				WithHint("send the request as %s", method(action)))
+			return
+		}
+		output, err := serve(registry, action, r)
+		if err != nil {
+			cli.WriteHTTPError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, output)
+	})
+}
+
+func serve(registry *controller.Registry, action *controller.Action, r *http.Request) (interface{}, error) {
+	input, err := Bind(action, r.URL.Query(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if r.Method == http.MethodPost {
+		// NOTE: The body is decoded over the query string, a field given in
+		// both takes the body's value.
+		decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBody))
+		if err := decoder.Decode(input); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fault.Wrap(err, fault.Usage, "usage.invalid_input", "invalid JSON body for %s", action.Name)
+		}
+	}
+	ctx := r.Context()
+	if dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run")); dryRun {
+		ctx = controller.WithDryRun(ctx)
+	}
+	return registry.Dispatch(ctx, action.Name, input)
+}
+
+func method(action *controller.Action) string {
+	if strings.EqualFold(action.Metadata["method"], http.MethodGet) {
+		return http.MethodGet
+	}
+	return http.MethodPost
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
@@ -0,0 +1,33 @@
+package binding
+
+import (
+	"context"
+	"encoding/json"
+
+	"../controller"
+	"../fault"
+	"../rpc"
+)
+
+// Methods registers a control socket method for every action, by its name,
+// taking the input as params. A name taken by another method is an error, and
+// then none are registered.
+func Methods(registry *controller.Registry, server *rpc.Server) error {
+	taken := make(map[string]bool)
+	for _, method := range server.Methods() {
+		taken[method.Name] = true
+	}
+	actions := registry.Actions()
+	for _, action := range actions {
+		if taken[action.Name] {
+			return fault.InternalError("internal.action", "action %q is also a control socket method", action.Name)
+		}
+	}
+	for _, action := range actions {
+		name := action.Name
+		server.Register(name, action.Description, func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+			return registry.Dispatch(ctx, name, params)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"strings"
+
+	"../fault"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Two kinds of aliases: a command declares short names for itself in
+// `Command.Aliases` (`rm` for `remove`), and the user declares aliases in the
+// config file that expand to a full command-line, git-style:
+//
+//   aliases:
+//     ls: list --output table
+//     wipe: remove --all --yes
+//
+// User aliases never shadow a command, and expand recursively at most once per
+// alias name so an alias can build on another without looping.
+////////////////////////////////////////////////////////////////////////////////
+
+func (self *Router) expandAliases(arguments []string) ([]string, error) {
+	expanded := make(map[string]bool)
+	for {
+		index := self.commandIndex(arguments)
+		if index < 0 {
+			return arguments, nil
+		}
+		name := arguments[index]
+		expansion, ok := self.Aliases[name]
+		if !ok || self.lookup(self.Commands, name) != nil {
+			return arguments, nil
+		}
+		if expanded[name] {
+			return nil, fault.ConfigError("config.recursive_alias", "alias %q expands to itself", name)
+		}
+		expanded[name] = true
+		if strings.HasPrefix(strings.TrimSpace(expansion), "!") {
+			return nil, fault.ConfigError("config.shell_alias", "alias %q runs a shell command, which is not supported", name)
+		}
+		words, err := Split(expansion)
+		if err != nil {
+			return nil, fault.Wrap(err, fault.Config, "config.invalid_alias", "alias %q is invalid", name)
+		}
+		result := append([]string{}, arguments[:index]...)
+		result = append(result, words...)
+		arguments = append(result, arguments[index+1:]...)
+	}
+}
+
+// commandIndex locates the first word that is not a global flag or the value of
+// one, which is where a command (or a user alias) has to be.
+func (self *Router) commandIndex(arguments []string) int {
+	for index := 0; index < len(arguments); index++ {
+		argument := arguments[index]
+		switch {
+		case argument == "--":
+			return -1
+		case strings.HasPrefix(argument, "-") && argument != "-":
+			name, _, hasValue := splitFlag(argument)
+			if flag := lookupFlag(self.Flags, name); flag != nil && !flag.Boolean && !hasValue {
+				index++
+			}
+		default:
+			return index
+		}
+	}
+	return -1
+}
+
+func (self *Router) aliasHelp() (help []CommandHelp) {
+	for name, expansion := range self.Aliases {
+		if self.lookup(self.Commands, name) == nil {
+			help = append(help, CommandHelp{Name: name, Description: "alias for '" + expansion + "'"})
+		}
+	}
+	return help
+}
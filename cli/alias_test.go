@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"../fault"
+)
+
+func TestExpandAliases(t *testing.T) {
+	router := testRouter()
+	router.Aliases = map[string]string{
+		"ls":      "notes list",
+		"lj":      "ls --output json",
+		"version": "notes list",
+		"loop":    "loop",
+		"sh":      "!rm -rf /",
+		"quoted":  `notes add "a title" --tags 'x y'`,
+	}
+	tests := []struct {
+		arguments []string
+		expanded  []string
+		code      string
+	}{
+		{[]string{"ls"}, []string{"notes", "list"}, ""},
+		{[]string{"-o", "yaml", "ls", "--x"}, []string{"-o", "yaml", "notes", "list", "--x"}, ""},
+		{[]string{"lj"}, []string{"notes", "list", "--output", "json"}, ""},
+		{[]string{"version"}, []string{"version"}, ""},
+		{[]string{"quoted"}, []string{"notes", "add", "a title", "--tags", "x y"}, ""},
+		{[]string{"--", "ls"}, []string{"--", "ls"}, ""},
+		{[]string{"loop"}, nil, "config.recursive_alias"},
+		{[]string{"sh"}, nil, "config.shell_alias"},
+	}
+	for _, test := range tests {
+		expanded, err := router.expandAliases(test.arguments)
+		var classified *fault.Error
+		switch {
+		case test.code != "" && (!errors.As(err, &classified) || classified.Code != test.code):
+			t.Errorf("%q failed with %v, expected %s", test.arguments, err, test.code)
+		case test.code == "" && err != nil:
+			t.Errorf("%q failed: %v", test.arguments, err)
+		case !reflect.DeepEqual(expanded, test.expanded):
+			t.Errorf("%q expanded to %q, expected %q", test.arguments, expanded, test.expanded)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"install-service", "list", "notes", "version", "status"}
+	tests := []struct {
+		name        string
+		suggestions []string
+	}{
+		{"lst", []string{"list"}},
+		{"nots", []string{"notes"}},
+		{"stauts", []string{"status"}},
+		{"inst", []string{"list", "install-service"}},
+		{"xyz", nil},
+		{"list", nil},
+	}
+	for _, test := range tests {
+		if suggestions := suggest(test.name, candidates); !reflect.DeepEqual(suggestions, test.suggestions) {
+			t.Errorf("%q suggested %q, expected %q", test.name, suggestions, test.suggestions)
+		}
+	}
+}
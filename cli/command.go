@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NOTE: An Action never prints, it returns a typed value (a struct with `json`
+// and `yaml` tags) which the router renders in the selected output mode.
+type Action func(context *Context) (interface{}, error)
+
+type Flag struct {
+	Name        string
+	Alias       string
+	Description string
+	Default     string
+	Boolean     bool
+}
+
+type Command struct {
+	Name        string
+	Aliases     []string
+	Usage       string
+	Description string
+	Flags       []Flag
+	Subcommands []*Command
+	Action      Action
+	Hidden      bool
+}
+
+func (self *Command) Is(name string) bool {
+	if self.Name == name {
+		return true
+	}
+	for _, alias := range self.Aliases {
+		if alias == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Command) Subcommand(name string) *Command {
+	for _, command := range self.Subcommands {
+		if command.Is(name) {
+			return command
+		}
+	}
+	return nil
+}
+
+func (self *Command) Flag(name string) *Flag { return lookupFlag(self.Flags, name) }
+
+func lookupFlag(flags []Flag, name string) *Flag {
+	for index, flag := range flags {
+		if flag.Name == name || (flag.Alias != "" && flag.Alias == name) {
+			return &flags[index]
+		}
+	}
+	return nil
+}
+
+// Help ///////////////////////////////////////////////////////////////////////
+type Help struct {
+	Name        string        `json:"name" yaml:"name"`
+	Aliases     []string      `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Version     string        `json:"version,omitempty" yaml:"version,omitempty"`
+	Usage       string        `json:"usage" yaml:"usage"`
+	Description string        `json:"description,omitempty" yaml:"description,omitempty"`
+	Commands    []CommandHelp `json:"commands,omitempty" yaml:"commands,omitempty"`
+	Flags       []FlagHelp    `json:"flags,omitempty" yaml:"flags,omitempty"`
+}
+
+type CommandHelp struct {
+	Name        string   `json:"name" yaml:"name"`
+	Aliases     []string `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+	Description string   `json:"description" yaml:"description"`
+}
+
+type FlagHelp struct {
+	Name        string `json:"name" yaml:"name"`
+	Alias       string `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Description string `json:"description" yaml:"description"`
+	Default     string `json:"default,omitempty" yaml:"default,omitempty"`
+	Boolean     bool   `json:"boolean" yaml:"boolean"`
+}
+
+func commandHelp(commands []*Command) (help []CommandHelp) {
+	for _, command := range commands {
+		if !command.Hidden {
+			help = append(help, CommandHelp{Name: command.Name, Aliases: command.Aliases, Description: command.Description})
+		}
+	}
+	sortCommandHelp(help)
+	return help
+}
+
+func sortCommandHelp(help []CommandHelp) {
+	sort.Slice(help, func(i, j int) bool { return help[i].Name < help[j].Name })
+}
+
+func flagHelp(flags []Flag) (help []FlagHelp) {
+	for _, flag := range flags {
+		help = append(help, FlagHelp{
+			Name:        flag.Name,
+			Alias:       flag.Alias,
+			Description: flag.Description,
+			Default:     flag.Default,
+			Boolean:     flag.Boolean,
+		})
+	}
+	return help
+}
+
+func (self Help) String() string {
+	var text strings.Builder
+	if self.Version != "" {
+		fmt.Fprintf(&text, "%s %s\n", self.Name, self.Version)
+	}
+	if self.Description != "" {
+		fmt.Fprintf(&text, "%s\n", self.Description)
+	}
+	if 0 < len(self.Aliases) {
+		fmt.Fprintf(&text, "aliases: %s\n", strings.Join(self.Aliases, ", "))
+	}
+	fmt.Fprintf(&text, "\nusage: %s\n", self.Usage)
+	if 0 < len(self.Commands) {
+		text.WriteString("\ncommands:\n")
+		for _, command := range self.Commands {
+			name := command.Name
+			if 0 < len(command.Aliases) {
+				name += " (" + strings.Join(command.Aliases, ", ") + ")"
+			}
+			fmt.Fprintf(&text, "  %-16s %s\n", name, command.Description)
+		}
+	}
+	if 0 < len(self.Flags) {
+		text.WriteString("\nflags:\n")
+		for _, flag := range self.Flags {
+			name := "--" + flag.Name
+			if flag.Alias != "" {
+				name = "-" + flag.Alias + ", " + name
+			}
+			if flag.Default != "" {
+				fmt.Fprintf(&text, "  %-20s %s (default: %s)\n", name, flag.Description, flag.Default)
+			} else {
+				fmt.Fprintf(&text, "  %-20s %s\n", name, flag.Description)
+			}
+		}
+	}
+	return text.String()
+}
+
+// Version ////////////////////////////////////////////////////////////////////
+type VersionResult struct {
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version" yaml:"version"`
+}
+
+func (self VersionResult) String() string { return fmt.Sprintf("%s %s", self.Name, self.Version) }
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+)
+
+// Complete returns the candidates for the word being typed (current) given
+// the words before it. It is the single completion engine used by the shell
+// and by the hidden `__complete` command that shell completion scripts call.
+func (self *Router) Complete(words []string, current string) []string {
+	var command *Command
+	var pending *Flag
+	commands, topLevel := self.Commands, true
+	for _, word := range words {
+		switch {
+		case pending != nil:
+			pending = nil
+		case word == "--":
+			return nil
+		case strings.HasPrefix(word, "-"):
+			name, _, hasValue := splitFlag(word)
+			if flag := self.flag(command, name); flag != nil && !flag.Boolean && !hasValue {
+				pending = flag
+			}
+		case self.lookup(commands, word) != nil:
+			command = self.lookup(commands, word)
+			commands, topLevel = command.Subcommands, false
+		default:
+			commands, topLevel = nil, false
+		}
+	}
+
+	var candidates []string
+	switch {
+	case pending != nil && pending.Name == "output":
+		candidates = []string{"text", "json", "yaml", "table", "template="}
+	case pending != nil:
+		return nil
+	case strings.HasPrefix(current, "-"):
+		flags := self.Flags
+		if command != nil {
+			flags = append(append([]Flag{}, command.Flags...), flags...)
+		}
+		for _, flag := range flags {
+			candidates = append(candidates, "--"+flag.Name)
+		}
+	default:
+		for _, command := range commands {
+			if !command.Hidden {
+				candidates = append(candidates, command.Name)
+				candidates = append(candidates, command.Aliases...)
+			}
+		}
+		if topLevel {
+			for alias := range self.Aliases {
+				candidates = append(candidates, alias)
+			}
+		}
+	}
+
+	var matches []string
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) && !seen[candidate] {
+			seen[candidate] = true
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+func (self *Router) completeCommand() *Command {
+	return &Command{
+		Name:        "__complete",
+		Usage:       "__complete [words...] <current>",
+		Description: "list completion candidates for the last word",
+		Hidden:      true,
+		Action: func(context *Context) (interface{}, error) {
+			words := context.Arguments
+			if len(words) == 0 {
+				return self.Complete(nil, ""), nil
+			}
+			return self.Complete(words[:len(words)-1], words[len(words)-1]), nil
+		},
+	}
+}
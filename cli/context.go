@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"io"
+	"strconv"
+
+	"../prompt"
+)
+
+type Context struct {
+	Router    *Router
+	Command   *Command
+	Path      []string
+	Arguments []string
+	Flags     map[string]string
+	Mode      Mode
+
+	Input  io.Reader
+	Output io.Writer
+	Error  io.Writer
+
+	prompt *prompt.Prompt
+}
+
+// Prompt returns the interactive prompt bound to the command IO; one prompt is
+// kept per context so buffered line input is shared between questions. Prompts
+// are written to the error stream so the output stream stays parsable.
+func (self *Context) Prompt() *prompt.Prompt {
+	if self.prompt == nil {
+		self.prompt = prompt.New(self.Input, self.Error)
+		self.prompt.Yes = self.Bool("yes")
+	}
+	return self.prompt
+}
+
+// Flag returns the value of a command or global flag, falling back to the
+// declared default when it was not given on the command-line.
+func (self *Context) Flag(name string) string {
+	if value, ok := self.Flags[name]; ok {
+		return value
+	}
+	if self.Command != nil {
+		if flag := self.Command.Flag(name); flag != nil {
+			return flag.Default
+		}
+	}
+	if flag := lookupFlag(self.Router.Flags, name); flag != nil {
+		return flag.Default
+	}
+	return ""
+}
+
+func (self *Context) Bool(name string) bool {
+	value, _ := strconv.ParseBool(self.Flag(name))
+	return value
+}
+
+func (self *Context) Int(name string) int {
+	value, _ := strconv.Atoi(self.Flag(name))
+	return value
+}
+
+func (self *Context) IsSet(name string) bool {
+	_, ok := self.Flags[name]
+	return ok
+}
+
+func (self *Context) Argument(index int) string {
+	if index < len(self.Arguments) {
+		return self.Arguments[index]
+	}
+	return ""
+}
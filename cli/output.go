@@ -0,0 +1,322 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"../fault"
+	"../validate"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Every command returns a typed value instead of printing. The value is then
+// rendered by the format selected with the global `--output` flag, so scripts
+// can depend on the stable `json`/`yaml` field names of the result types and
+// never on the wording of the human readable text output.
+////////////////////////////////////////////////////////////////////////////////
+
+type Format int
+
+const (
+	Text Format = iota
+	JSON
+	YAML
+	Table
+	Template
+)
+
+func (self Format) String() string {
+	switch self {
+	case JSON:
+		return "json"
+	case YAML:
+		return "yaml"
+	case Table:
+		return "table"
+	case Template:
+		return "template"
+	default:
+		return "text"
+	}
+}
+
+// NOTE: Machine readable formats are the ones scripts parse; errors are
+// rendered as structured objects on the output stream in these formats.
+func (self Format) MachineReadable() bool { return self == JSON || self == YAML }
+
+type Mode struct {
+	Format   Format
+	Template *template.Template
+}
+
+// ParseMode accepts the value of the `--output` flag:
+//
+//	text, json, yaml, table or template=<go template>
+func ParseMode(value string) (Mode, error) {
+	name, body := value, ""
+	if index := strings.Index(value, "="); 0 <= index {
+		name, body = value[:index], value[index+1:]
+	}
+	switch strings.ToLower(name) {
+	case "", "text":
+		return Mode{Format: Text}, nil
+	case "json":
+		return Mode{Format: JSON}, nil
+	case "yaml", "yml":
+		return Mode{Format: YAML}, nil
+	case "table":
+		return Mode{Format: Table}, nil
+	case "template":
+		if len(body) == 0 {
+			return Mode{}, fault.UsageError("usage.invalid_output", "output template is empty")
+		}
+		tmpl, err := template.New("output").Parse(body)
+		if err != nil {
+			return Mode{}, fault.Wrap(err, fault.Usage, "usage.invalid_output", "invalid output template")
+		}
+		return Mode{Format: Template, Template: tmpl}, nil
+	default:
+		return Mode{}, fault.UsageError("usage.invalid_output", "unknown output mode %q", name).
+			WithHint("use one of text, json, yaml, table or template=<go template>")
+	}
+}
+
+func (self Mode) Render(w io.Writer, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	switch self.Format {
+	case JSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case YAML:
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case Table:
+		return renderTable(w, value)
+	case Template:
+		if err := self.Template.Execute(w, value); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(w)
+		return err
+	default:
+		return renderText(w, value)
+	}
+}
+
+// Errors /////////////////////////////////////////////////////////////////////
+type ErrorResult struct {
+	Error ErrorObject `json:"error" yaml:"error"`
+}
+
+type ErrorObject struct {
+	Class    string `json:"class" yaml:"class"`
+	Code     string `json:"code" yaml:"code"`
+	Message  string `json:"message" yaml:"message"`
+	Hint     string `json:"hint,omitempty" yaml:"hint,omitempty"`
+	ExitCode int    `json:"exit_code" yaml:"exit_code"`
+	// NOTE: Fields lists each invalid field of a validation error.
+	Fields validate.Errors `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+func NewErrorResult(err error) ErrorResult {
+	classified := fault.As(err)
+	return ErrorResult{Error: ErrorObject{
+		Class:    classified.Class.String(),
+		Code:     classified.Code,
+		Message:  err.Error(),
+		Hint:     classified.HintText(),
+		ExitCode: classified.ExitCode(),
+		Fields:   validate.Fields(err),
+	}}
+}
+
+// NOTE: Human readable formats write the error to the error stream, machine
+// readable formats write a structured object to the output stream so a script
+// reading stdout always gets a parsable document.
+func (self Mode) RenderError(output, errOutput io.Writer, err error) error {
+	if err == nil {
+		return nil
+	}
+	if self.Format.MachineReadable() {
+		return self.Render(output, NewErrorResult(err))
+	}
+	text := errorText(err)
+	if fields := validate.Fields(err); fields != nil {
+		// NOTE: One line per field instead of the joined message.
+		text = strings.TrimSuffix(strings.TrimSuffix(text, fields.Error()), ": ")
+		if text == "error" {
+			text = "error: invalid fields"
+		}
+		for _, field := range fields {
+			text += "\n  " + field.Error()
+		}
+	}
+	_, writeErr := fmt.Fprintf(errOutput, "%s\nhint: %s\n", text, fault.As(err).HintText())
+	return writeErr
+}
+
+// WriteHTTPError answers an HTTP request with the same error document the
+// json output mode renders.
+func WriteHTTPError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(fault.As(err).HTTPStatus())
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(NewErrorResult(err))
+}
+
+func errorText(err error) string {
+	message := err.Error()
+	if strings.HasPrefix(message, "error:") {
+		return message
+	}
+	return "error: " + message
+}
+
+// Text ///////////////////////////////////////////////////////////////////////
+func renderText(w io.Writer, value interface{}) error {
+	switch v := value.(type) {
+	case fmt.Stringer:
+		_, err := fmt.Fprintln(w, strings.TrimRight(v.String(), "\n"))
+		return err
+	case string:
+		_, err := fmt.Fprintln(w, strings.TrimRight(v, "\n"))
+		return err
+	}
+	switch indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return renderTable(w, value)
+	default:
+		_, err := fmt.Fprintln(w, value)
+		return err
+	}
+}
+
+// Table //////////////////////////////////////////////////////////////////////
+// NOTE: Slices of structs are rendered one row per element, a single struct or
+// map is rendered as a two column key/value table. Column names are the json
+// field names so they match the machine readable output.
+func renderTable(w io.Writer, value interface{}) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	v := indirect(reflect.ValueOf(value))
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		var fields []field
+		if element := indirectType(v.Type().Elem()); element.Kind() == reflect.Struct {
+			fields = fieldsOf(element)
+			headers := make([]string, len(fields))
+			for index, f := range fields {
+				headers[index] = strings.ToUpper(f.name)
+			}
+			fmt.Fprintln(table, strings.Join(headers, "\t"))
+		}
+		for index := 0; index < v.Len(); index++ {
+			element := indirect(v.Index(index))
+			if fields == nil || element.Kind() != reflect.Struct {
+				fmt.Fprintln(table, cell(element))
+				continue
+			}
+			cells := make([]string, len(fields))
+			for column, f := range fields {
+				cells[column] = cell(element.FieldByIndex(f.index))
+			}
+			fmt.Fprintln(table, strings.Join(cells, "\t"))
+		}
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			fmt.Fprintf(table, "%s\t%s\n", f.name, cell(v.FieldByIndex(f.index)))
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			fmt.Fprintf(table, "%v\t%s\n", key, cell(v.MapIndex(key)))
+		}
+	default:
+		fmt.Fprintln(table, cell(v))
+	}
+	return table.Flush()
+}
+
+type field struct {
+	name  string
+	index []int
+}
+
+func fieldsOf(t reflect.Type) (fields []field) {
+	for index := 0; index < t.NumField(); index++ {
+		f := t.Field(index)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		fields = append(fields, field{name: name, index: f.Index})
+	}
+	return fields
+}
+
+func cell(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if v.CanInterface() {
+		if stringer, ok := v.Interface().(fmt.Stringer); ok {
+			return stringer.String()
+		}
+	}
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return ""
+	case reflect.Slice, reflect.Array:
+		items := make([]string, v.Len())
+		for index := range items {
+			items[index] = cell(v.Index(index))
+		}
+		return strings.Join(items, ",")
+	case reflect.Struct, reflect.Map:
+		data, _ := json.Marshal(v.Interface())
+		return string(data)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
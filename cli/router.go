@@ -0,0 +1,317 @@
+package cli
+
+import (
+	"io"
+	"runtime/debug"
+	"strings"
+
+	"../fault"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// The router is intentionally tiny, it avoids third party cli libraries as the
+// design notes in `cmd/app-cli` ask. It resolves the command path, parses the
+// global and command flags (flags may appear anywhere on the command-line, a
+// command flag before the command name too) and renders the typed result
+// returned by the action in the selected output mode.
+////////////////////////////////////////////////////////////////////////////////
+
+type Router struct {
+	Name        string
+	Version     string
+	Description string
+	Commands    []*Command
+	Flags       []Flag
+	// NOTE: Panics are recovered and turned into an internal error, the stack
+	// trace goes to a crash report in this directory instead of the terminal.
+	Crashes string
+	// NOTE: Aliases are user defined, loaded from the `aliases` section of the
+	// config file; see alias.go.
+	Aliases map[string]string
+
+	Input  io.Reader
+	Output io.Writer
+	Error  io.Writer
+
+	shell bool
+}
+
+func New(name, version string, input io.Reader, output, errOutput io.Writer) *Router {
+	router := &Router{
+		Name:    name,
+		Version: version,
+		Input:   input,
+		Output:  output,
+		Error:   errOutput,
+		Flags: []Flag{
+			{Name: "output", Alias: "o", Default: "text", Description: "output mode: text, json, yaml, table or template=<go template>"},
+			{Name: "help", Alias: "h", Boolean: true, Description: "show help"},
+			{Name: "yes", Alias: "y", Boolean: true, Description: "assume yes for every confirmation"},
+		},
+	}
+	router.Commands = []*Command{
+		{
+			Name:        "help",
+			Usage:       "help [command]",
+			Description: "show help for a command",
+			Action:      func(context *Context) (interface{}, error) { return router.Help(context.Arguments...), nil },
+		},
+		{
+			Name:        "version",
+			Description: "show the version",
+			Action: func(context *Context) (interface{}, error) {
+				return VersionResult{Name: router.Name, Version: router.Version}, nil
+			},
+		},
+		router.completeCommand(),
+	}
+	return router
+}
+
+func (self *Router) Command(commands ...*Command) *Router {
+	self.Commands = append(self.Commands, commands...)
+	return self
+}
+
+func (self *Router) Flag(flags ...Flag) *Router {
+	self.Flags = append(self.Flags, flags...)
+	return self
+}
+
+func (self *Router) lookup(commands []*Command, name string) *Command {
+	for _, command := range commands {
+		if command.Is(name) {
+			return command
+		}
+	}
+	return nil
+}
+
+// Help returns the help for the command at the given path, or the top-level
+// help when no path is given.
+func (self *Router) Help(path ...string) Help {
+	help := Help{
+		Name:        self.Name,
+		Version:     self.Version,
+		Usage:       self.Name + " [flags] <command> [arguments]",
+		Description: self.Description,
+		Commands:    append(commandHelp(self.Commands), self.aliasHelp()...),
+		Flags:       flagHelp(self.Flags),
+	}
+	sortCommandHelp(help.Commands)
+	commands := self.Commands
+	for index, name := range path {
+		command := self.lookup(commands, name)
+		if command == nil {
+			break
+		}
+		usage := command.Usage
+		if usage == "" {
+			usage = strings.Join(path[:index+1], " ") + " [flags] [arguments]"
+		}
+		help = Help{
+			Name:        strings.Join(path[:index+1], " "),
+			Aliases:     command.Aliases,
+			Usage:       self.Name + " " + usage,
+			Description: command.Description,
+			Commands:    commandHelp(command.Subcommands),
+			Flags:       append(flagHelp(command.Flags), flagHelp(self.Flags)...),
+		}
+		commands = command.Subcommands
+	}
+	return help
+}
+
+// Run ////////////////////////////////////////////////////////////////////////
+func (self *Router) Run(arguments []string) (err error) {
+	mode := Mode{Format: Text}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fault.Crash(recovered, debug.Stack(), self.Crashes)
+			mode.RenderError(self.Output, self.Error, err)
+		}
+	}()
+
+	context, err := self.parse(arguments)
+	mode = context.Mode
+	if err != nil {
+		context.Mode.RenderError(self.Output, self.Error, err)
+		return err
+	}
+
+	var result interface{}
+	switch {
+	case context.Bool("help"), context.Command == nil:
+		result = self.Help(context.Path...)
+	case context.Command.Action == nil:
+		result = self.Help(context.Path...)
+	default:
+		result, err = context.Command.Action(context)
+	}
+	if err != nil {
+		context.Mode.RenderError(self.Output, self.Error, err)
+		return err
+	}
+	return context.Mode.Render(self.Output, result)
+}
+
+func (self *Router) parse(arguments []string) (*Context, error) {
+	context := &Context{
+		Router: self,
+		Flags:  make(map[string]string),
+		Input:  self.Input,
+		Output: self.Output,
+		Error:  self.Error,
+	}
+	arguments, err := self.expandAliases(arguments)
+	if err == nil {
+		err = self.parseArguments(context, arguments)
+	}
+	mode, modeErr := ParseMode(context.Flag("output"))
+	context.Mode = mode
+	if err == nil {
+		err = modeErr
+	}
+	return context, err
+}
+
+func (self *Router) parseArguments(context *Context, arguments []string) error {
+	commands := self.Commands
+	// NOTE: Flags are parsed against the command the arguments resolve to,
+	// wherever they appear.
+	resolved := self.resolve(arguments)
+	for index := 0; index < len(arguments); index++ {
+		argument := arguments[index]
+		switch {
+		case argument == "--":
+			context.Arguments = append(context.Arguments, arguments[index+1:]...)
+			return nil
+		case strings.HasPrefix(argument, "-") && argument != "-":
+			name, value, hasValue := splitFlag(argument)
+			flag := self.flag(resolved, name)
+			if flag == nil {
+				return fault.UsageError("usage.unknown_flag", "unknown flag %q", argument).
+					WithHint("%s", self.flagHint(resolved, name))
+			}
+			switch {
+			case flag.Boolean && !hasValue:
+				value = "true"
+			case !hasValue:
+				if index+1 == len(arguments) {
+					return fault.UsageError("usage.missing_value", "flag --%s requires a value", flag.Name)
+				}
+				index++
+				value = arguments[index]
+			}
+			context.Flags[flag.Name] = value
+		case len(context.Arguments) == 0 && self.lookup(commands, argument) != nil:
+			context.Command = self.lookup(commands, argument)
+			context.Path = append(context.Path, argument)
+			commands = context.Command.Subcommands
+		case context.Command == nil:
+			return fault.UsageError("usage.unknown_command", "unknown command %q", argument).
+				WithHint("%s", self.commandHint(commands, argument, true))
+		case len(context.Arguments) == 0 && context.Command.Action == nil:
+			return fault.UsageError("usage.unknown_command", "unknown command %q", strings.Join(append(context.Path, argument), " ")).
+				WithHint("%s", self.commandHint(commands, argument, false))
+		default:
+			context.Arguments = append(context.Arguments, argument)
+		}
+	}
+	return nil
+}
+
+// resolve returns the command the arguments name, skipping flags and their
+// values; a flag not yet known is looked up in the subcommands below to tell
+// whether it takes a value.
+func (self *Router) resolve(arguments []string) *Command {
+	var command *Command
+	commands := self.Commands
+	for index := 0; index < len(arguments); index++ {
+		argument := arguments[index]
+		switch {
+		case argument == "--":
+			return command
+		case strings.HasPrefix(argument, "-") && argument != "-":
+			name, _, hasValue := splitFlag(argument)
+			flag := self.flag(command, name)
+			if flag == nil {
+				flag = descendantFlag(commands, name)
+			}
+			if flag != nil && !flag.Boolean && !hasValue {
+				index++
+			}
+		default:
+			next := self.lookup(commands, argument)
+			if next == nil {
+				return command
+			}
+			command, commands = next, next.Subcommands
+		}
+	}
+	return command
+}
+
+func descendantFlag(commands []*Command, name string) *Flag {
+	for _, command := range commands {
+		if flag := command.Flag(name); flag != nil {
+			return flag
+		}
+		if flag := descendantFlag(command.Subcommands, name); flag != nil {
+			return flag
+		}
+	}
+	return nil
+}
+
+func (self *Router) flag(command *Command, name string) *Flag {
+	if command != nil {
+		if flag := command.Flag(name); flag != nil {
+			return flag
+		}
+	}
+	return lookupFlag(self.Flags, name)
+}
+
+func (self *Router) commandHint(commands []*Command, name string, topLevel bool) string {
+	var candidates []string
+	for _, command := range commands {
+		if !command.Hidden {
+			candidates = append(candidates, command.Name)
+			candidates = append(candidates, command.Aliases...)
+		}
+	}
+	if topLevel {
+		for alias := range self.Aliases {
+			candidates = append(candidates, alias)
+		}
+	}
+	if hint := didYouMean(suggest(name, candidates), ""); hint != "" {
+		return hint
+	}
+	return fault.Usage.Hint()
+}
+
+func (self *Router) flagHint(command *Command, name string) string {
+	var candidates []string
+	flags := self.Flags
+	if command != nil {
+		flags = append(append([]Flag{}, command.Flags...), flags...)
+	}
+	for _, flag := range flags {
+		candidates = append(candidates, flag.Name)
+	}
+	if hint := didYouMean(suggest(name, candidates), "--"); hint != "" {
+		return hint
+	}
+	return fault.Usage.Hint()
+}
+
+func splitFlag(argument string) (name, value string, hasValue bool) {
+	name = strings.TrimLeft(argument, "-")
+	if index := strings.Index(name, "="); 0 <= index {
+		return name[:index], name[index+1:], true
+	}
+	return name, "", false
+}
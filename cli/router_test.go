@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"../fault"
+)
+
+type noteResult struct {
+	Title string   `json:"title" yaml:"title"`
+	Tags  []string `json:"tags" yaml:"tags"`
+}
+
+type noteList []noteResult
+
+func testRouter() *Router {
+	router := New("app", "1.0.0", strings.NewReader(""), new(bytes.Buffer), new(bytes.Buffer))
+	router.Aliases = map[string]string{"ls": "notes list --output json"}
+	return router.Command(
+		&Command{
+			Name:  "install-service",
+			Flags: []Flag{{Name: "print", Boolean: true}, {Name: "name", Default: "app"}},
+			Action: func(context *Context) (interface{}, error) {
+				return context.Flag("name") + " print=" + context.Flag("print") + " " + strings.Join(context.Arguments, " "), nil
+			},
+		},
+		&Command{
+			Name: "notes",
+			Subcommands: []*Command{
+				{
+					Name:    "add",
+					Aliases: []string{"a"},
+					Flags:   []Flag{{Name: "tags"}},
+					Action: func(context *Context) (interface{}, error) {
+						if context.Argument(0) == "" {
+							return nil, fault.UsageError("usage.missing_title", "a title is required")
+						}
+						return noteResult{Title: context.Argument(0), Tags: strings.Split(context.Flag("tags"), ",")}, nil
+					},
+				},
+				{
+					Name: "list",
+					Action: func(context *Context) (interface{}, error) {
+						return noteList{{Title: "one", Tags: []string{"a"}}, {Title: "two"}}, nil
+					},
+				},
+				{
+					Name:   "crash",
+					Action: func(context *Context) (interface{}, error) { panic("boom") },
+				},
+			},
+		},
+	)
+}
+
+func run(t *testing.T, arguments ...string) (string, string, error) {
+	t.Helper()
+	router := testRouter()
+	router.Crashes = t.TempDir()
+	output, errOutput := new(bytes.Buffer), new(bytes.Buffer)
+	router.Output, router.Error = output, errOutput
+	err := router.Run(arguments)
+	return output.String(), errOutput.String(), err
+}
+
+func TestAliasHint(t *testing.T) {
+	router := testRouter()
+	router.Aliases["stat%d"] = "notes list"
+	errOutput := new(bytes.Buffer)
+	router.Error = errOutput
+	router.Run([]string{"stat%"})
+	if !strings.Contains(errOutput.String(), "did you mean stat%d?") {
+		t.Errorf("wrote %q, expected the alias in the hint as it is", errOutput)
+	}
+}
+
+func TestRouting(t *testing.T) {
+	tests := []struct {
+		arguments []string
+		output    string
+	}{
+		{[]string{"install-service"}, "app print= \n"},
+		{[]string{"install-service", "--print"}, "app print=true \n"},
+		{[]string{"--print", "install-service"}, "app print=true \n"},
+		{[]string{"--name", "web", "install-service", "x"}, "web print= x\n"},
+		{[]string{"--name=web", "--print", "install-service"}, "web print=true \n"},
+		{[]string{"install-service", "--", "--print"}, "app print= --print\n"},
+		{[]string{"notes", "add", "first", "--tags", "a,b", "--output", "template={{.Title}}:{{len .Tags}}"}, "first:2\n"},
+		{[]string{"--tags", "a", "notes", "a", "first", "-o", "template={{.Tags}}"}, "[a]\n"},
+		{[]string{"ls"}, "[\n  {\n    \"title\": \"one\",\n    \"tags\": [\n      \"a\"\n    ]\n  },\n  {\n    \"title\": \"two\",\n    \"tags\": null\n  }\n]\n"},
+		{[]string{"version"}, "app 1.0.0\n"},
+	}
+	for _, test := range tests {
+		output, errOutput, err := run(t, test.arguments...)
+		if err != nil {
+			t.Errorf("%q failed: %v %s", test.arguments, err, errOutput)
+			continue
+		}
+		if output != test.output {
+			t.Errorf("%q output %q, expected %q", test.arguments, output, test.output)
+		}
+	}
+}
+
+func TestRoutingErrors(t *testing.T) {
+	tests := []struct {
+		arguments []string
+		code      string
+		hint      string
+	}{
+		{[]string{"instal-service"}, "usage.unknown_command", "did you mean install-service?"},
+		{[]string{"notes", "ad"}, "usage.unknown_command", "did you mean a, add?"},
+		{[]string{"install-service", "--prnt"}, "usage.unknown_flag", "did you mean --print?"},
+		{[]string{"install-service", "--name"}, "usage.missing_value", ""},
+		{[]string{"notes", "add"}, "usage.missing_title", ""},
+		{[]string{"--output", "xml", "version"}, "usage.invalid_output", "use one of"},
+		{[]string{"notes", "crash"}, "internal.panic", ""},
+	}
+	for _, test := range tests {
+		_, errOutput, err := run(t, test.arguments...)
+		var classified *fault.Error
+		if !errors.As(err, &classified) {
+			t.Errorf("%q returned %v, expected a fault", test.arguments, err)
+			continue
+		}
+		if classified.Code != test.code {
+			t.Errorf("%q failed with %s, expected %s", test.arguments, classified.Code, test.code)
+		}
+		if !strings.Contains(errOutput, test.hint) {
+			t.Errorf("%q wrote %q, expected the hint %q", test.arguments, errOutput, test.hint)
+		}
+	}
+}
+
+func TestOutputModes(t *testing.T) {
+	tests := []struct {
+		mode   string
+		output string
+	}{
+		{"text", "TITLE  TAGS\none    a\ntwo    \n"},
+		{"table", "TITLE  TAGS\none    a\ntwo    \n"},
+		{"yaml", "- title: one\n  tags:\n  - a\n- title: two\n  tags: []\n"},
+		{"template={{range .}}{{.Title}} {{end}}", "one two \n"},
+	}
+	for _, test := range tests {
+		output, errOutput, err := run(t, "notes", "list", "--output", test.mode)
+		if err != nil {
+			t.Errorf("--output %s failed: %v %s", test.mode, err, errOutput)
+		} else if output != test.output {
+			t.Errorf("--output %s rendered %q, expected %q", test.mode, output, test.output)
+		}
+	}
+}
+
+func TestErrorOutput(t *testing.T) {
+	output, errOutput, _ := run(t, "notes", "add", "--output", "json")
+	if errOutput != "" || !strings.Contains(output, `"code": "usage.missing_title"`) || !strings.Contains(output, `"exit_code": 64`) {
+		t.Errorf("json error rendered %q on the output and %q on the error stream", output, errOutput)
+	}
+	output, errOutput, _ = run(t, "notes", "add")
+	if output != "" || errOutput != "error: a title is required\nhint: "+fault.Usage.Hint()+"\n" {
+		t.Errorf("text error rendered %q on the output and %q on the error stream", output, errOutput)
+	}
+}
+
+func TestPromptYes(t *testing.T) {
+	tests := []struct {
+		arguments []string
+		input     string
+		output    string
+	}{
+		{[]string{"wipe"}, "y\n", "true\n"},
+		{[]string{"wipe"}, "n\n", "false\n"},
+		{[]string{"wipe", "--yes"}, "", "true\n"},
+		{[]string{"-y", "wipe"}, "n\n", "true\n"},
+	}
+	for _, test := range tests {
+		output := new(bytes.Buffer)
+		router := New("app", "1.0.0", strings.NewReader(test.input), output, new(bytes.Buffer)).Command(&Command{
+			Name: "wipe",
+			Action: func(context *Context) (interface{}, error) {
+				return context.Prompt().Confirm("wipe everything?", false)
+			},
+		})
+		if err := router.Run(test.arguments); err != nil {
+			t.Errorf("%q failed: %v", test.arguments, err)
+		} else if output.String() != test.output {
+			t.Errorf("%q with input %q rendered %q, expected %q", test.arguments, test.input, output.String(), test.output)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"../fault"
+	"../terminal"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// The shell keeps the application (loaded config, open stores) alive and runs
+// one command-line at a time through the same router, so every command, alias
+// and output mode works exactly as it does from the system shell. A line ending
+// in a backslash, or with an unterminated quote, continues on the next line.
+////////////////////////////////////////////////////////////////////////////////
+
+// Shell returns the `shell` command, history is persisted to the given file.
+func (self *Router) Shell(history string) *Command {
+	return &Command{
+		Name:        "shell",
+		Description: "run commands interactively",
+		Action: func(context *Context) (interface{}, error) {
+			if self.shell {
+				return nil, fault.UsageError("usage.nested_shell", "already running a shell")
+			}
+			self.shell = true
+			defer func() { self.shell = false }()
+
+			editor := terminal.NewEditor(context.Input, context.Error)
+			editor.History = loadHistory(history)
+			editor.Complete = func(before string) []string {
+				words, _ := Split(before)
+				current := ""
+				if 0 < len(words) && !strings.HasSuffix(before, " ") {
+					current, words = words[len(words)-1], words[:len(words)-1]
+				}
+				return self.Complete(words, current)
+			}
+
+			prompt := self.Name + "> "
+			for {
+				line, err := readEntry(editor, prompt)
+				switch {
+				case err == terminal.ErrInterrupted:
+					continue
+				case err == io.EOF:
+					return nil, nil
+				case err != nil:
+					return nil, err
+				}
+				arguments, err := Split(line)
+				if err != nil || len(arguments) == 0 {
+					continue
+				}
+				editor.Remember(line)
+				appendHistory(history, line)
+				if arguments[0] == "exit" || arguments[0] == "quit" {
+					return nil, nil
+				}
+				// NOTE: Errors are already rendered by Run, the shell carries on.
+				self.Run(arguments)
+			}
+		},
+	}
+}
+
+// readEntry reads a full entry, continuing on the next line after a trailing
+// backslash or inside an open quote.
+func readEntry(editor *terminal.Editor, prompt string) (string, error) {
+	var entry strings.Builder
+	for {
+		line, err := editor.ReadLine(prompt)
+		if err != nil {
+			if err == io.EOF && entry.Len() != 0 {
+				err = nil
+				line = ""
+			} else {
+				return "", err
+			}
+		}
+		if strings.HasSuffix(line, "\\") && !strings.HasSuffix(line, "\\\\") {
+			entry.WriteString(strings.TrimSuffix(line, "\\") + " ")
+			prompt = strings.Repeat(".", len(prompt)-2) + "> "
+			continue
+		}
+		entry.WriteString(line)
+		if _, err := Split(entry.String()); err == ErrUnterminated && line != "" {
+			entry.WriteString("\n")
+			prompt = strings.Repeat(".", len(prompt)-2) + "> "
+			continue
+		}
+		return entry.String(), nil
+	}
+}
+
+// History ////////////////////////////////////////////////////////////////////
+// NOTE: One entry per line, newlines of multi-line entries are escaped.
+var historyEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n")
+
+func loadHistory(path string) (history []string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		history = append(history, unescapeHistory(scanner.Text()))
+	}
+	if 1000 < len(history) {
+		history = history[len(history)-1000:]
+	}
+	return history
+}
+
+func appendHistory(path, line string) {
+	if path == "" {
+		return
+	}
+	os.MkdirAll(filepath.Dir(path), 0700)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.WriteString(historyEscaper.Replace(line) + "\n")
+}
+
+func unescapeHistory(line string) string {
+	var unescaped strings.Builder
+	for index := 0; index < len(line); index++ {
+		if line[index] == '\\' && index+1 < len(line) {
+			index++
+			if line[index] == 'n' {
+				unescaped.WriteByte('\n')
+				continue
+			}
+		}
+		unescaped.WriteByte(line[index])
+	}
+	return unescaped.String()
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		line      string
+		arguments []string
+		err       error
+	}{
+		{"notes add first", []string{"notes", "add", "first"}, nil},
+		{"  notes\tadd  ", []string{"notes", "add"}, nil},
+		{`notes add "a title" 'it''s'`, []string{"notes", "add", "a title", "its"}, nil},
+		{`say "\"quoted\"" 'back\slash' a\ b`, []string{"say", `"quoted"`, `back\slash`, "a b"}, nil},
+		{`empty "" ''`, []string{"empty", "", ""}, nil},
+		{`open "quote`, nil, ErrUnterminated},
+		{`trailing \`, []string{"trailing"}, ErrUnterminated},
+		{"", nil, nil},
+	}
+	for _, test := range tests {
+		arguments, err := Split(test.line)
+		if err != test.err {
+			t.Errorf("%q: error %v, expected %v", test.line, err, test.err)
+		}
+		if test.err == nil && !reflect.DeepEqual(arguments, test.arguments) {
+			t.Errorf("%q split into %q, expected %q", test.line, arguments, test.arguments)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	router := testRouter()
+	tests := []struct {
+		words      []string
+		current    string
+		candidates []string
+	}{
+		{nil, "no", []string{"notes"}},
+		{nil, "", []string{"help", "install-service", "ls", "notes", "version"}},
+		{[]string{"notes"}, "", []string{"a", "add", "crash", "list"}},
+		{[]string{"notes", "add"}, "--t", []string{"--tags"}},
+		{[]string{"install-service"}, "--", []string{"--help", "--name", "--output", "--print", "--yes"}},
+		{[]string{"--output"}, "y", []string{"yaml"}},
+		{[]string{"install-service", "--name"}, "", nil},
+		{[]string{"notes", "add", "--"}, "", nil},
+	}
+	for _, test := range tests {
+		if candidates := router.Complete(test.words, test.current); !reflect.DeepEqual(candidates, test.candidates) {
+			t.Errorf("%q %q completed to %q, expected %q", test.words, test.current, candidates, test.candidates)
+		}
+	}
+}
+
+func TestShell(t *testing.T) {
+	history := filepath.Join(t.TempDir(), "history")
+	script := strings.Join([]string{
+		`notes add "a title" \`,
+		`--output template={{.Title}}`,
+		`notes add 'two`,
+		`lines' -o template={{.Title}}`,
+		`unknown`,
+		``,
+		`exit`,
+		`version`,
+	}, "\n")
+	output, errOutput := new(bytes.Buffer), new(bytes.Buffer)
+	router := testRouter()
+	router.Input, router.Output, router.Error = strings.NewReader(script), output, errOutput
+	router.Command(router.Shell(history))
+	if err := router.Run([]string{"shell"}); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "a title\ntwo\nlines\n" {
+		t.Errorf("the shell rendered %q", output.String())
+	}
+	if !strings.Contains(errOutput.String(), `error: unknown command "unknown"`) {
+		t.Errorf("the shell did not report the unknown command: %q", errOutput.String())
+	}
+	data, err := os.ReadFile(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "notes add \"a title\"  --output template={{.Title}}\nnotes add 'two\\nlines' -o template={{.Title}}\nunknown\nexit\n"
+	if string(data) != expected {
+		t.Errorf("history %q, expected %q", data, expected)
+	}
+	if loaded := loadHistory(history); len(loaded) != 4 || loaded[1] != "notes add 'two\nlines' -o template={{.Title}}" {
+		t.Errorf("loaded history %q", loaded)
+	}
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"strings"
+
+	"../fault"
+)
+
+var ErrUnterminated = fault.UsageError("usage.unterminated_quote", "unterminated quote or escape")
+
+// Split breaks a command line into arguments the way a shell would for plain
+// words, single and double quotes and backslash escapes; it does not expand
+// variables or globs.
+func Split(line string) ([]string, error) {
+	var arguments []string
+	var current strings.Builder
+	var quote rune
+	inArgument, escaped := false, false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArgument = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote, inArgument = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArgument {
+				arguments = append(arguments, current.String())
+				current.Reset()
+				inArgument = false
+			}
+		default:
+			current.WriteRune(r)
+			inArgument = true
+		}
+	}
+	if quote != 0 || escaped {
+		return arguments, ErrUnterminated
+	}
+	if inArgument {
+		arguments = append(arguments, current.String())
+	}
+	return arguments, nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+)
+
+// NOTE: Suggestions are ranked by edit distance; a candidate is close enough
+// when at most a third of the typed name (and never less than two characters)
+// would have to change, or when the typed name is a prefix of the candidate.
+func suggest(name string, candidates []string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	limit := len(name) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	var suggestions []suggestion
+	seen := make(map[string]bool)
+	for _, candidate := range candidates {
+		if seen[candidate] || candidate == name {
+			continue
+		}
+		seen[candidate] = true
+		distance := levenshtein(name, candidate)
+		if distance <= limit || (2 <= len(name) && strings.HasPrefix(candidate, name)) {
+			suggestions = append(suggestions, suggestion{name: candidate, distance: distance})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].distance == suggestions[j].distance {
+			return suggestions[i].name < suggestions[j].name
+		}
+		return suggestions[i].distance < suggestions[j].distance
+	})
+	var names []string
+	for index := 0; index < len(suggestions) && index < 3; index++ {
+		names = append(names, suggestions[index].name)
+	}
+	return names
+}
+
+func levenshtein(a, b string) int {
+	source, target := []rune(a), []rune(b)
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(target)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+func didYouMean(suggestions []string, prefix string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return "did you mean " + prefix + strings.Join(suggestions, ", "+prefix) + "?"
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	application "../.."
+	"../../binding"
+	"../../cli"
+)
+
+// actionsCommand lists the actions of the library, each is also a command of
+// its own, see binding/cli.go.
+func actionsCommand(app *application.Application) *cli.Command {
+	return &cli.Command{
+		Name:        "actions",
+		Description: "list the actions, with their usage and HTTP method",
+		Action: func(context *cli.Context) (interface{}, error) {
+			return binding.Describe(app.Actions, application.ActionsPrefix), nil
+		},
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	application "../.."
+	"../../cli"
+	"../../config"
+	"../../fault"
+	"../../log"
+	"../../metrics"
+	"../../rpc"
+)
+
+// call connects to the daemon through its control socket, found in the
+// runtime directory, and invokes a single method.
+func call(app *application.Application, method string, params, result interface{}) error {
+	client, err := rpc.Connect(app.Name)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Call(context.Background(), method, params, result)
+}
+
+func statusCommand(app *application.Application) *cli.Command {
+	return &cli.Command{
+		Name:        "status",
+		Description: "show the daemon status, health, uptime, version and pid",
+		Action: func(context *cli.Context) (interface{}, error) {
+			var status application.Status
+			err := call(app, "status", nil, &status)
+			return status, err
+		},
+	}
+}
+
+func daemonCommand(app *application.Application) *cli.Command {
+	return &cli.Command{
+		Name:        "daemon",
+		Description: "control the running daemon",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "version",
+				Description: "show the daemon version",
+				Action: func(context *cli.Context) (interface{}, error) {
+					var version application.VersionResult
+					err := call(app, "version", nil, &version)
+					return version, err
+				},
+			},
+			{
+				Name:        "reload",
+				Description: "reload the daemon config",
+				Action: func(context *cli.Context) (interface{}, error) {
+					var result application.ControlResult
+					err := call(app, "reload", nil, &result)
+					return result, err
+				},
+			},
+			{
+				Name:        "stop",
+				Description: "stop the daemon gracefully",
+				Action: func(context *cli.Context) (interface{}, error) {
+					var result application.ControlResult
+					err := call(app, "stop", nil, &result)
+					return result, err
+				},
+			},
+			{
+				Name:        "upgrade",
+				Description: "restart the daemon on the new binary without dropping connections",
+				Action: func(context *cli.Context) (interface{}, error) {
+					var result application.UpgradeResult
+					err := call(app, "upgrade", nil, &result)
+					return result, err
+				},
+			},
+			{
+				Name:        "config",
+				Description: "dump the daemon's effective config",
+				Action: func(context *cli.Context) (interface{}, error) {
+					settings := config.Default()
+					err := call(app, "config", nil, settings)
+					return settings, err
+				},
+			},
+			{
+				Name:        "metrics",
+				Description: "show the daemon metrics, in the Prometheus text format",
+				Action: func(context *cli.Context) (interface{}, error) {
+					var snapshot metrics.Snapshot
+					err := call(app, "metrics", nil, &snapshot)
+					return snapshot, err
+				},
+			},
+			{
+				Name:        "log",
+				Description: "inspect and control the daemon's logging",
+				Subcommands: []*cli.Command{
+					{
+						Name:        "level",
+						Usage:       "daemon log level [debug|info|warn|error] [--component name]",
+						Description: "show the log levels, or set the level of the daemon or a component",
+						Flags: []cli.Flag{
+							{Name: "component", Alias: "c", Description: "component to set the level of"},
+						},
+						Action: func(context *cli.Context) (interface{}, error) {
+							params := application.LogLevelParams{Component: context.Flag("component"), Level: context.Argument(0)}
+							var levels log.Levels
+							err := call(app, "log.level", params, &levels)
+							return levels, err
+						},
+					},
+					{
+						Name:        "reopen",
+						Description: "reopen the log files after an outside rotation, as SIGUSR1 does (SIGUSR2 upgrades)",
+						Action: func(context *cli.Context) (interface{}, error) {
+							var result application.ControlResult
+							err := call(app, "log.reopen", nil, &result)
+							return result, err
+						},
+					},
+				},
+			},
+			{
+				Name:        "call",
+				Usage:       "daemon call <method> [json params]",
+				Description: "call any control method, including application methods",
+				Action: func(context *cli.Context) (interface{}, error) {
+					if len(context.Arguments) == 0 {
+						return nil, fault.UsageError("usage.missing_argument", "a method name is required").
+							WithHint("list the methods with: daemon call rpc.methods")
+					}
+					var params interface{}
+					if 1 < len(context.Arguments) {
+						params = json.RawMessage(context.Arguments[1])
+						if !json.Valid(params.(json.RawMessage)) {
+							return nil, fault.UsageError("usage.invalid_argument", "params must be valid json")
+						}
+					}
+					var result interface{}
+					err := call(app, context.Arguments[0], params, &result)
+					return result, err
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+
+	application "../.."
+	"../../cli"
+	"../../event"
+	"../../rpc"
+)
+
+// eventsCommand streams the daemon's events until interrupted, rendering
+// each one as it arrives in the output mode.
+func eventsCommand(app *application.Application) *cli.Command {
+	return &cli.Command{
+		Name:        "events",
+		Usage:       "events [pattern]",
+		Description: "stream the daemon's events, optionally matching a pattern such as jobs.*.failed",
+		Flags: []cli.Flag{
+			{Name: "queue", Description: "events buffered for this client before they are dropped", Default: "256"},
+		},
+		Action: func(context *cli.Context) (interface{}, error) {
+			client, err := rpc.Connect(app.Name)
+			if err != nil {
+				return nil, err
+			}
+			defer client.Close()
+
+			ctx, stop := interruptible()
+			defer stop()
+			var result application.EventsResult
+			err = client.Stream(ctx, "events", application.EventsParams{Pattern: context.Argument(0), Queue: context.Int("queue")}, func(data json.RawMessage) {
+				var received event.Event
+				if json.Unmarshal(data, &received) == nil {
+					context.Mode.Render(context.Output, received)
+				}
+			}, &result)
+			if ctx.Err() != nil || (err == nil && result.Dropped == 0) {
+				return nil, err
+			}
+			return result, err
+		},
+	}
+}
+
+// interruptible returns a context done on ^C, which ends a stream cleanly.
+func interruptible() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	application "../.."
+	"../../cli"
+	"../../fault"
+	"../../scheduler"
+)
+
+// jobsCommand shows the scheduled jobs of the daemon; when the daemon is not
+// running the history in the state directory is read instead.
+func jobsCommand(app *application.Application) *cli.Command {
+	return &cli.Command{
+		Name:        "jobs",
+		Description: "show the scheduled jobs, with their last run, duration and error",
+		Action: func(context *cli.Context) (interface{}, error) {
+			var statuses scheduler.Statuses
+			err := call(app, "jobs", nil, &statuses)
+			if fault.ClassOf(err) == fault.Unavailable {
+				return scheduler.FromHistory(app.Jobs.History), nil
+			}
+			return statuses, err
+		},
+		Subcommands: []*cli.Command{
+			{
+				Name:        "history",
+				Usage:       "jobs history <job>",
+				Description: "show the latest runs of a job",
+				Action: func(context *cli.Context) (interface{}, error) {
+					if len(context.Arguments) == 0 {
+						return nil, fault.UsageError("usage.missing_argument", "a job name is required").
+							WithHint("list the jobs with: jobs")
+					}
+					var runs scheduler.Runs
+					err := call(app, "jobs.history", application.JobParams{Job: context.Argument(0)}, &runs)
+					if fault.ClassOf(err) == fault.Unavailable {
+						return scheduler.Runs(app.Jobs.History.Runs(context.Argument(0))), nil
+					}
+					return runs, err
+				},
+			},
+			{
+				Name:        "run",
+				Usage:       "jobs run <job>",
+				Description: "run a job now, outside its schedule",
+				Action: func(context *cli.Context) (interface{}, error) {
+					if len(context.Arguments) == 0 {
+						return nil, fault.UsageError("usage.missing_argument", "a job name is required").
+							WithHint("list the jobs with: jobs")
+					}
+					var result application.ControlResult
+					err := call(app, "jobs.run", application.JobParams{Job: context.Argument(0)}, &result)
+					return result, err
+				},
+			},
+		},
+	}
+}
@@ -1,20 +1,51 @@
 package main
 
 import (
-	"fmt"
+	"os"
+
+	application "../.."
+	"../../binding"
+	"../../cli"
+	"../../fault"
 )
 
 func main() {
-	fmt.Println("application-cli")
-	fmt.Println("===============")
-	fmt.Println("application command-line interface template; intended to be")
-	fmt.Println("used with the library. all applications: cli tools, GUI tools")
-	fmt.Println("and even web applications should be built as a Go library.\n")
-	fmt.Println("after the go library is written, it is then called into the")
-	fmt.Println("UI and only code related to presenting the library through")
-	fmt.Println("the specified UI is in this file. so this file contains all")
-	fmt.Println("the features accesible through the command-line interface.\n")
-	fmt.Println("ansi coloring, terminal printing, and so on...")
+	app := application.Initialize("app", application.Version{Major: 0, Minor: 1, Patch: 0})
+
+	router := cli.New("app-cli", app.Version.String(), app.IO.Input, app.IO.Output, app.IO.Error)
+	router.Description = "application command-line interface template; all applications: cli\n" +
+		"tools, GUI tools and even web applications should be built as a Go\n" +
+		"library, and only the code presenting the library lives here."
+	router.Crashes = app.Crashes()
+
+	// a broken config file must not stop the cli, the defaults are used and
+	// the problem is reported.
+	if err := app.LoadConfig(); err != nil {
+		cli.Mode{}.RenderError(app.IO.Output, app.IO.Error, err)
+	}
+	router.Aliases = app.Settings.Aliases
+
+	// `app-cli shell` keeps this application loaded and runs commands line by
+	// line, with history kept in the state directory.
+	router.Command(router.Shell(string(app.State.Path) + "/history"))
+
+	// the daemon is controlled through its control socket, see daemon.go,
+	// jobs.go and events.go.
+	router.Command(statusCommand(app), daemonCommand(app), jobsCommand(app), eventsCommand(app))
+
+	// stored models are listed, watched and upgraded to the current schema
+	// versions, see models.go.
+	router.Command(modelsCommand(app), migrateCommand(app))
+
+	// every action registered in app.Actions is a command of its own, named
+	// by its dotted name, see actions.go.
+	router.Command(actionsCommand(app))
+	commands, err := binding.Commands(app.Actions, router.Commands)
+	if err != nil {
+		cli.Mode{}.RenderError(app.IO.Output, app.IO.Error, err)
+		os.Exit(fault.ExitCode(err))
+	}
+	router.Command(commands...)
 
 	// step 1) load config values
 	// env, _ := env.Parse(os.Env())
@@ -73,20 +104,16 @@ func main() {
 	// or preferably establish command hooks like a web application, and
 	// run and then pass in the input vaues
 
-	// switch {
-	// case command.Is(xAction):
-	//   result, err := app.Action()
-	//   if err != nil {
-	//   	app.ErrorOutput(err)
-	//      os.Exit(1)
-	//   } elsif result == x {
-	// 		app.Output("result was x!")
-	//   } else {
-	//      app.Output
-	// }
-	//
-	// default: // no command specified in a cli tool, falls back to help
-	//   app.Output(app.Help())
+	// every command returns a typed value instead of printing, the router
+	// renders it in the mode selected by the global `--output` flag:
+	//
+	//   --output text|json|yaml|table|template=<go template>
+	//
+	// so scripts depend on the stable field names of the result types, never
+	// on the wording of the human output. errors are rendered as structured
+	// objects in the machine-readable modes.
+	//
+	// no command specified in a cli tool, falls back to help.
 	//
 	// Or if a flag is specifying daemon mode. Have a method that holds open
 	// until a signal is given.
@@ -97,4 +124,8 @@ func main() {
 	// but if it does not, then have all the files in this folder, and avoid
 	// special cli tool libraries when possible, they should still be in the
 	// primary library not in this folder.
+
+	// errors are classified (usage, config, unavailable, permission, io,
+	// internal) and the exit code follows `sysexits.h`.
+	os.Exit(fault.ExitCode(router.Run(os.Args[1:])))
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	application "../.."
+	"../../cli"
+	"../../fault"
+	"../../model"
+	"../../rpc"
+)
+
+// Documents are models listed by collection, whatever their type.
+type Documents []model.Document
+
+func (self Documents) String() string {
+	var output strings.Builder
+	table := tabwriter.NewWriter(&output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tREVISION\tUPDATED")
+	for _, document := range self {
+		fmt.Fprintf(table, "%v\t%v\t%v\n", document["id"], document["revision"], document["updated"])
+	}
+	table.Flush()
+	return output.String()
+}
+
+// modelsCommand reads the stored models, those a query selects with `--where`
+// and the like, see model.Query; `--watch` keeps the output live with their
+// changes, streamed by the daemon or, when it is not running, read from the
+// store.
+func modelsCommand(app *application.Application) *cli.Command {
+	return &cli.Command{
+		Name:        "models",
+		Description: "read the stored models",
+		Subcommands: []*cli.Command{
+			{
+				Name:        "list",
+				Usage:       "models list <collection> [--where predicates] [--sort fields] [--limit n] [--offset n] [--after cursor] [--watch] [--cursor n]",
+				Description: "list the models of a collection, then their changes with --watch",
+				Flags: []cli.Flag{
+					{Name: "where", Description: "predicates, comma separated, e.g. title~=draft,revision>1"},
+					{Name: "sort", Description: "fields to sort by, comma separated, -field for descending"},
+					{Name: "limit", Description: "the most models in a page"},
+					{Name: "offset", Description: "models to skip"},
+					{Name: "after", Description: "the next cursor of the page before"},
+					{Name: "watch", Description: "stream the changes after the list until interrupted", Boolean: true},
+					{Name: "cursor", Description: "with --watch, skip the list and resume after this change"},
+				},
+				Action: func(context *cli.Context) (interface{}, error) {
+					collection := context.Argument(0)
+					if !model.ValidID(collection) {
+						return nil, fault.UsageError("usage.invalid_collection", "invalid collection %q", collection).
+							WithHint("name a collection, such as: models list notes")
+					}
+					if !context.Bool("watch") {
+						query, err := queryOf(context)
+						if err != nil {
+							return nil, err
+						}
+						return model.Find(app.Store, collection, query)
+					}
+					var cursor uint64
+					if context.IsSet("cursor") {
+						parsed, err := strconv.ParseUint(context.Flag("cursor"), 10, 64)
+						if err != nil {
+							return nil, fault.Wrap(err, fault.Usage, "usage.invalid_flag", "invalid --cursor")
+						}
+						cursor = parsed
+					} else {
+						// NOTE: The cursor is read before the list, a change in
+						// between is shown twice rather than missed.
+						latest, err := model.Latest(app.Store, collection)
+						if err != nil {
+							return nil, err
+						}
+						documents, err := model.Documents(app.Store, collection)
+						if err != nil {
+							return nil, err
+						}
+						cursor = latest
+						context.Mode.Render(context.Output, Documents(documents))
+					}
+					return watch(app, context, collection, cursor)
+				},
+			},
+		},
+	}
+}
+
+// queryOf is the query the flags of `models list` describe.
+func queryOf(context *cli.Context) (model.Query, error) {
+	query := model.Query{Where: split(context.Flag("where")), Sort: split(context.Flag("sort")), After: context.Flag("after")}
+	for flag, target := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if !context.IsSet(flag) {
+			continue
+		}
+		parsed, err := strconv.Atoi(context.Flag(flag))
+		if err != nil || parsed < 0 {
+			return query, fault.UsageError("usage.invalid_flag", "invalid --%s %q", flag, context.Flag(flag)).
+				WithHint("pass a number, 0 or more")
+		}
+		*target = parsed
+	}
+	return query, nil
+}
+
+func split(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// watch renders the changes of a collection as they arrive, until ^C.
+func watch(app *application.Application, context *cli.Context, collection string, cursor uint64) (interface{}, error) {
+	ctx, stop := interruptible()
+	defer stop()
+	result := application.WatchResult{Cursor: cursor}
+	client, err := rpc.Connect(app.Name)
+	if fault.ClassOf(err) == fault.Unavailable {
+		err = model.Watch(ctx, app.Store, collection, cursor, func(change model.Change) error {
+			result.Cursor = change.Cursor
+			return context.Mode.Render(context.Output, change)
+		})
+	} else if err == nil {
+		defer client.Close()
+		err = client.Stream(ctx, "watch", application.WatchParams{Collection: collection, Cursor: &cursor}, func(data json.RawMessage) {
+			var change model.Change
+			if json.Unmarshal(data, &change) == nil {
+				result.Cursor = change.Cursor
+				context.Mode.Render(context.Output, change)
+			}
+		}, &result)
+	}
+	if ctx.Err() != nil {
+		return result, nil
+	}
+	return result, err
+}
+
+// migrateCommand upgrades the stored documents of the registered models to
+// their current schema versions. The store is locked per collection, so it is
+// safe while the daemon runs.
+func migrateCommand(app *application.Application) *cli.Command {
+	return &cli.Command{
+		Name:        "migrate",
+		Usage:       "migrate [collection...] [--dry-run]",
+		Description: "upgrade stored models to the current schema versions",
+		Flags: []cli.Flag{
+			{Name: "dry-run", Description: "report what each upgrade step would touch, without writing", Boolean: true},
+		},
+		Action: func(context *cli.Context) (interface{}, error) {
+			return app.Models.Migrate(context.Bool("dry-run"), context.Arguments...)
+		},
+	}
+}
@@ -1,25 +1,77 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	application "../.."
+	"../../binding"
+	"../../cli"
+	"../../fault"
+	"../../scheduler"
 )
 
 func main() {
-	fmt.Println("app daemon")
-	fmt.Println("==========")
-	fmt.Println("review cli tool, it will be similar if its a typical system")
-	fmt.Println("service.\n")
+	app := application.Initialize("app", application.Version{Major: 0, Minor: 1, Patch: 0})
 
 	//
 	// so load the config values:
 	//    [ env => flags => file => default/userinput ]
+	//
+	//  a broken config file does not stop the daemon, the defaults are used
+	//  and the problem is reported.
+	//
+	if err := app.LoadConfig(); err != nil {
+		cli.Mode{}.RenderError(app.IO.Output, app.IO.Error, err)
+	}
+
+	//
+	//  without a command the daemon runs in the foreground; packaging
+	//  helpers such as install-service are commands, see service.go.
+	//
+	router := cli.New("appd", app.Version.String(), app.IO.Input, app.IO.Output, app.IO.Error)
+	router.Description = "application daemon; runs in the foreground when no command is given."
+	router.Crashes = app.Crashes()
+	router.Command(&cli.Command{
+		Name:        "run",
+		Description: "run the daemon in the foreground",
+		Action:      func(*cli.Context) (interface{}, error) { return nil, run(app) },
+	}, installServiceCommand(app))
+
+	arguments := os.Args[1:]
+	if len(arguments) == 0 {
+		arguments = []string{"run"}
+	}
+	os.Exit(fault.ExitCode(router.Run(arguments)))
+}
+
+func run(app *application.Application) error {
+	//
+	//  diagnostics go to the sinks in the log config: stderr, a rotated file
+	//  in the state directory and syslog. SIGUSR1 reopens the files.
+	//
+	if err := app.OpenLog(); err != nil {
+		app.Log.Warn("logging is degraded", "error", err)
+	}
+	//
+	//  the control socket in the runtime directory is how app-cli talks to
+	//  the daemon (status, reload, stop, version, config).
+	//
+	if _, err := app.ServeControl(); err != nil {
+		return err
+	}
+
 	//
 	//  then use this to load the daemon using whatever server
 	//  that is expected.
 	//
 	//  serviceApp, _ := app.Server.TCP("localhost", 8080)
-	//
-	//  rcpClient, _ := app.Server.TCP("10.10.10.10", 3000)
+	//  serviceApp.Serve(func(conn net.Conn) { ... })
 	//
 	//  then maybe copy data obtained via the rpc and present it over
 	// the service connection, or the other way we are providing the
@@ -27,14 +79,87 @@ func main() {
 	// but the logic for this part of the service should use the library
 	// and the daemon logic should be execlusively here.
 	//
-
-	fmt.Println("if it is a web application, then this is where we load config")
-	fmt.Println("values for loading the web server: the host/address, and the")
-	fmt.Println("port.")
-	//
-	//
+	// if it is a web application, then this is where we load config
+	// values for loading the web server come from the "web" listener in
+	// the config (server.listeners). if the port is taken the next free
+	// port is used, the effective address is in app.Listeners. under
+	// socket activation the socket passed for "web" is used instead.
 	//
-	// webApp, _ := app.Server.HTTP("localhost", 8080)
-	// webApp.Start()
+	// /healthz and /readyz report the checks registered in app.Health,
+	// /metrics the metrics in app.Metrics when metrics.http is set, and the
+	// actions in app.Actions are routes under /api.
 	//
+	mux := http.NewServeMux()
+	app.Health.Mount(mux)
+	if app.Settings.Metrics.HTTP {
+		app.Metrics.Mount(mux, app.Settings.Metrics.Path)
+	}
+	binding.Mount(app.Actions, mux, application.ActionsPrefix)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s\n", app.Name, app.Version)
+	})
+	listener, err := app.Server.Configured("web")
+	if err != nil {
+		return err
+	}
+	webApp, err := app.Server.Handle(listener, mux)
+	if err != nil {
+		return err
+	}
+	webApp.Start()
+	app.Log.Named("server").Info("listening", "listener", listener.Name, "url", "http://"+webApp.Address())
+
+	// periodic work is scheduled on app.Jobs, with cron expressions or
+	// `@every <duration>`; runs are kept in the state directory and shown by
+	// `app-cli jobs`. this one removes crash reports older than a month.
+	err = app.Jobs.Add(scheduler.Job{
+		Name:     "cleanup",
+		Schedule: "@daily",
+		Jitter:   10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return removeOlder(app.Crashes(), 30*24*time.Hour)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// the model store and the job history are append-only logs, compacted
+	// online once they are mostly overwritten records.
+	err = app.Jobs.Add(scheduler.Job{
+		Name:     "compact",
+		Schedule: "@hourly",
+		Jitter:   5 * time.Minute,
+		Run:      func(ctx context.Context) error { return app.Compact() },
+	})
+	if err != nil {
+		return err
+	}
+
+	// everything is bound: write the PID file, start the jobs and, if this
+	// process was started by an upgrade (SIGUSR2 or `app-cli daemon
+	// upgrade`), let the old process know it can drain and exit.
+	if err := app.Ready(); err != nil {
+		cli.Mode{}.RenderError(app.IO.Output, app.IO.Error, err)
+	}
+
+	// hold open until SIGINT/SIGTERM, then drain connections and exit.
+	return app.Run()
+}
+
+// removeOlder removes the files of a directory last modified before the age.
+func removeOlder(directory string, age time.Duration) error {
+	entries, err := ioutil.ReadDir(directory)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && time.Since(entry.ModTime()) > age {
+			if err := os.Remove(filepath.Join(directory, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	application "../.."
+	"../../cli"
+	"../../fault"
+	"../../service"
+)
+
+// InstallResult lists the service files written by install-service.
+type InstallResult struct {
+	Init  service.Init `yaml:"init" json:"init"`
+	Files []string     `yaml:"files" json:"files"`
+}
+
+func installServiceCommand(app *application.Application) *cli.Command {
+	return &cli.Command{
+		Name:        "install-service",
+		Usage:       "install-service [--init systemd|openrc|runit] [--print]",
+		Description: "generate and install service files for the daemon",
+		Flags: []cli.Flag{
+			{Name: "init", Description: "init system: systemd, openrc or runit", Default: string(service.Systemd)},
+			{Name: "exec", Description: "path of the installed daemon binary", Default: "/usr/local/bin/" + app.Name + "d"},
+			{Name: "root", Description: "install relative to this directory", Default: "/"},
+			{Name: "print", Description: "print the files instead of installing them", Boolean: true},
+		},
+		Action: func(context *cli.Context) (interface{}, error) {
+			init, err := service.ParseInit(context.Flag("init"))
+			if err != nil {
+				return nil, fault.Wrap(err, fault.Usage, "usage.invalid_flag", "invalid --init").
+					WithHint("use systemd, openrc or runit")
+			}
+			files := app.Unit(context.Flag("exec")).Files(init)
+			if context.Bool("print") {
+				return files, nil
+			}
+			result := InstallResult{Init: init}
+			for _, file := range files {
+				path := filepath.Join(context.Flag("root"), file.Path)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					return result, err
+				}
+				if err := ioutil.WriteFile(path, []byte(file.Content), os.FileMode(file.Mode)); err != nil {
+					return result, err
+				}
+				result.Files = append(result.Files, path)
+			}
+			return result, nil
+		},
+	}
+}
+
+func (self InstallResult) String() string {
+	output := "installed " + string(self.Init) + " service files:"
+	for _, file := range self.Files {
+		output += "\n  " + file
+	}
+	return output
+}
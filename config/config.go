@@ -6,6 +6,11 @@ import (
 	"path/filepath"
 
 	yaml "gopkg.in/yaml.v2"
+
+	"../log"
+	"../metrics"
+	"../server"
+	"../validate"
 )
 
 type Environment int
@@ -28,7 +33,32 @@ func (self Environment) String() string {
 }
 
 type Config struct {
-	Environment string `yaml:"environment"`
+	Environment string `yaml:"environment" json:"environment" validate:"enum=development|testing|production"`
+	// NOTE: Aliases expand to full command-lines, git-style:
+	//   aliases:
+	//     ls: list --output table
+	Aliases map[string]string `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+	Server  server.Config     `yaml:"server" json:"server"`
+	Log     log.Config        `yaml:"log" json:"log"`
+	Metrics metrics.Config    `yaml:"metrics" json:"metrics"`
+}
+
+func Default() *Config {
+	return &Config{
+		Environment: Development.String(),
+		Aliases:     map[string]string{},
+		Server:      server.DefaultConfig(),
+		Log:         log.DefaultConfig(),
+		Metrics:     metrics.DefaultConfig(),
+	}
+}
+
+func (self *Config) String() string {
+	data, err := yaml.Marshal(self)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
 }
 
 func LoadConfig(path string) (config *Config, err error) {
@@ -36,10 +66,16 @@ func LoadConfig(path string) (config *Config, err error) {
 	if err != nil {
 		return nil, err
 	}
-	err = yaml.Unmarshal(yamlFile, &config)
+	// NOTE: Values missing from the file keep their defaults.
+	config = Default()
+	err = yaml.Unmarshal(yamlFile, config)
 	if err != nil {
 		return nil, err
 	}
+	// NOTE: The error lists every invalid field, see validate.Fields.
+	if err := validate.Struct(config); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
@@ -0,0 +1,215 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"./binding"
+	"./config"
+	"./fault"
+	"./health"
+	"./log"
+	"./rpc"
+	"./scheduler"
+	"./server"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// The control socket is the channel between the daemon and the cli, both built
+// on this library. It lives in the runtime directory and speaks JSON-RPC; the
+// built-in methods are status, health, reload, stop, upgrade, version,
+// config, metrics, jobs, events, watch, log.level and log.reopen; every
+// action in Actions is a method of the same name.
+////////////////////////////////////////////////////////////////////////////////
+
+type Status struct {
+	Name      string            `json:"name" yaml:"name"`
+	Version   string            `json:"version" yaml:"version"`
+	PID       int               `json:"pid" yaml:"pid"`
+	Started   time.Time         `json:"started" yaml:"started"`
+	Uptime    string            `json:"uptime" yaml:"uptime"`
+	Listeners map[string]string `json:"listeners" yaml:"listeners"`
+	Liveness  health.Report     `json:"liveness" yaml:"liveness"`
+	Readiness health.Report     `json:"readiness" yaml:"readiness"`
+}
+
+func (self Status) String() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s %s (pid %d)\n", self.Name, self.Version, self.PID)
+	fmt.Fprintf(&text, "up %s, since %s\n", self.Uptime, self.Started.Format(time.RFC1123))
+	fmt.Fprintf(&text, "\nalive: %s, ready: %s\n", self.Liveness.Status, self.Readiness.Status)
+	for _, check := range self.Readiness.Checks {
+		fmt.Fprintf(&text, "  [%s] %-20s %-10s %s", check.Status, check.Name, check.Kind, check.Duration)
+		if check.Error != "" {
+			fmt.Fprintf(&text, "  %s", check.Error)
+		}
+		text.WriteString("\n")
+	}
+	names := make([]string, 0, len(self.Listeners))
+	for name := range self.Listeners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if 0 < len(names) {
+		text.WriteString("\nlisteners:\n")
+	}
+	for _, name := range names {
+		fmt.Fprintf(&text, "  %-16s %s\n", name, self.Listeners[name])
+	}
+	return text.String()
+}
+
+type HealthParams struct {
+	Kind string `json:"kind"`
+}
+
+type VersionResult struct {
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version" yaml:"version"`
+}
+
+func (self VersionResult) String() string { return self.Name + " " + self.Version }
+
+type ControlResult struct {
+	Method string `json:"method" yaml:"method"`
+	OK     bool   `json:"ok" yaml:"ok"`
+}
+
+func (self ControlResult) String() string { return self.Method + ": ok" }
+
+type UpgradeResult struct {
+	PID int `json:"pid" yaml:"pid"`
+}
+
+func (self UpgradeResult) String() string {
+	return fmt.Sprintf("upgraded, now serving from pid %d", self.PID)
+}
+
+type JobParams struct {
+	Job string `json:"job"`
+}
+
+type LogLevelParams struct {
+	Component string `json:"component,omitempty"`
+	Level     string `json:"level,omitempty"`
+}
+
+// ActionsPrefix is the path the actions are routed under by an HTTP front end.
+const ActionsPrefix = "/api"
+
+func (self *Application) ControlSocket() string {
+	return rpc.Socket(string(self.Runtime.Path))
+}
+
+func (self *Application) Status(ctx context.Context) Status {
+	self.mu.Lock()
+	listeners := make(map[string]string)
+	for name, address := range self.Listeners {
+		listeners[name] = address.String()
+	}
+	self.mu.Unlock()
+	return Status{
+		Name:      self.Name,
+		Version:   self.Version.String(),
+		PID:       os.Getpid(),
+		Started:   self.Started,
+		Uptime:    time.Since(self.Started).Round(time.Second).String(),
+		Listeners: listeners,
+		Liveness:  self.Health.Liveness(ctx),
+		Readiness: self.Health.Readiness(ctx),
+	}
+}
+
+// ServeControl binds the control socket and serves the RPC methods on it
+// until the application shuts down. A client streaming events or changes
+// sends nothing while it waits, so reads have no deadline.
+func (self *Application) ServeControl() (*server.Listener, error) {
+	if err := binding.Methods(self.Actions, self.RPC); err != nil {
+		return nil, err
+	}
+	listener, err := self.Server.Unix(self.ControlSocket())
+	if err != nil {
+		return nil, err
+	}
+	listener.ServeStreams(self.RPC.Serve(self.context))
+	return listener, nil
+}
+
+func (self *Application) registerControl() {
+	self.RPC.Register("status", "process status, uptime, health and listeners", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return self.Status(ctx), nil
+	})
+	self.RPC.Register("health", "run the liveness or readiness checks", rpc.Typed(func(ctx context.Context, params HealthParams) (health.Report, error) {
+		if params.Kind == health.Readiness.String() {
+			return self.Health.Readiness(ctx), nil
+		}
+		return self.Health.Liveness(ctx), nil
+	}))
+	self.RPC.Register("version", "name and version", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return VersionResult{Name: self.Name, Version: self.Version.String()}, nil
+	})
+	self.RPC.Register("reload", "reload the config file", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		if err := self.Reload(); err != nil {
+			return nil, err
+		}
+		return ControlResult{Method: "reload", OK: true}, nil
+	})
+	self.RPC.Register("stop", "shut down gracefully", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		self.Stop()
+		return ControlResult{Method: "stop", OK: true}, nil
+	})
+	self.RPC.Register("upgrade", "re-execute the binary on disk without dropping connections", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		pid, err := self.Upgrade()
+		if err != nil {
+			return nil, err
+		}
+		return UpgradeResult{PID: pid}, nil
+	})
+	self.RPC.Register("config", "dump the effective config", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		if self.Settings == nil {
+			return config.Default(), nil
+		}
+		return self.Settings, nil
+	})
+	self.RPC.Register("metrics", "the metrics, in the Prometheus text format in text mode", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return self.Metrics.Gather(), nil
+	})
+	self.RPC.Register("jobs", "the scheduled jobs with their next and last run", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return self.Jobs.Jobs(), nil
+	})
+	self.RPC.Register("jobs.history", "the latest runs of a job", rpc.Typed(func(ctx context.Context, params JobParams) (scheduler.Runs, error) {
+		return self.Jobs.History.Runs(params.Job), nil
+	}))
+	self.RPC.Register("jobs.run", "run a job now, outside its schedule", rpc.Typed(func(ctx context.Context, params JobParams) (ControlResult, error) {
+		if err := self.Jobs.Trigger(params.Job); err != nil {
+			return ControlResult{}, err
+		}
+		return ControlResult{Method: "jobs.run", OK: true}, nil
+	}))
+	self.RPC.Register("events", "stream the events matching a pattern", rpc.Typed(self.streamEvents))
+	self.RPC.Register("watch", "stream the changes of a model collection", rpc.Typed(self.streamWatch))
+	self.RPC.Register("log.level", "show the log levels, or set the level of a component", rpc.Typed(func(ctx context.Context, params LogLevelParams) (log.Levels, error) {
+		if params.Level != "" {
+			level, err := log.ParseLevel(params.Level)
+			if err != nil {
+				return nil, fault.Wrap(err, fault.Usage, "usage.invalid_argument", "invalid log level").
+					WithHint("use debug, info, warn or error")
+			}
+			self.Log.SetLevel(params.Component, level)
+			self.Log.Named("log").Info("level changed", "logger", params.Component, "level", level)
+		}
+		return self.Log.Levels(), nil
+	}))
+	self.RPC.Register("log.reopen", "reopen the log files after an outside rotation", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		if err := self.Log.Reopen(); err != nil {
+			return nil, err
+		}
+		return ControlResult{Method: "log.reopen", OK: true}, nil
+	})
+}
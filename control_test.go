@@ -0,0 +1,102 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"./controller"
+	"./event"
+	"./filesystem"
+	"./kv"
+	"./log"
+	"./model"
+	"./rpc"
+	"./server"
+)
+
+type streamed struct {
+	model.Model
+	Title string `json:"title"`
+}
+
+// testApplication serves the control socket of an application whose
+// connections time out reads after readTimeout.
+func testApplication(t *testing.T, readTimeout time.Duration) *Application {
+	t.Helper()
+	store, err := kv.Open(filepath.Join(t.TempDir(), "kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := server.DefaultConfig()
+	config.Listeners, config.ReadTimeout = nil, readTimeout
+	app := &Application{
+		Name:    "app-test",
+		Runtime: filesystem.Directory{Path: filesystem.Path(t.TempDir())},
+		Started: time.Now(),
+		RPC:     rpc.NewServer(),
+		Actions: controller.NewRegistry(),
+		Events:  event.New(log.New(log.Error)),
+		Store:   model.NewKV(store, model.JSON{}),
+		Server:  server.New(config),
+	}
+	app.context, app.cancel = context.WithCancel(context.Background())
+	app.registerControl()
+	if _, err := app.ServeControl(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		app.cancel()
+		app.Server.Shutdown(context.Background())
+		store.Close()
+	})
+	return app
+}
+
+func TestStreamsOutliveReadTimeout(t *testing.T) {
+	const readTimeout = 100 * time.Millisecond
+	tests := []struct {
+		method  string
+		params  interface{}
+		produce func(*Application, int)
+	}{
+		{"events", EventsParams{Pattern: "test.*"}, func(app *Application, index int) {
+			app.Events.Publish("test.tick", index)
+		}},
+		{"watch", WatchParams{Collection: "notes"}, func(app *Application, index int) {
+			model.NewRepository[streamed](app.Store, "notes").Create(&streamed{Title: "note"})
+		}},
+	}
+	for _, test := range tests {
+		app := testApplication(t, readTimeout)
+		client, err := rpc.Dial(app.ControlSocket(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received := make(chan json.RawMessage, 100)
+		done := make(chan error, 1)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			done <- client.Stream(ctx, test.method, test.params, func(data json.RawMessage) { received <- data }, nil)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		// NOTE: The client sends nothing while the stream is open, for
+		// several read timeouts.
+		for index := 0; index < 8; index++ {
+			test.produce(app, index)
+			select {
+			case <-received:
+			case err := <-done:
+				t.Fatalf("%s: the stream ended after %d items: %v", test.method, index, err)
+			case <-time.After(time.Second):
+				t.Fatalf("%s: item %d was not streamed", test.method, index)
+			}
+			time.Sleep(readTimeout / 2)
+		}
+		cancel()
+		<-done
+		client.Close()
+	}
+}
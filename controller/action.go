@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"../fault"
+)
+
+// Action is a named operation with typed input and output. It is declared
+// once with NewAction and can then be dispatched by name with any input the
+// registry can turn into its input type: the input itself, a pointer to it or
+// its JSON encoding.
+type Action struct {
+	Name        string
+	Description string
+	// NOTE: Metadata is free-form, for what presents the action, e.g.
+	// `hidden`, `confirm` or `category`.
+	Metadata map[string]string
+
+	input      reflect.Type
+	output     reflect.Type
+	handler    Handler
+	middleware Middlewares
+}
+
+func NewAction[I, O any](name, description string, handler func(context.Context, I) (O, error)) *Action {
+	return &Action{
+		Name:        name,
+		Description: description,
+		Metadata:    make(map[string]string),
+		input:       reflect.TypeOf((*I)(nil)).Elem(),
+		output:      reflect.TypeOf((*O)(nil)).Elem(),
+		handler: func(ctx context.Context, input interface{}) (interface{}, error) {
+			// NOTE: Input is converted before the middleware runs, a nil input
+			// is the zero I and a mismatch comes from middleware replacing it.
+			typed, ok := input.(I)
+			if !ok && input != nil {
+				return nil, fault.UsageError("usage.invalid", "%s takes %s, not %T", name, reflect.TypeOf((*I)(nil)).Elem(), input)
+			}
+			return handler(ctx, typed)
+		},
+	}
+}
+
+// With sets a metadata value, for declaring actions in one expression.
+func (self *Action) With(key, value string) *Action {
+	self.Metadata[key] = value
+	return self
+}
+
+// Use adds middleware run around this action only, innermost.
+func (self *Action) Use(middleware ...Middleware) *Action {
+	self.middleware = self.middleware.Then(middleware...)
+	return self
+}
+
+func (self *Action) Input() reflect.Type  { return self.input }
+func (self *Action) Output() reflect.Type { return self.output }
+
+// NewInput returns a pointer to a zero input, to decode into.
+func (self *Action) NewInput() interface{} { return reflect.New(self.input).Interface() }
+
+// Run converts the input and calls the handler, without any middleware.
+func (self *Action) Run(ctx context.Context, input interface{}) (interface{}, error) {
+	converted, err := self.convert(input)
+	if err != nil {
+		return nil, err
+	}
+	return self.handler(ctx, converted)
+}
+
+func (self *Action) convert(input interface{}) (interface{}, error) {
+	switch value := input.(type) {
+	case nil:
+		return reflect.Zero(self.input).Interface(), nil
+	case json.RawMessage:
+		decoded := reflect.New(self.input)
+		if 0 < len(value) && string(value) != "null" {
+			if err := json.Unmarshal(value, decoded.Interface()); err != nil {
+				return nil, fault.Wrap(err, fault.Usage, "usage.invalid_input", "invalid input for %s", self.Name)
+			}
+		}
+		return decoded.Elem().Interface(), nil
+	}
+	given := reflect.ValueOf(input)
+	switch {
+	case given.Type().AssignableTo(self.input):
+		converted := reflect.New(self.input).Elem()
+		converted.Set(given)
+		return converted.Interface(), nil
+	case given.Kind() == reflect.Ptr && given.Type().Elem().AssignableTo(self.input):
+		if given.IsNil() {
+			return reflect.Zero(self.input).Interface(), nil
+		}
+		return given.Elem().Interface(), nil
+	}
+	return nil, fault.InternalError("internal.action_input", "%s takes %s, not %s", self.Name, self.input, given.Type())
+}
+
+// Call runs an action with typed input and output.
+func Call[I, O any](ctx context.Context, action *Action, input I) (output O, err error) {
+	result, err := action.Run(ctx, input)
+	if err != nil {
+		return output, err
+	}
+	output, ok := result.(O)
+	if !ok && result != nil {
+		return output, fault.InternalError("internal.action_output", "%s returns %s, not %T", action.Name, action.output, output)
+	}
+	return output, nil
+}
+
+func (self *Action) String() string {
+	return fmt.Sprintf("%s(%s) %s", self.Name, self.input, self.output)
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"../fault"
+)
+
+type note struct {
+	Title string `json:"title" validate:"required"`
+}
+
+type titled interface{ Name() string }
+
+func (self note) Name() string { return self.Title }
+
+func TestConvert(t *testing.T) {
+	action := NewAction("notes.echo", "", func(ctx context.Context, input note) (note, error) {
+		return input, nil
+	})
+	tests := []struct {
+		input interface{}
+		title string
+		code  string
+	}{
+		{note{Title: "a"}, "a", ""},
+		{&note{Title: "b"}, "b", ""},
+		{(*note)(nil), "", ""},
+		{nil, "", ""},
+		{json.RawMessage(`{"title":"c"}`), "c", ""},
+		{json.RawMessage(`null`), "", ""},
+		{json.RawMessage(`[1]`), "", "usage.invalid_input"},
+		{"a string", "", "internal.action_input"},
+	}
+	for _, test := range tests {
+		output, err := action.Run(context.Background(), test.input)
+		if test.code != "" {
+			if fault.As(err).Code != test.code {
+				t.Errorf("%#v returned %v, expected %s", test.input, err, test.code)
+			}
+			continue
+		}
+		if err != nil || output.(note).Title != test.title {
+			t.Errorf("%#v returned %v, %v, expected %q", test.input, output, err, test.title)
+		}
+	}
+}
+
+func TestInterfaceInput(t *testing.T) {
+	action := NewAction("names.get", "", func(ctx context.Context, input titled) (string, error) {
+		if input == nil {
+			return "nobody", nil
+		}
+		return input.Name(), nil
+	})
+	tests := []struct {
+		input  interface{}
+		output string
+		code   string
+	}{
+		{nil, "nobody", ""},
+		{note{Title: "a"}, "a", ""},
+		{42, "", "usage.invalid"},
+	}
+	for _, test := range tests {
+		output, err := action.handler(context.Background(), test.input)
+		if test.code != "" {
+			if fault.As(err).Code != test.code || fault.As(err).HTTPStatus() != 422 {
+				t.Errorf("%#v returned %v, expected %s", test.input, err, test.code)
+			}
+			continue
+		}
+		if err != nil || output != test.output {
+			t.Errorf("%#v returned %v, %v, expected %q", test.input, output, err, test.output)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	action := NewAction("notes.count", "", func(ctx context.Context, input note) (int, error) {
+		return len(input.Title), nil
+	})
+	count, err := Call[note, int](context.Background(), action, note{Title: "four"})
+	if err != nil || count != 4 {
+		t.Errorf("called as %d, %v", count, err)
+	}
+	if _, err := Call[note, string](context.Background(), action, note{}); fault.As(err).Code != "internal.action_output" {
+		t.Errorf("a mistyped call returned %v", err)
+	}
+	if fmt.Sprint(action) != "notes.count(controller.note) int" {
+		t.Errorf("printed as %s", action)
+	}
+}
@@ -1,11 +1,70 @@
 package controller
 
-type Action struct {
-}
+import "sort"
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// A controller groups the actions of one part of the application, with the
+// middleware run around each of them. The application logic lives in the actions,
+// the cli, the daemon and any other interface only present them.
+//
+//   type Notes struct{ controller.Base }
+//
+//   func (self Notes) Actions() controller.Actions {
+//     return controller.Actions{
+//       controller.NewAction("notes.add", "add a note", self.add),
+//     }
+//   }
+//
+//   func (self Notes) Middleware() controller.Middlewares {
+//     return controller.Middlewares{controller.Authorize(self.owner)}
+//   }
+//
+//   func (self Notes) Watch() controller.Watches {
+//     return controller.Watches{
+//       controller.NewWatch("notes.index", "notes", self.index),
+//     }
+//   }
+//
+////////////////////////////////////////////////////////////////////////////////
 
 type Controller interface {
-	BeforeHooks() *Action
-	AfterHooks() *Action
-	Actions() *Action
-	Watch() err
+	// Middleware runs around every action of the controller, inside the
+	// middleware of the registry and outside that of the action.
+	Middleware() Middlewares
+	Actions() Actions
+	// Watch are the changes of models the controller reacts to.
+	Watch() Watches
+}
+
+// Base is embedded by controllers without middleware or anything to watch.
+type Base struct{}
+
+func (Base) Middleware() Middlewares { return nil }
+func (Base) Watch() Watches          { return nil }
+
+// Actions is a set of actions, kept in the order they were declared.
+type Actions []*Action
+
+func (self Actions) Get(name string) *Action {
+	for _, action := range self {
+		if action.Name == name {
+			return action
+		}
+	}
+	return nil
+}
+
+func (self Actions) Names() []string {
+	names := make([]string, 0, len(self))
+	for _, action := range self {
+		names = append(names, action.Name)
+	}
+	return names
+}
+
+func (self Actions) Sorted() Actions {
+	sorted := append(Actions{}, self...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
 }
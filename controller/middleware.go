@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"reflect"
+
+	"../fault"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Middleware is what runs around actions without being part of any one of
+// them: recovery, logging, timeouts, authorization, dry runs. A middleware
+// wraps the handler of an action; it runs the rest of the chain, and the
+// action, by calling next, and short-circuits them by returning without.
+//
+// The chain of an action is, outermost first, the middleware of the registry,
+// of its controller and its own:
+//
+//   app.Actions.Use(controller.Recover(app.Crashes()))
+//   controller.NewAction("notes.add", "add a note", self.add).
+//     Use(controller.Before(func(ctx context.Context, note Note) (Note, error) {
+//       note.Title = strings.TrimSpace(note.Title)
+//       return note, nil
+//     }))
+//
+////////////////////////////////////////////////////////////////////////////////
+
+// Handler runs an action with its input, converted and valid.
+type Handler func(ctx context.Context, input interface{}) (interface{}, error)
+
+type Middleware func(action *Action, next Handler) Handler
+
+// Middlewares is a chain of middleware, outermost first.
+type Middlewares []Middleware
+
+func (self Middlewares) Then(middleware ...Middleware) Middlewares {
+	return append(append(Middlewares{}, self...), middleware...)
+}
+
+func (self Middlewares) wrap(action *Action, handler Handler) Handler {
+	for index := len(self) - 1; 0 <= index; index-- {
+		handler = self[index](action, handler)
+	}
+	return handler
+}
+
+// Around is a middleware seeing the typed input and output of the actions
+// taking I and returning O, other actions pass through it untouched; with
+// interface{} for either it applies to all.
+func Around[I, O any](around func(ctx context.Context, input I, next func(context.Context, I) (O, error)) (O, error)) Middleware {
+	input, output := reflect.TypeOf((*I)(nil)).Elem(), reflect.TypeOf((*O)(nil)).Elem()
+	return func(action *Action, next Handler) Handler {
+		if !fits(action.input, input) || !fits(action.output, output) {
+			return next
+		}
+		typed := func(ctx context.Context, given I) (result O, err error) {
+			value, err := next(ctx, given)
+			if err != nil || value == nil {
+				return result, err
+			}
+			return value.(O), nil
+		}
+		return func(ctx context.Context, given interface{}) (interface{}, error) {
+			value, _ := given.(I)
+			result, err := around(ctx, value, typed)
+			if err != nil {
+				return nil, err
+			}
+			return action.checkOutput(result)
+		}
+	}
+}
+
+// Before is a middleware that may replace, or refuse, the input of actions
+// taking I.
+func Before[I any](before func(context.Context, I) (I, error)) Middleware {
+	return Around(func(ctx context.Context, input I, next func(context.Context, I) (interface{}, error)) (interface{}, error) {
+		input, err := before(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, input)
+	})
+}
+
+// After is a middleware that may replace, or refuse, the output of actions
+// returning O.
+func After[O any](after func(context.Context, O) (O, error)) Middleware {
+	return Around(func(ctx context.Context, input interface{}, next func(context.Context, interface{}) (O, error)) (O, error) {
+		output, err := next(ctx, input)
+		if err != nil {
+			return output, err
+		}
+		return after(ctx, output)
+	})
+}
+
+// fits is whether values of a type are, or implement, another type.
+func fits(given, to reflect.Type) bool {
+	return given == to || (to.Kind() == reflect.Interface && given.Implements(to))
+}
+
+// checkOutput checks an output replaced by middleware with an interface O is
+// still of the action's output type.
+func (self *Action) checkOutput(output interface{}) (interface{}, error) {
+	if output == nil {
+		return nil, nil
+	}
+	if !reflect.TypeOf(output).AssignableTo(self.output) {
+		return nil, fault.InternalError("internal.action_output", "%s returns %s, not %T", self.Name, self.output, output)
+	}
+	return output, nil
+}
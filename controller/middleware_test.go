@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"../fault"
+	"../log"
+)
+
+func TestAround(t *testing.T) {
+	counted := 0
+	tests := []struct {
+		name       string
+		middleware Middleware
+		action     string
+		output     interface{}
+		code       string
+		counted    int
+	}{
+		{
+			"typed",
+			Around(func(ctx context.Context, input note, next func(context.Context, note) (note, error)) (note, error) {
+				counted++
+				input.Title += " in"
+				output, err := next(ctx, input)
+				output.Title += " out"
+				return output, err
+			}),
+			"notes.add", note{Title: "a in out"}, "", 1,
+		},
+		{
+			"other input",
+			Around(func(ctx context.Context, input int, next func(context.Context, int) (note, error)) (note, error) {
+				counted++
+				return next(ctx, input)
+			}),
+			"notes.add", note{Title: "a"}, "", 0,
+		},
+		{
+			"any input",
+			Around(func(ctx context.Context, input interface{}, next func(context.Context, interface{}) (interface{}, error)) (interface{}, error) {
+				counted++
+				return next(ctx, input)
+			}),
+			"notes.count", 1, "", 1,
+		},
+		{
+			"output of another type",
+			Around(func(ctx context.Context, input interface{}, next func(context.Context, interface{}) (interface{}, error)) (interface{}, error) {
+				return "replaced", nil
+			}),
+			"notes.add", nil, "internal.action_output", 0,
+		},
+		{
+			"refused",
+			Around(func(ctx context.Context, input note, next func(context.Context, note) (note, error)) (note, error) {
+				return note{}, fault.UsageError("usage.refused", "refused")
+			}),
+			"notes.add", nil, "usage.refused", 0,
+		},
+	}
+	for _, test := range tests {
+		counted = 0
+		registry := NewRegistry()
+		registry.Use(test.middleware)
+		registry.Add(
+			NewAction("notes.add", "", func(ctx context.Context, input note) (note, error) { return input, nil }),
+			NewAction("notes.count", "", func(ctx context.Context, input struct{}) (int, error) { return 1, nil }),
+		)
+		input := interface{}(note{Title: "a"})
+		if test.action == "notes.count" {
+			input = nil
+		}
+		output, err := registry.Dispatch(context.Background(), test.action, input)
+		if test.code != "" {
+			if fault.As(err).Code != test.code {
+				t.Errorf("%s: returned %v, expected %s", test.name, err, test.code)
+			}
+			continue
+		}
+		if err != nil || output != test.output || counted != test.counted {
+			t.Errorf("%s: returned %#v, %v, ran %d times, expected %#v, %d times", test.name, output, err, counted, test.output, test.counted)
+		}
+	}
+}
+
+func TestLogging(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		level    log.Level
+		expected string
+	}{
+		{"success", nil, log.Debug, "action=notes.run"},
+		{"success, not debugging", nil, log.Info, ""},
+		{"usage error", fault.UsageError("usage.invalid", "no title"), log.Info, ""},
+		{"usage error, debugging", fault.UsageError("usage.invalid", "no title"), log.Debug, "error=\"no title\""},
+		{"internal error", errors.New("disk on fire"), log.Info, "error=\"disk on fire\""},
+	}
+	for _, test := range tests {
+		output := new(bytes.Buffer)
+		registry := NewRegistry()
+		registry.Use(Logging(log.New(test.level, log.NewWriterSink(output, log.LogfmtEncoder{}))))
+		registry.Add(NewAction("notes.run", "", func(ctx context.Context, input struct{}) (bool, error) {
+			return test.err == nil, test.err
+		}))
+		registry.Dispatch(context.Background(), "notes.run", nil)
+		logged := output.String()
+		if test.expected == "" && logged != "" || !strings.Contains(logged, test.expected) {
+			t.Errorf("%s: logged %q, expected %q", test.name, logged, test.expected)
+		}
+		if test.expected != "" && !strings.Contains(logged, "duration=") {
+			t.Errorf("%s: logged %q without the duration", test.name, logged)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"../fault"
+	"../metrics"
+	"../validate"
+)
+
+// Registry dispatches actions by name, each inside the middleware of the
+// registry, of the controller it was registered with and its own.
+type Registry struct {
+	mu          sync.RWMutex
+	actions     map[string]registered
+	controllers []Controller
+	middleware  Middlewares
+	calls       *metrics.Counter
+	durations   *metrics.Histogram
+}
+
+type registered struct {
+	action     *Action
+	middleware Middlewares
+}
+
+func NewRegistry() *Registry {
+	return &Registry{actions: make(map[string]registered)}
+}
+
+// Instrument counts dispatched actions by name and result, and observes their
+// duration, middleware included.
+func (self *Registry) Instrument(registry *metrics.Registry) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.calls = registry.Counter("actions_total", "dispatched actions by action and result", "action", "result")
+	self.durations = registry.Histogram("action_duration_seconds", "action duration, middleware included", nil, "action")
+}
+
+// Register adds the actions of a controller.
+func (self *Registry) Register(controller Controller) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, watch := range controller.Watch() {
+		for _, registered := range self.controllers {
+			for _, taken := range registered.Watch() {
+				if taken.Name == watch.Name {
+					return fault.InternalError("internal.watch", "watch %q is already registered", watch.Name)
+				}
+			}
+		}
+	}
+	middleware := controller.Middleware()
+	entries := make([]registered, 0, len(controller.Actions()))
+	for _, action := range controller.Actions() {
+		entries = append(entries, registered{action: action, middleware: middleware})
+	}
+	if err := self.add(entries...); err != nil {
+		return err
+	}
+	self.controllers = append(self.controllers, controller)
+	return nil
+}
+
+// Use adds middleware run around every action, outermost, including the
+// actions registered before.
+func (self *Registry) Use(middleware ...Middleware) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.middleware = self.middleware.Then(middleware...)
+}
+
+// Add registers actions that belong to no controller.
+func (self *Registry) Add(actions ...*Action) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	entries := make([]registered, 0, len(actions))
+	for _, action := range actions {
+		entries = append(entries, registered{action: action})
+	}
+	return self.add(entries...)
+}
+
+// add registers all of the entries or, when a name is taken, none of them.
+func (self *Registry) add(entries ...registered) error {
+	for index, entry := range entries {
+		_, taken := self.actions[entry.action.Name]
+		if taken || index != indexOf(entries, entry.action.Name) {
+			return fault.InternalError("internal.action", "action %q is already registered", entry.action.Name)
+		}
+	}
+	for _, entry := range entries {
+		self.actions[entry.action.Name] = entry
+	}
+	return nil
+}
+
+func indexOf(entries []registered, name string) int {
+	for index, entry := range entries {
+		if entry.action.Name == name {
+			return index
+		}
+	}
+	return -1
+}
+
+func (self *Registry) Action(name string) (*Action, bool) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	entry, ok := self.actions[name]
+	return entry.action, ok
+}
+
+// Actions returns the registered actions, by name.
+func (self *Registry) Actions() Actions {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	actions := make(Actions, 0, len(self.actions))
+	for _, entry := range self.actions {
+		actions = append(actions, entry.action)
+	}
+	return actions.Sorted()
+}
+
+func (self *Registry) Controllers() []Controller {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	return append([]Controller{}, self.controllers...)
+}
+
+// Dispatch runs an action by name, inside its middleware.
+func (self *Registry) Dispatch(ctx context.Context, name string, input interface{}) (interface{}, error) {
+	self.mu.RLock()
+	entry, ok := self.actions[name]
+	middleware := self.middleware
+	calls, durations := self.calls, self.durations
+	self.mu.RUnlock()
+	if !ok {
+		return nil, fault.UsageError("usage.unknown_action", "unknown action %q", name).
+			WithHint("list the actions with: actions")
+	}
+	started := time.Now()
+	output, err := entry.run(ctx, middleware, input)
+	if calls != nil {
+		durations.Observe(time.Since(started).Seconds(), name)
+		if err != nil {
+			calls.Inc(name, fault.ClassOf(err).String())
+		} else {
+			calls.Inc(name, "ok")
+		}
+	}
+	return output, err
+}
+
+func (self registered) run(ctx context.Context, middleware Middlewares, input interface{}) (interface{}, error) {
+	// NOTE: Middleware sees the decoded, valid input, not the JSON it may
+	// arrive as.
+	input, err := self.action.convert(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate.Struct(input); err != nil {
+		return nil, fault.Wrap(err, fault.Usage, "usage.invalid", "invalid input for %s", self.action.Name).
+			WithHint("fix the fields listed and retry")
+	}
+	chain := middleware.Then(self.middleware...).Then(self.action.middleware...)
+	return chain.wrap(self.action, self.action.handler)(ctx, input)
+}
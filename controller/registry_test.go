@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"../fault"
+	"../metrics"
+	"../validate"
+)
+
+type notes struct {
+	Base
+	trace *[]string
+}
+
+func (self notes) Middleware() Middlewares {
+	return Middlewares{trace("controller", self.trace)}
+}
+
+func (self notes) Actions() Actions {
+	return Actions{
+		NewAction("notes.add", "add a note", func(ctx context.Context, input note) (note, error) {
+			*self.trace = append(*self.trace, "action")
+			return input, nil
+		}).Use(trace("action", self.trace)),
+		NewAction("notes.list", "list the notes", func(ctx context.Context, input struct{}) ([]note, error) {
+			return []note{{Title: "a"}}, nil
+		}).With("method", "GET"),
+	}
+}
+
+func trace(name string, trace *[]string) Middleware {
+	return func(action *Action, next Handler) Handler {
+		return func(ctx context.Context, input interface{}) (interface{}, error) {
+			*trace = append(*trace, name)
+			return next(ctx, input)
+		}
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	var calls []string
+	registry := NewRegistry()
+	registry.Use(trace("registry", &calls))
+	if err := registry.Register(notes{trace: &calls}); err != nil {
+		t.Fatal(err)
+	}
+	metricsRegistry := metrics.NewRegistry()
+	registry.Instrument(metricsRegistry)
+	output, err := registry.Dispatch(context.Background(), "notes.add", note{Title: "a"})
+	if err != nil || output.(note).Title != "a" {
+		t.Fatalf("dispatched as %v, %v", output, err)
+	}
+	if strings.Join(calls, " ") != "registry controller action action" {
+		t.Errorf("ran %v", calls)
+	}
+	tests := []struct {
+		name   string
+		input  interface{}
+		code   string
+		status int
+	}{
+		{"notes.add", note{}, "usage.invalid", 422},
+		{"notes.missing", nil, "usage.unknown_action", 404},
+	}
+	for _, test := range tests {
+		_, err := registry.Dispatch(context.Background(), test.name, test.input)
+		if fault.As(err).Code != test.code || fault.As(err).HTTPStatus() != test.status {
+			t.Errorf("%s returned %v, expected %s %d", test.name, err, test.code, test.status)
+		}
+	}
+	_, err = registry.Dispatch(context.Background(), "notes.add", note{})
+	if fields := validate.Fields(err); len(fields) != 1 || fields[0].Field != "title" {
+		t.Errorf("field errors %v", fields)
+	}
+	if !strings.Contains(metricsRegistry.Gather().String(), `actions_total{action="notes.add",result="usage"} 2`) {
+		t.Errorf("failed dispatches were not counted:\n%s", metricsRegistry.Gather())
+	}
+	if names := registry.Actions().Names(); strings.Join(names, " ") != "notes.add notes.list" {
+		t.Errorf("actions %v", names)
+	}
+	if err := registry.Register(notes{trace: &calls}); fault.As(err).Code != "internal.action" {
+		t.Errorf("registering twice returned %v", err)
+	}
+	duplicate := NewAction("other", "", func(context.Context, note) (note, error) { return note{}, nil })
+	if err := registry.Add(duplicate, duplicate); err == nil {
+		t.Error("a duplicate in one call was registered")
+	}
+	if _, ok := registry.Action("other"); ok {
+		t.Error("a failed registration was partly applied")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		middleware Middleware
+		ctx        context.Context
+		output     interface{}
+		code       string
+	}{
+		{
+			"before",
+			Before(func(ctx context.Context, input note) (note, error) {
+				input.Title = strings.ToUpper(input.Title)
+				return input, nil
+			}),
+			context.Background(), note{Title: "A"}, "",
+		},
+		{
+			"before with an interface",
+			Before(func(ctx context.Context, input titled) (titled, error) { return nil, errors.New("refused") }),
+			context.Background(), nil, "internal.error",
+		},
+		{
+			"after",
+			After(func(ctx context.Context, output note) (note, error) {
+				output.Title += "!"
+				return output, nil
+			}),
+			context.Background(), note{Title: "a!"}, "",
+		},
+		{
+			"after of another type",
+			After(func(ctx context.Context, output int) (int, error) { return 0, errors.New("not run") }),
+			context.Background(), note{Title: "a"}, "",
+		},
+		{
+			"authorize",
+			Authorize(func(ctx context.Context, action *Action, input interface{}) error { return errors.New("no") }),
+			context.Background(), nil, "permission.denied",
+		},
+		{
+			"authorize with a fault",
+			Authorize(func(ctx context.Context, action *Action, input interface{}) error {
+				return fault.UsageError("usage.owner", "not yours")
+			}),
+			context.Background(), nil, "usage.owner",
+		},
+		{"dry run", DryRun(), WithDryRun(context.Background()), Planned{Action: "notes.add", Input: note{Title: "a"}}, ""},
+		{"no dry run", DryRun(), context.Background(), note{Title: "a"}, ""},
+	}
+	for _, test := range tests {
+		registry := NewRegistry()
+		registry.Use(test.middleware)
+		registry.Add(NewAction("notes.add", "", func(ctx context.Context, input note) (note, error) { return input, nil }))
+		output, err := registry.Dispatch(test.ctx, "notes.add", note{Title: "a"})
+		if test.code != "" {
+			if fault.As(err).Code != test.code {
+				t.Errorf("%s: returned %v, expected %s", test.name, err, test.code)
+			}
+			continue
+		}
+		if err != nil || output != test.output {
+			t.Errorf("%s: returned %#v, %v, expected %#v", test.name, output, err, test.output)
+		}
+	}
+}
+
+func TestTimeoutAndRecover(t *testing.T) {
+	registry := NewRegistry()
+	registry.Use(Recover(t.TempDir()), Timeout(20*time.Millisecond))
+	registry.Add(
+		NewAction("slow", "", func(ctx context.Context, input struct{}) (bool, error) {
+			<-ctx.Done()
+			return false, ctx.Err()
+		}),
+		NewAction("patient", "", func(ctx context.Context, input struct{}) (bool, error) {
+			time.Sleep(40 * time.Millisecond)
+			return true, nil
+		}).With("timeout", "1s"),
+		NewAction("broken", "", func(ctx context.Context, input struct{}) (bool, error) { panic("boom") }),
+	)
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"slow", "unavailable.timeout"},
+		{"patient", ""},
+		{"broken", "internal.panic"},
+	}
+	for _, test := range tests {
+		_, err := registry.Dispatch(context.Background(), test.name, nil)
+		if (test.code == "" && err != nil) || (test.code != "" && fault.As(err).Code != test.code) {
+			t.Errorf("%s returned %v, expected %q", test.name, err, test.code)
+		}
+	}
+}
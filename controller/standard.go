@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"../fault"
+	"../log"
+)
+
+// Recover turns a panic in an action into an internal error, with the stack
+// written to a crash report in the directory.
+func Recover(crashes string) Middleware {
+	return func(action *Action, next Handler) Handler {
+		return func(ctx context.Context, input interface{}) (output interface{}, err error) {
+			defer func() {
+				switch recovered := recover().(type) {
+				case nil:
+				case panicked:
+					output, err = nil, fault.Crash(recovered.value, recovered.stack, crashes)
+				default:
+					output, err = nil, fault.Crash(recovered, debug.Stack(), crashes)
+				}
+			}()
+			return next(ctx, input)
+		}
+	}
+}
+
+// panicked carries a panic, with its stack, from the goroutine an action ran
+// in to the one that waited for it.
+type panicked struct {
+	value interface{}
+	stack []byte
+}
+
+// Logging logs every action with its duration at debug, and internal errors,
+// bugs, at error; other failures are the caller's to report.
+func Logging(logger *log.Logger) Middleware {
+	return func(action *Action, next Handler) Handler {
+		return func(ctx context.Context, input interface{}) (interface{}, error) {
+			started := time.Now()
+			output, err := next(ctx, input)
+			switch {
+			case err != nil && fault.ClassOf(err) == fault.Internal:
+				logger.Error("action failed", "action", action.Name, "duration", time.Since(started), "error", err)
+			case err != nil:
+				logger.Debug("action failed", "action", action.Name, "duration", time.Since(started), "error", err)
+			default:
+				logger.Debug("action", "action", action.Name, "duration", time.Since(started))
+			}
+			return output, err
+		}
+	}
+}
+
+// Timeout cancels the context of actions running longer than the timeout, or
+// than their `timeout` metadata, and returns without waiting for them. No
+// timeout, zero, lets them run.
+func Timeout(timeout time.Duration) Middleware {
+	return func(action *Action, next Handler) Handler {
+		limit := timeout
+		if value, ok := action.Metadata["timeout"]; ok {
+			if parsed, err := time.ParseDuration(value); err == nil {
+				limit = parsed
+			}
+		}
+		if limit <= 0 {
+			return next
+		}
+		return func(ctx context.Context, input interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, limit)
+			defer cancel()
+			type result struct {
+				output interface{}
+				err    error
+				panic  *panicked
+			}
+			done := make(chan result, 1)
+			go func() {
+				defer func() {
+					if recovered := recover(); recovered != nil {
+						stack := debug.Stack()
+						if inner, ok := recovered.(panicked); ok {
+							recovered, stack = inner.value, inner.stack
+						}
+						done <- result{panic: &panicked{recovered, stack}}
+					}
+				}()
+				output, err := next(ctx, input)
+				done <- result{output: output, err: err}
+			}()
+			select {
+			case result := <-done:
+				if result.panic != nil {
+					panic(*result.panic)
+				}
+				return result.output, result.err
+			case <-ctx.Done():
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return nil, ctx.Err()
+				}
+				return nil, fault.New(fault.Unavailable, "unavailable.timeout", "%s did not finish within %s", action.Name, limit).
+					WithHint("retry, or raise the timeout of the action")
+			}
+		}
+	}
+}
+
+// Authorize runs a check before every action; an error from it refuses the
+// action, as a permission error unless it is a fault already.
+func Authorize(check func(ctx context.Context, action *Action, input interface{}) error) Middleware {
+	return func(action *Action, next Handler) Handler {
+		return func(ctx context.Context, input interface{}) (interface{}, error) {
+			if err := check(ctx, action, input); err != nil {
+				var classified *fault.Error
+				if errors.As(err, &classified) {
+					return nil, err
+				}
+				return nil, fault.Wrap(err, fault.Permission, "permission.denied", "not allowed to run %s", action.Name)
+			}
+			return next(ctx, input)
+		}
+	}
+}
+
+// Dry Runs ///////////////////////////////////////////////////////////////////
+type dryRunKey struct{}
+
+// WithDryRun marks a context so that actions show what they would do instead
+// of doing it.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// Planned is the output of an action skipped by a dry run.
+type Planned struct {
+	Action string      `json:"action" yaml:"action"`
+	Input  interface{} `json:"input" yaml:"input"`
+}
+
+func (self Planned) String() string {
+	input, _ := json.Marshal(self.Input)
+	return fmt.Sprintf("dry run, would run %s with %s", self.Action, input)
+}
+
+// DryRun skips actions in a dry run, returning what they would have run with.
+// Actions that only read, with the `method` metadata GET, run; actions with
+// the `dry_run` metadata run and check IsDryRun themselves.
+func DryRun() Middleware {
+	return func(action *Action, next Handler) Handler {
+		if strings.EqualFold(action.Metadata["method"], "GET") || action.Metadata["dry_run"] == "true" {
+			return next
+		}
+		return func(ctx context.Context, input interface{}) (interface{}, error) {
+			if IsDryRun(ctx) {
+				return Planned{Action: action.Name, Input: input}, nil
+			}
+			return next(ctx, input)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"../log"
+	"../model"
+)
+
+// Watch reacts to the changes of a collection, see model.Watch. Its cursor is
+// kept in the store under its name, so it resumes across restarts after the
+// last change it handled.
+type Watch struct {
+	Name       string
+	Collection string
+	Handler    func(context.Context, model.Change) error
+}
+
+type Watches []*Watch
+
+func NewWatch(name, collection string, handler func(context.Context, model.Change) error) *Watch {
+	return &Watch{Name: name, Collection: collection, Handler: handler}
+}
+
+// NOTE: Cursors are documents of this collection, by watch name.
+const cursors = "watches"
+
+// retryWatch is how long a failed watch waits before it retries the change.
+const retryWatch = 5 * time.Second
+
+// Watches returns the watches of the registered controllers.
+func (self *Registry) Watches() Watches {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	var watches Watches
+	for _, controller := range self.controllers {
+		watches = append(watches, controller.Watch()...)
+	}
+	return watches
+}
+
+// Watch runs the watches of the registered controllers on a store until ctx
+// is done. A handler that fails gets the same change again after a while.
+func (self *Registry) Watch(ctx context.Context, backend model.Backend, logger *log.Logger) {
+	for _, watch := range self.Watches() {
+		go watch.run(ctx, backend, logger.With("watch", watch.Name, "collection", watch.Collection))
+	}
+}
+
+func (self *Watch) run(ctx context.Context, backend model.Backend, logger *log.Logger) {
+	for {
+		cursor, err := self.cursor(backend)
+		if err == nil {
+			err = model.Watch(ctx, backend, self.Collection, cursor, func(change model.Change) error {
+				if err := self.Handler(ctx, change); err != nil {
+					return err
+				}
+				return self.save(backend, change.Cursor)
+			})
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, model.ErrCursorExpired) {
+			// NOTE: The changes missed are gone, the watch carries on from
+			// the latest one.
+			logger.Warn("changes were missed, resuming from the latest", "cursor", cursor)
+			if latest, err := model.Latest(backend, self.Collection); err == nil {
+				self.save(backend, latest)
+			}
+			continue
+		}
+		logger.Warn("watch failed, retrying", "error", err, "retry", retryWatch)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryWatch):
+		}
+	}
+}
+
+type cursor struct {
+	Cursor uint64 `json:"cursor" yaml:"cursor"`
+}
+
+// cursor returns the saved cursor of the watch; a new watch starts from the
+// latest change.
+func (self *Watch) cursor(backend model.Backend) (uint64, error) {
+	data, err := backend.Read(cursors, self.Name)
+	if errors.Is(err, model.ErrNotFound) {
+		latest, err := model.Latest(backend, self.Collection)
+		if err != nil {
+			return 0, err
+		}
+		return latest, self.save(backend, latest)
+	} else if err != nil {
+		return 0, err
+	}
+	var saved cursor
+	err = backend.Codec().Unmarshal(data, &saved)
+	return saved.Cursor, err
+}
+
+func (self *Watch) save(backend model.Backend, at uint64) error {
+	data, err := backend.Codec().Marshal(cursor{Cursor: at})
+	if err != nil {
+		return err
+	}
+	return backend.Write(cursors, self.Name, data)
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"../kv"
+	"../log"
+	"../model"
+)
+
+type stored struct {
+	model.Model
+	Title string `json:"title"`
+}
+
+type watcher struct {
+	Base
+	changes chan model.Change
+}
+
+func (self watcher) Actions() Actions { return nil }
+
+func (self watcher) Watch() Watches {
+	return Watches{NewWatch("notes.test", "notes", func(ctx context.Context, change model.Change) error {
+		self.changes <- change
+		return nil
+	})}
+}
+
+func TestWatch(t *testing.T) {
+	store, err := kv.Open(filepath.Join(t.TempDir(), "kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	backend := model.NewKV(store, model.JSON{})
+	notes := model.NewRepository[stored](backend, "notes")
+	changes := make(chan model.Change, 10)
+	registry := NewRegistry()
+	registry.Register(watcher{changes: changes})
+	logger := log.New(log.Error)
+
+	tests := []struct {
+		name    string
+		before  []string
+		during  []string
+		watched []string
+	}{
+		{"a new watch starts from the latest change", []string{"old"}, []string{"first"}, []string{"first"}},
+		{"a watch resumes after the last change it handled", []string{"missed"}, []string{"second"}, []string{"missed", "second"}},
+	}
+	for _, test := range tests {
+		for _, title := range test.before {
+			notes.Create(&stored{Title: title})
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		registry.Watch(ctx, backend, logger)
+		time.Sleep(20 * time.Millisecond)
+		for _, title := range test.during {
+			notes.Create(&stored{Title: title})
+		}
+		for _, expected := range test.watched {
+			select {
+			case change := <-changes:
+				if change.Type != model.Created || change.New["title"] != expected {
+					t.Errorf("%s: watched %s of %v, expected %s", test.name, change, change.New["title"], expected)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s: %s was not watched", test.name, expected)
+			}
+		}
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		select {
+		case change := <-changes:
+			t.Errorf("%s: watched %s too", test.name, change)
+		default:
+		}
+	}
+}
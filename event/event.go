@@ -0,0 +1,286 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"../log"
+	"../metrics"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// The bus lets components notify each other without knowing of each other.
+// Topics are dot separated, and patterns match them with wildcards: `*`
+// matches one segment and `**` any number of them:
+//
+//   config.reloaded     jobs.*.failed     model.**     **
+//
+// A synchronous subscriber runs in the publisher's goroutine, before Publish
+// returns. An asynchronous one has its own goroutine and a bounded queue; when
+// the queue is full the event is dropped or the publisher blocks, as chosen by
+// the subscriber. A panicking subscriber is logged and keeps its subscription,
+// the publisher and the other subscribers never see it.
+//
+// Topic gives a topic a type, so publisher and subscribers agree on the data:
+//
+//   var Reloaded = event.Topic[*config.Config]("config.reloaded")
+//   Reloaded.Subscribe(app.Events, func(settings *config.Config) { ... })
+//
+////////////////////////////////////////////////////////////////////////////////
+
+type Event struct {
+	Topic string      `json:"topic" yaml:"topic"`
+	Time  time.Time   `json:"time" yaml:"time"`
+	Data  interface{} `json:"data,omitempty" yaml:"data,omitempty"`
+}
+
+type Handler func(Event)
+
+type Policy int
+
+const (
+	// Drop discards events while the queue is full, for subscribers that must
+	// never slow publishers down.
+	Drop Policy = iota
+	// Block makes the publisher wait for room in the queue.
+	Block
+)
+
+type Bus struct {
+	Log *log.Logger
+
+	mu            sync.RWMutex
+	subscriptions []*Subscription
+	published     *metrics.Counter
+	dropped       *metrics.Counter
+	panics        *metrics.Counter
+}
+
+func New(logger *log.Logger) *Bus {
+	return &Bus{Log: logger}
+}
+
+// Instrument counts published and dropped events and subscriber panics.
+func (self *Bus) Instrument(registry *metrics.Registry) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.published = registry.Counter("events_published_total", "events published on the bus")
+	self.dropped = registry.Counter("events_dropped_total", "events dropped by full subscriber queues", "pattern")
+	self.panics = registry.Counter("events_subscriber_panics_total", "subscribers that panicked handling an event", "pattern")
+}
+
+type Subscription struct {
+	Pattern string
+
+	bus     *Bus
+	handler Handler
+	policy  Policy
+	queue   chan Event
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// Subscribe calls the handler synchronously for every event matching the
+// pattern.
+func (self *Bus) Subscribe(pattern string, handler Handler) *Subscription {
+	subscription := &Subscription{Pattern: pattern, bus: self, handler: handler, done: make(chan struct{})}
+	self.add(subscription)
+	return subscription
+}
+
+// SubscribeAsync calls the handler from its own goroutine, with at most size
+// events waiting in its queue.
+func (self *Bus) SubscribeAsync(pattern string, size int, policy Policy, handler Handler) *Subscription {
+	if size < 1 {
+		size = 1
+	}
+	subscription := &Subscription{
+		Pattern: pattern,
+		bus:     self,
+		handler: handler,
+		policy:  policy,
+		queue:   make(chan Event, size),
+		done:    make(chan struct{}),
+	}
+	self.add(subscription)
+	go subscription.run()
+	return subscription
+}
+
+func (self *Bus) add(subscription *Subscription) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	// NOTE: The slice is replaced, never changed in place, so Publish can
+	// range over it without holding the lock.
+	self.subscriptions = append(append([]*Subscription{}, self.subscriptions...), subscription)
+}
+
+func (self *Bus) remove(subscription *Subscription) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	subscriptions := make([]*Subscription, 0, len(self.subscriptions))
+	for _, existing := range self.subscriptions {
+		if existing != subscription {
+			subscriptions = append(subscriptions, existing)
+		}
+	}
+	self.subscriptions = subscriptions
+}
+
+// Publish delivers an event to the matching subscribers.
+func (self *Bus) Publish(topic string, data interface{}) {
+	event := Event{Topic: topic, Time: time.Now(), Data: data}
+	self.mu.RLock()
+	subscriptions, published := self.subscriptions, self.published
+	self.mu.RUnlock()
+	if published != nil {
+		published.Inc()
+	}
+	for _, subscription := range subscriptions {
+		if Match(subscription.Pattern, topic) {
+			subscription.publish(event)
+		}
+	}
+}
+
+// Close removes every subscription.
+func (self *Bus) Close() {
+	self.mu.RLock()
+	subscriptions := self.subscriptions
+	self.mu.RUnlock()
+	for _, subscription := range subscriptions {
+		subscription.Unsubscribe()
+	}
+}
+
+func (self *Subscription) publish(event Event) {
+	if self.queue == nil {
+		self.deliver(event)
+		return
+	}
+	if self.policy == Block {
+		select {
+		case self.queue <- event:
+		case <-self.done:
+		}
+		return
+	}
+	select {
+	case self.queue <- event:
+	default:
+		self.dropped.Add(1)
+		if counter := self.bus.dropped; counter != nil {
+			counter.Inc(self.Pattern)
+		}
+	}
+}
+
+func (self *Subscription) run() {
+	for {
+		select {
+		case event := <-self.queue:
+			self.deliver(event)
+		case <-self.done:
+			return
+		}
+	}
+}
+
+func (self *Subscription) deliver(event Event) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			self.bus.Log.Error("event subscriber panicked", "pattern", self.Pattern, "topic", event.Topic,
+				"panic", fmt.Sprint(recovered), "stack", string(debug.Stack()))
+			if counter := self.bus.panics; counter != nil {
+				counter.Inc(self.Pattern)
+			}
+		}
+	}()
+	self.handler(event)
+}
+
+// Dropped is the number of events dropped because the queue was full.
+func (self *Subscription) Dropped() uint64 { return self.dropped.Load() }
+
+// Done is closed once the subscription is removed.
+func (self *Subscription) Done() <-chan struct{} { return self.done }
+
+func (self *Subscription) Unsubscribe() {
+	self.once.Do(func() {
+		self.bus.remove(self)
+		close(self.done)
+	})
+}
+
+// Match reports whether a topic matches a pattern.
+func Match(pattern, topic string) bool {
+	return match(strings.Split(pattern, "."), strings.Split(topic, "."))
+}
+
+func match(pattern, topic []string) bool {
+	for index, segment := range pattern {
+		switch segment {
+		case "**":
+			for rest := index; rest <= len(topic); rest++ {
+				if match(pattern[index+1:], topic[rest:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+			if len(topic) <= index {
+				return false
+			}
+		default:
+			if len(topic) <= index || topic[index] != segment {
+				return false
+			}
+		}
+	}
+	return len(pattern) == len(topic)
+}
+
+// Topic //////////////////////////////////////////////////////////////////////
+// Topic is a topic whose events carry data of one type.
+type Topic[T any] string
+
+func (self Topic[T]) Publish(bus *Bus, data T) {
+	bus.Publish(string(self), data)
+}
+
+// Subscribe calls the handler synchronously with the data of every event on
+// the topic; events with data of another type are ignored.
+func (self Topic[T]) Subscribe(bus *Bus, handler func(T)) *Subscription {
+	return bus.Subscribe(string(self), func(event Event) {
+		if data, ok := event.Data.(T); ok {
+			handler(data)
+		}
+	})
+}
+
+// SubscribeAsync is Subscribe with a queue, see Bus.SubscribeAsync.
+func (self Topic[T]) SubscribeAsync(bus *Bus, size int, policy Policy, handler func(T)) *Subscription {
+	return bus.SubscribeAsync(string(self), size, policy, func(event Event) {
+		if data, ok := event.Data.(T); ok {
+			handler(data)
+		}
+	})
+}
+
+func (self Event) String() string {
+	if self.Data == nil {
+		return fmt.Sprintf("%s %s", self.Time.Format("15:04:05.000"), self.Topic)
+	}
+	data, err := json.Marshal(self.Data)
+	if err != nil {
+		return fmt.Sprintf("%s %s %v", self.Time.Format("15:04:05.000"), self.Topic, self.Data)
+	}
+	return fmt.Sprintf("%s %s %s", self.Time.Format("15:04:05.000"), self.Topic, data)
+}
@@ -0,0 +1,155 @@
+package event
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"../log"
+	"../metrics"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		match   bool
+	}{
+		{"config.reloaded", "config.reloaded", true},
+		{"config.reloaded", "config.reload", false},
+		{"config", "config.reloaded", false},
+		{"jobs.*.failed", "jobs.backup.failed", true},
+		{"jobs.*.failed", "jobs.backup.finished", false},
+		{"jobs.*.failed", "jobs.failed", false},
+		{"jobs.*", "jobs.backup.failed", false},
+		{"model.**", "model.notes.created", true},
+		{"model.**", "model", true},
+		{"model.**", "models.notes", false},
+		{"**.failed", "jobs.backup.failed", true},
+		{"a.**.z", "a.b.c.z", true},
+		{"a.**.z", "a.z", true},
+		{"a.**.z", "a.b.c", false},
+		{"**", "anything.at.all", true},
+	}
+	for _, test := range tests {
+		if Match(test.pattern, test.topic) != test.match {
+			t.Errorf("%q matching %q: %v, expected %v", test.pattern, test.topic, !test.match, test.match)
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	bus := New(log.New(log.Error))
+	var received []string
+	subscription := bus.Subscribe("jobs.**", func(event Event) { received = append(received, event.Topic) })
+	bus.Publish("jobs.backup.finished", nil)
+	bus.Publish("config.reloaded", nil)
+	bus.Publish("jobs.sync.failed", "disk full")
+	subscription.Unsubscribe()
+	subscription.Unsubscribe()
+	bus.Publish("jobs.backup.finished", nil)
+	if strings.Join(received, " ") != "jobs.backup.finished jobs.sync.failed" {
+		t.Errorf("received %v", received)
+	}
+	select {
+	case <-subscription.Done():
+	default:
+		t.Error("an unsubscribed subscription is not done")
+	}
+}
+
+func TestPanic(t *testing.T) {
+	bus := New(log.New(log.Error))
+	registry := metrics.NewRegistry()
+	bus.Instrument(registry)
+	bus.Subscribe("**", func(Event) { panic("boom") })
+	delivered := 0
+	bus.Subscribe("**", func(Event) { delivered++ })
+	bus.Publish("a", nil)
+	bus.Publish("b", nil)
+	if delivered != 2 {
+		t.Errorf("a panicking subscriber stopped delivery: %d delivered", delivered)
+	}
+	exposed := registry.Gather().String()
+	for _, line := range []string{`events_subscriber_panics_total{pattern="**"} 2`, "events_published_total 2"} {
+		if !strings.Contains(exposed, line) {
+			t.Errorf("the metrics lack %s", line)
+		}
+	}
+}
+
+func TestAsync(t *testing.T) {
+	tests := []struct {
+		policy  Policy
+		handled int
+		dropped uint64
+	}{
+		{Drop, 2, 3},
+		{Block, 5, 0},
+	}
+	for _, test := range tests {
+		bus := New(log.New(log.Error))
+		release := make(chan struct{})
+		var mu sync.Mutex
+		handled := 0
+		subscription := bus.SubscribeAsync("tick", 1, test.policy, func(Event) {
+			<-release
+			mu.Lock()
+			handled++
+			mu.Unlock()
+		})
+		published := make(chan struct{})
+		go func() {
+			for index := 0; index < 5; index++ {
+				bus.Publish("tick", index)
+				// NOTE: Let the subscriber take the first event off the queue.
+				if index == 0 {
+					time.Sleep(20 * time.Millisecond)
+				}
+			}
+			close(published)
+		}()
+		if test.policy == Block {
+			select {
+			case <-published:
+				t.Error("a blocking subscriber did not hold back the publisher")
+			case <-time.After(50 * time.Millisecond):
+			}
+		} else {
+			<-published
+		}
+		close(release)
+		<-published
+		deadline := time.Now().Add(time.Second)
+		for {
+			mu.Lock()
+			count := handled
+			mu.Unlock()
+			if count == test.handled || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		mu.Lock()
+		if handled != test.handled || subscription.Dropped() != test.dropped {
+			t.Errorf("policy %d: handled %d, dropped %d, expected %d and %d", test.policy,
+				handled, subscription.Dropped(), test.handled, test.dropped)
+		}
+		mu.Unlock()
+		bus.Close()
+	}
+}
+
+func TestTopic(t *testing.T) {
+	type reloaded struct{ Version int }
+	topic := Topic[reloaded]("config.reloaded")
+	bus := New(log.New(log.Error))
+	var versions []int
+	topic.Subscribe(bus, func(data reloaded) { versions = append(versions, data.Version) })
+	topic.Publish(bus, reloaded{Version: 2})
+	bus.Publish("config.reloaded", "not the topic type")
+	if len(versions) != 1 || versions[0] != 2 {
+		t.Errorf("received %v", versions)
+	}
+}
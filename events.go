@@ -0,0 +1,95 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"./config"
+	"./event"
+	"./fault"
+	"./rpc"
+	"./scheduler"
+)
+
+// Built-in topics. Jobs publish on `jobs.<name>.finished` and
+// `jobs.<name>.failed`, with the scheduler.Run as data.
+var (
+	ConfigReloaded = event.Topic[*config.Config]("config.reloaded")
+	Started        = event.Topic[VersionResult]("app.ready")
+	Stopping       = event.Topic[VersionResult]("app.stopping")
+)
+
+type EventsParams struct {
+	Pattern string `json:"pattern,omitempty"`
+	// NOTE: Queue is how many events may wait for a slow client before they
+	// are dropped; the bus never waits for a client.
+	Queue int `json:"queue,omitempty"`
+}
+
+type EventsResult struct {
+	Dropped uint64 `json:"dropped" yaml:"dropped"`
+}
+
+func (self EventsResult) String() string {
+	if self.Dropped == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d events dropped", self.Dropped)
+}
+
+func (self *Application) publishRun(run scheduler.Run) {
+	result := "finished"
+	if run.Failed() {
+		result = "failed"
+	}
+	self.Events.Publish("jobs."+run.Job+"."+result, run)
+}
+
+// streamEvents streams the events matching a pattern to a control socket
+// client until it disconnects or the daemon stops. Events already queued when
+// the daemon stops, such as app.stopping, are still sent.
+func (self *Application) streamEvents(ctx context.Context, params EventsParams) (EventsResult, error) {
+	if !rpc.Streaming(ctx) {
+		return EventsResult{}, fault.UsageError("usage.streaming", "events is a stream, it must be called over a connection with an id")
+	}
+	if params.Pattern == "" {
+		params.Pattern = "**"
+	}
+	if params.Queue <= 0 {
+		params.Queue = 256
+	}
+	// NOTE: The queue is drained here rather than by an async subscriber so
+	// the stream can flush it before returning its result.
+	var result EventsResult
+	queue := make(chan event.Event, params.Queue)
+	subscription := self.Events.Subscribe(params.Pattern, func(received event.Event) {
+		select {
+		case queue <- received:
+		default:
+			atomic.AddUint64(&result.Dropped, 1)
+		}
+	})
+	defer subscription.Unsubscribe()
+	for {
+		select {
+		case received := <-queue:
+			if err := rpc.Send(ctx, received); err != nil {
+				return result, nil
+			}
+		case <-ctx.Done():
+			subscription.Unsubscribe()
+			for {
+				select {
+				case received := <-queue:
+					if err := rpc.Send(ctx, received); err != nil {
+						return result, nil
+					}
+				default:
+					result.Dropped = atomic.LoadUint64(&result.Dropped)
+					return result, nil
+				}
+			}
+		}
+	}
+}
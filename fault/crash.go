@@ -0,0 +1,48 @@
+package fault
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Crash converts a recovered panic into an internal error. The stack trace is
+// written to a crash report in the given directory instead of the terminal;
+// the error only points at the report.
+func Crash(recovered interface{}, stack []byte, directory string) *Error {
+	err := InternalError("internal.panic", "unexpected failure: %v", recovered)
+	if cause, ok := recovered.(error); ok {
+		err.Cause = cause
+		err.Message = "unexpected failure"
+	}
+	path, writeErr := WriteCrashReport(directory, recovered, stack)
+	if writeErr != nil {
+		return err.WithHint("this is a bug, please report it (the crash report could not be written: %v)", writeErr)
+	}
+	return err.WithHint("this is a bug, please report it and include the crash report: %s", path)
+}
+
+func WriteCrashReport(directory string, recovered interface{}, stack []byte) (string, error) {
+	if directory == "" {
+		directory = os.TempDir()
+	}
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(directory, fmt.Sprintf("crash-%s-%d.txt", now.Format("20060102-150405"), os.Getpid()))
+
+	var report strings.Builder
+	fmt.Fprintf(&report, "time:      %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&report, "panic:     %v\n", recovered)
+	fmt.Fprintf(&report, "arguments: %q\n", os.Args)
+	fmt.Fprintf(&report, "go:        %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&report, "pid:       %d\n\n", os.Getpid())
+	report.Write(stack)
+
+	return path, ioutil.WriteFile(path, []byte(report.String()), 0600)
+}
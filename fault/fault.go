@@ -0,0 +1,246 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Errors must be correct. Every error leaving a command is classified into a
+// small taxonomy, each class maps to a `sysexits.h` exit code, carries a hint
+// for humans and a stable machine code for the json/yaml output:
+//
+//   class         exit code             example machine code
+//   usage         64  EX_USAGE          usage.unknown_command
+//   config        78  EX_CONFIG         config.invalid
+//   unavailable   69  EX_UNAVAILABLE    unavailable.daemon
+//   permission    77  EX_NOPERM         permission.denied
+//   io            74  EX_IOERR          io.not_found
+//   internal      70  EX_SOFTWARE       internal.panic
+//
+////////////////////////////////////////////////////////////////////////////////
+
+type Class int
+
+const (
+	Internal Class = iota
+	Usage
+	Config
+	Unavailable
+	Permission
+	IO
+)
+
+// sysexits.h
+const (
+	ExitOK          = 0
+	ExitUsage       = 64
+	ExitUnavailable = 69
+	ExitSoftware    = 70
+	ExitIO          = 74
+	ExitPermission  = 77
+	ExitConfig      = 78
+)
+
+func (self Class) String() string {
+	switch self {
+	case Usage:
+		return "usage"
+	case Config:
+		return "config"
+	case Unavailable:
+		return "unavailable"
+	case Permission:
+		return "permission"
+	case IO:
+		return "io"
+	default:
+		return "internal"
+	}
+}
+
+func (self Class) ExitCode() int {
+	switch self {
+	case Usage:
+		return ExitUsage
+	case Config:
+		return ExitConfig
+	case Unavailable:
+		return ExitUnavailable
+	case Permission:
+		return ExitPermission
+	case IO:
+		return ExitIO
+	default:
+		return ExitSoftware
+	}
+}
+
+func (self Class) Hint() string {
+	switch self {
+	case Usage:
+		return "run with --help to see the available commands and flags"
+	case Config:
+		return "check the configuration file and environment variables"
+	case Unavailable:
+		return "the service may not be running or is busy, try again later"
+	case Permission:
+		return "check the permissions of the path and the user running the command"
+	case IO:
+		return "check that the path exists and the disk is readable and writable"
+	default:
+		return "this is a bug, please report it"
+	}
+}
+
+func ParseClass(name string) Class {
+	for _, class := range []Class{Usage, Config, Unavailable, Permission, IO} {
+		if class.String() == name {
+			return class
+		}
+	}
+	return Internal
+}
+
+// Error //////////////////////////////////////////////////////////////////////
+type Error struct {
+	Class   Class
+	Code    string
+	Message string
+	Hint    string
+	Cause   error
+}
+
+func New(class Class, code, format string, args ...interface{}) *Error {
+	return &Error{Class: class, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func Wrap(cause error, class Class, code, format string, args ...interface{}) *Error {
+	err := New(class, code, format, args...)
+	err.Cause = cause
+	return err
+}
+
+func (self *Error) Error() string {
+	if self.Cause != nil {
+		return fmt.Sprintf("%s: %v", self.Message, self.Cause)
+	}
+	return self.Message
+}
+
+func (self *Error) Unwrap() error { return self.Cause }
+
+func (self *Error) WithHint(format string, args ...interface{}) *Error {
+	self.Hint = fmt.Sprintf(format, args...)
+	return self
+}
+
+func (self *Error) HintText() string {
+	if self.Hint != "" {
+		return self.Hint
+	}
+	return self.Class.Hint()
+}
+
+func (self *Error) ExitCode() int { return self.Class.ExitCode() }
+
+// HTTPStatus is the status an HTTP front end answers the error with.
+func (self *Error) HTTPStatus() int {
+	switch {
+	case self.Code == "usage.invalid":
+		return 422
+	case self.Code == "unavailable.conflict":
+		return 409
+	case self.Code == "io.not_found", self.Code == "usage.unknown_action":
+		return 404
+	case self.Code == "usage.method_not_allowed":
+		return 405
+	}
+	switch self.Class {
+	case Usage:
+		return 400
+	case Permission:
+		return 403
+	case Unavailable:
+		return 503
+	default:
+		return 500
+	}
+}
+
+// Constructors ///////////////////////////////////////////////////////////////
+func UsageError(code, format string, args ...interface{}) *Error {
+	return New(Usage, code, format, args...)
+}
+
+func ConfigError(code, format string, args ...interface{}) *Error {
+	return New(Config, code, format, args...)
+}
+
+func UnavailableError(code, format string, args ...interface{}) *Error {
+	return New(Unavailable, code, format, args...)
+}
+
+func PermissionError(code, format string, args ...interface{}) *Error {
+	return New(Permission, code, format, args...)
+}
+
+func IOError(code, format string, args ...interface{}) *Error {
+	return New(IO, code, format, args...)
+}
+
+func InternalError(code, format string, args ...interface{}) *Error {
+	return New(Internal, code, format, args...)
+}
+
+// Classification /////////////////////////////////////////////////////////////
+// As returns the classified form of any error. Errors that are not already a
+// *Error are classified from well known standard library errors, anything
+// unknown is an internal error.
+func As(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return classified
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, os.ErrPermission), errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return Wrap(err, Permission, "permission.denied", "permission denied")
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ENOENT) && isNetwork(err):
+		return Wrap(err, Unavailable, "unavailable.connection_refused", "connection refused")
+	case errors.Is(err, os.ErrNotExist):
+		return Wrap(err, IO, "io.not_found", "not found")
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return Wrap(err, Unavailable, "unavailable.timeout", "timed out")
+	case errors.As(err, new(*os.PathError)), errors.As(err, new(*os.LinkError)), errors.As(err, new(*os.SyscallError)):
+		return Wrap(err, IO, "io.failed", "input/output failure")
+	}
+	return &Error{Class: Internal, Code: "internal.error", Message: err.Error()}
+}
+
+func isNetwork(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+func ClassOf(err error) Class {
+	if err == nil {
+		return Internal
+	}
+	return As(err).Class
+}
+
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	return As(err).ExitCode()
+}
@@ -0,0 +1,123 @@
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err      error
+		class    Class
+		code     string
+		exitCode int
+	}{
+		{UsageError("usage.unknown_command", "unknown command"), Usage, "usage.unknown_command", 64},
+		{ConfigError("config.invalid", "invalid"), Config, "config.invalid", 78},
+		{UnavailableError("unavailable.daemon", "not running"), Unavailable, "unavailable.daemon", 69},
+		{PermissionError("permission.denied", "denied"), Permission, "permission.denied", 77},
+		{IOError("io.failed", "failed"), IO, "io.failed", 74},
+		{InternalError("internal.panic", "panic"), Internal, "internal.panic", 70},
+		{fmt.Errorf("loading: %w", ConfigError("config.invalid", "invalid")), Config, "config.invalid", 78},
+		{&os.PathError{Op: "open", Path: "/x", Err: os.ErrPermission}, Permission, "permission.denied", 77},
+		{&os.PathError{Op: "open", Path: "/x", Err: syscall.EACCES}, Permission, "permission.denied", 77},
+		{&os.PathError{Op: "open", Path: "/x", Err: os.ErrNotExist}, IO, "io.not_found", 74},
+		{&os.PathError{Op: "read", Path: "/x", Err: syscall.EIO}, IO, "io.failed", 74},
+		{&net.OpError{Op: "dial", Net: "unix", Err: os.NewSyscallError("connect", syscall.ENOENT)}, Unavailable, "unavailable.connection_refused", 69},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, Unavailable, "unavailable.connection_refused", 69},
+		{fmt.Errorf("waiting: %w", context.DeadlineExceeded), Unavailable, "unavailable.timeout", 69},
+		{errors.New("anything else"), Internal, "internal.error", 70},
+	}
+	for _, test := range tests {
+		classified := As(test.err)
+		if classified.Class != test.class || classified.Code != test.code {
+			t.Errorf("%v classified as %s %s, expected %s %s", test.err, classified.Class, classified.Code, test.class, test.code)
+		}
+		if ExitCode(test.err) != test.exitCode {
+			t.Errorf("%v exits with %d, expected %d", test.err, ExitCode(test.err), test.exitCode)
+		}
+		if ClassOf(test.err) != test.class {
+			t.Errorf("%v is of class %s, expected %s", test.err, ClassOf(test.err), test.class)
+		}
+	}
+	if As(nil) != nil || ExitCode(nil) != ExitOK {
+		t.Error("nil is classified as an error")
+	}
+}
+
+func TestParseClass(t *testing.T) {
+	for _, class := range []Class{Internal, Usage, Config, Unavailable, Permission, IO} {
+		if parsed := ParseClass(class.String()); parsed != class {
+			t.Errorf("%s parsed as %s", class, parsed)
+		}
+	}
+	if ParseClass("unknown") != Internal {
+		t.Error("an unknown class is not internal")
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err    *Error
+		status int
+	}{
+		{UsageError("usage.invalid", ""), 422},
+		{UsageError("usage.unknown_action", ""), 404},
+		{UsageError("usage.method_not_allowed", ""), 405},
+		{UsageError("usage.invalid_query", ""), 400},
+		{UnavailableError("unavailable.conflict", ""), 409},
+		{UnavailableError("unavailable.timeout", ""), 503},
+		{IOError("io.not_found", ""), 404},
+		{IOError("io.failed", ""), 500},
+		{PermissionError("permission.denied", ""), 403},
+		{InternalError("internal.panic", ""), 500},
+	}
+	for _, test := range tests {
+		if status := test.err.HTTPStatus(); status != test.status {
+			t.Errorf("%s answers %d, expected %d", test.err.Code, status, test.status)
+		}
+	}
+}
+
+func TestMessageAndHint(t *testing.T) {
+	cause := errors.New("disk full")
+	err := Wrap(cause, IO, "io.failed", "failed to write %s", "notes")
+	if err.Error() != "failed to write notes: disk full" {
+		t.Errorf("message %q", err.Error())
+	}
+	if !errors.Is(err, cause) {
+		t.Error("the cause is not unwrapped")
+	}
+	if err.HintText() != IO.Hint() {
+		t.Errorf("default hint %q", err.HintText())
+	}
+	if err.WithHint("free %d bytes", 10).HintText() != "free 10 bytes" {
+		t.Errorf("hint %q", err.HintText())
+	}
+}
+
+func TestCrash(t *testing.T) {
+	directory := t.TempDir()
+	err := Crash("boom", []byte("goroutine 1 [running]:\n"), directory)
+	if err.Code != "internal.panic" || err.ExitCode() != ExitSoftware || err.Message != "unexpected failure: boom" {
+		t.Errorf("crash classified as %s %d %q", err.Code, err.ExitCode(), err.Message)
+	}
+	path := strings.TrimPrefix(err.HintText(), "this is a bug, please report it and include the crash report: ")
+	report, readErr := os.ReadFile(path)
+	if readErr != nil {
+		t.Fatalf("the crash report %q was not written: %v", path, readErr)
+	}
+	if !strings.Contains(string(report), "panic:     boom\n") || !strings.HasSuffix(string(report), "goroutine 1 [running]:\n") {
+		t.Errorf("crash report %q", report)
+	}
+	cause := errors.New("nil map")
+	if err := Crash(cause, nil, directory); !errors.Is(err, cause) || err.Message != "unexpected failure" {
+		t.Errorf("a panic with an error is %v", err)
+	}
+}
@@ -13,33 +13,35 @@ type Directory struct {
 }
 
 func (self *Directory) NewDirectory(name string) error {
-	path := filepath.Join(self.Path, name)
+	path := filepath.Join(string(self.Path), name)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		err := os.MkdirAll(path, os.FileMode(0770))
 		if err != nil {
 			return err
 		} else {
 			self.Directories = append(self.Directories, &Directory{
-				Path:        path,
+				Path:        Path(path),
 				Directories: []*Directory{},
 				Files:       []*File{},
 			})
 		}
 	}
+	return nil
 }
 
 // TODO: Should maybe overrite?
 func (self *Directory) NewFile(name string, data []byte) error {
-	path := filepath.Join(self.Path, name)
+	path := filepath.Join(string(self.Path), name)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		err := ioutil.WriteFile(path, data, 0644)
 		if err != nil {
 			return err
 		} else {
 			self.Files = append(self.Files, &File{
-				Path: path,
+				Path: Path(path),
 				Data: data,
 			})
 		}
 	}
+	return nil
 }
@@ -0,0 +1,210 @@
+package filesystem
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Records are the append-log format: a 16 byte header then the data, padded
+// to PAD bytes so records start on PAD boundaries and offsets can be counted
+// in PAD units, which lets a uint32 address 64GiB.
+//
+//   0       4          8                16
+//   | length | checksum | reserved (zero) | data ... | padding |
+//
+// The checksum is a CRC-32C of the header, without the checksum, and the
+// data; a torn or corrupt record fails it with EBADSLT and scanning skips to
+// the next valid record.
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	PAD        = 16
+	headerSize = 16
+	// NOTE: A length beyond this is a corrupt header, not a record.
+	maxRecordSize = 1 << 30
+)
+
+var EBADSLT = errors.New("checksum mismatch")
+var EINVAL = errors.New("invalid argument")
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+type Reader struct {
+	file      *os.File
+	blockSize int
+}
+
+// Create New AppendReader (you just nice wrapper around ReadFromReader adn ScanFromReader)
+// it is *safe* to use it concurrently
+// Example usage
+//
+//	r, err := NewReader(filename, 4096)
+//	if err != nil {
+//		panic(err)
+//	}
+//	// read specific offset
+//	data, _, err := r.Read(docID)
+//	if err != nil {
+//		panic(err)
+//	}
+//	// scan from specific offset
+//	err = r.Scan(0, func(data []byte, offset, next uint32) error {
+//		log.Printf("%v",data)
+//		return nil
+//	})
+//
+// each Read requires 2 syscalls, one to read the header and one to read the data (since the length of the data is in the header).
+// You can reduce that to 1 syscall if your data fits within 1 block, do not set blockSize < 16 because this is the header length.
+// blockSize 0 means 16
+func NewReader(filename string, blockSize int) (*Reader, error) {
+	if blockSize == 0 {
+		blockSize = 16
+	}
+	if blockSize < 16 {
+		return nil, EINVAL
+	}
+
+	fd, err := os.OpenFile(filename, os.O_RDONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewReaderFromFile(fd, blockSize)
+}
+
+func NewReaderFromFile(fd *os.File, blockSize int) (*Reader, error) {
+	if blockSize == 0 {
+		blockSize = 16
+	}
+	if blockSize < 16 {
+		return nil, EINVAL
+	}
+
+	return &Reader{
+		file:      fd,
+		blockSize: blockSize,
+	}, nil
+}
+
+// Scan the open file, if the callback returns error this error is returned as the Scan error. just a wrapper around ScanFromReader.
+func (ar *Reader) Scan(offset uint32, cb func([]byte, uint32, uint32) error) error {
+	return ScanFromReader(ar.file, offset, ar.blockSize, cb)
+}
+
+// Read at specific offset (just wrapper around ReadFromReader), returns the data, next readable offset and error
+func (ar *Reader) Read(offset uint32) ([]byte, uint32, error) {
+	return ReadFromReader(ar.file, offset, ar.blockSize)
+}
+
+func (ar *Reader) Close() error {
+	return ar.file.Close()
+}
+
+// Reads specific offset. returns data, nextOffset, error. You can
+// ReadFromReader(nextOffset) if you want to read the next document, or
+// use the Scan() helper
+func ReadFromReader(reader io.ReaderAt, offset uint32, blockSize int) ([]byte, uint32, error) {
+	b, err := ReadFromReader64(reader, uint64(offset)*PAD, blockSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b, offset + RecordSize(len(b)), nil
+}
+
+// ReadFromReader64 reads the record at a byte offset. The end of the file, or
+// a header cut short by it, is io.EOF.
+func ReadFromReader64(reader io.ReaderAt, offset uint64, blockSize int) ([]byte, error) {
+	if blockSize < headerSize {
+		blockSize = headerSize
+	}
+	block := make([]byte, blockSize)
+	n, err := reader.ReadAt(block, int64(offset))
+	if n < headerSize {
+		if err == nil || err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(block[0:4])
+	if maxRecordSize < length {
+		return nil, EBADSLT
+	}
+	data := make([]byte, length)
+	copied := copy(data, block[headerSize:n])
+	if copied < len(data) {
+		read, err := reader.ReadAt(data[copied:], int64(offset)+int64(headerSize+copied))
+		if read < len(data)-copied {
+			if err == nil || err == io.EOF {
+				// NOTE: A record cut short is corrupt like any other, there
+				// may be valid records after a torn write.
+				return nil, EBADSLT
+			}
+			return nil, err
+		}
+	}
+	if binary.LittleEndian.Uint32(block[4:8]) != checksum(block[:headerSize], data) {
+		return nil, EBADSLT
+	}
+	return data, nil
+}
+
+// Scan ReaderAt, if the callback returns error this error is returned as the Scan error
+func ScanFromReader(reader io.ReaderAt, offset uint32, blockSize int, cb func([]byte, uint32, uint32) error) error {
+	for {
+		data, next, err := ReadFromReader(reader, offset, blockSize)
+		if err == io.EOF {
+			return nil
+		}
+		if err == EBADSLT {
+			// assume corrupted file, so just skip until we find next valid entry
+			offset++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = cb(data, offset, next)
+		if err != nil {
+			return err
+		}
+		offset = next
+	}
+}
+
+// Writing ////////////////////////////////////////////////////////////////////
+// RecordSize is the size of a record holding length bytes, in PAD units.
+func RecordSize(length int) uint32 {
+	return uint32((headerSize + length + PAD - 1) / PAD)
+}
+
+// EncodeRecord frames data as a record, padding included.
+func EncodeRecord(data []byte) []byte {
+	record := make([]byte, int(RecordSize(len(data)))*PAD)
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(data)))
+	copy(record[headerSize:], data)
+	binary.LittleEndian.PutUint32(record[4:8], checksum(record[:headerSize], data))
+	return record
+}
+
+// WriteRecord writes a record at offset, in PAD units, and returns the offset
+// after it. The write is not synced.
+func WriteRecord(writer io.WriterAt, offset uint32, data []byte) (uint32, error) {
+	if maxRecordSize < len(data) {
+		return offset, EINVAL
+	}
+	record := EncodeRecord(data)
+	if _, err := writer.WriteAt(record, int64(offset)*PAD); err != nil {
+		return offset, err
+	}
+	return offset + uint32(len(record)/PAD), nil
+}
+
+func checksum(header, data []byte) uint32 {
+	sum := crc32.Update(0, castagnoli, header[0:4])
+	sum = crc32.Update(sum, castagnoli, header[8:headerSize])
+	return crc32.Update(sum, castagnoli, data)
+}
@@ -0,0 +1,202 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Subsystems register checks instead of the daemon guessing its own health.
+// Liveness answers "should this process be restarted", readiness answers
+// "should it receive work"; a process that is not alive is not ready either,
+// so readiness runs both kinds of checks. Results can be cached so an
+// expensive check is not run for every probe.
+////////////////////////////////////////////////////////////////////////////////
+
+type Kind int
+
+const (
+	Liveness Kind = iota
+	Readiness
+)
+
+func (self Kind) String() string {
+	if self == Readiness {
+		return "readiness"
+	}
+	return "liveness"
+}
+
+const (
+	Pass = "pass"
+	Fail = "fail"
+)
+
+const DefaultTimeout = 5 * time.Second
+
+type Check struct {
+	Name    string
+	Kind    Kind
+	Timeout time.Duration
+	// NOTE: Cache is how long a result is reused before running the check
+	// again; 0 runs the check on every probe.
+	Cache time.Duration
+	Run   func(ctx context.Context) error
+}
+
+type Result struct {
+	Name     string    `json:"name" yaml:"name"`
+	Kind     string    `json:"kind" yaml:"kind"`
+	Status   string    `json:"status" yaml:"status"`
+	Error    string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Duration string    `json:"duration" yaml:"duration"`
+	Checked  time.Time `json:"checked" yaml:"checked"`
+	Cached   bool      `json:"cached" yaml:"cached"`
+}
+
+type Report struct {
+	Kind   string   `json:"kind" yaml:"kind"`
+	Status string   `json:"status" yaml:"status"`
+	Checks []Result `json:"checks" yaml:"checks"`
+}
+
+func (self Report) Passing() bool { return self.Status == Pass }
+
+func (self Report) String() string {
+	text := fmt.Sprintf("%s: %s\n", self.Kind, self.Status)
+	for _, check := range self.Checks {
+		text += fmt.Sprintf("  [%s] %-20s %s", check.Status, check.Name, check.Duration)
+		if check.Error != "" {
+			text += "  " + check.Error
+		}
+		text += "\n"
+	}
+	return text
+}
+
+// Registry ///////////////////////////////////////////////////////////////////
+type Registry struct {
+	mu     sync.Mutex
+	checks []*entry
+}
+
+type entry struct {
+	check Check
+	mu    sync.Mutex
+	last  *Result
+}
+
+func New() *Registry { return &Registry{} }
+
+func (self *Registry) Register(check Check) {
+	if check.Timeout == 0 {
+		check.Timeout = DefaultTimeout
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.checks = append(self.checks, &entry{check: check})
+}
+
+func (self *Registry) Liveness(ctx context.Context) Report  { return self.Run(ctx, Liveness) }
+func (self *Registry) Readiness(ctx context.Context) Report { return self.Run(ctx, Readiness) }
+
+// Run runs the checks of a kind concurrently, each bounded by its timeout.
+func (self *Registry) Run(ctx context.Context, kind Kind) Report {
+	self.mu.Lock()
+	var entries []*entry
+	for _, registered := range self.checks {
+		if registered.check.Kind <= kind {
+			entries = append(entries, registered)
+		}
+	}
+	self.mu.Unlock()
+
+	report := Report{Kind: kind.String(), Status: Pass, Checks: make([]Result, len(entries))}
+	var wait sync.WaitGroup
+	for index := range entries {
+		wait.Add(1)
+		go func(index int) {
+			defer wait.Done()
+			report.Checks[index] = entries[index].run(ctx)
+		}(index)
+	}
+	wait.Wait()
+	for _, result := range report.Checks {
+		if result.Status != Pass {
+			report.Status = Fail
+		}
+	}
+	sort.Slice(report.Checks, func(i, j int) bool { return report.Checks[i].Name < report.Checks[j].Name })
+	return report
+}
+
+func (self *entry) run(ctx context.Context) Result {
+	// NOTE: Holding the entry lock while running also collapses concurrent
+	// probes of the same check into a single run when it is cached.
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.last != nil && time.Since(self.last.Checked) < self.check.Cache {
+		cached := *self.last
+		cached.Cached = true
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, self.check.Timeout)
+	defer cancel()
+	started := time.Now()
+	finished := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				finished <- fmt.Errorf("check panicked: %v", recovered)
+			}
+		}()
+		finished <- self.check.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-finished:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", self.check.Timeout)
+	}
+	result := Result{
+		Name:     self.check.Name,
+		Kind:     self.check.Kind.String(),
+		Status:   Pass,
+		Duration: time.Since(started).Round(time.Microsecond).String(),
+		Checked:  started,
+	}
+	if err != nil {
+		result.Status, result.Error = Fail, err.Error()
+	}
+	self.last = &result
+	return result
+}
+
+// HTTP ///////////////////////////////////////////////////////////////////////
+// Handler answers 200 when the checks of the kind pass and 503 otherwise, with
+// the report as json.
+func (self *Registry) Handler(kind Kind) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := self.Run(r.Context(), kind)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !report.Passing() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Mount adds the conventional `/healthz` and `/readyz` endpoints to a mux.
+func (self *Registry) Mount(mux *http.ServeMux) {
+	mux.Handle("/healthz", self.Handler(Liveness))
+	mux.Handle("/readyz", self.Handler(Readiness))
+}
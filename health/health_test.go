@@ -0,0 +1,138 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		checks    []Check
+		liveness  string
+		readiness string
+		failing   []string
+	}{
+		{"no checks", nil, Pass, Pass, nil},
+		{
+			"passing",
+			[]Check{
+				{Name: "alive", Kind: Liveness, Run: func(context.Context) error { return nil }},
+				{Name: "store", Kind: Readiness, Run: func(context.Context) error { return nil }},
+			},
+			Pass, Pass, nil,
+		},
+		{
+			"not ready",
+			[]Check{
+				{Name: "alive", Kind: Liveness, Run: func(context.Context) error { return nil }},
+				{Name: "store", Kind: Readiness, Run: func(context.Context) error { return errors.New("locked") }},
+			},
+			Pass, Fail, []string{"store"},
+		},
+		{
+			"dead is not ready",
+			[]Check{
+				{Name: "alive", Kind: Liveness, Run: func(context.Context) error { return errors.New("deadlocked") }},
+			},
+			Fail, Fail, []string{"alive"},
+		},
+		{
+			"timeout",
+			[]Check{
+				{Name: "slow", Kind: Liveness, Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				}},
+			},
+			Fail, Fail, []string{"slow"},
+		},
+		{
+			"panic",
+			[]Check{
+				{Name: "broken", Kind: Readiness, Run: func(context.Context) error { panic("boom") }},
+			},
+			Pass, Fail, []string{"broken"},
+		},
+	}
+	for _, test := range tests {
+		registry := New()
+		for _, check := range test.checks {
+			registry.Register(check)
+		}
+		liveness, readiness := registry.Liveness(context.Background()), registry.Readiness(context.Background())
+		if liveness.Status != test.liveness || readiness.Status != test.readiness {
+			t.Errorf("%s: liveness %s, readiness %s, expected %s, %s", test.name,
+				liveness.Status, readiness.Status, test.liveness, test.readiness)
+		}
+		var failing []string
+		for _, result := range readiness.Checks {
+			if result.Status == Fail {
+				failing = append(failing, result.Name)
+				if result.Error == "" {
+					t.Errorf("%s: %s failed without an error", test.name, result.Name)
+				}
+			}
+		}
+		if len(failing) != len(test.failing) || 0 < len(failing) && failing[0] != test.failing[0] {
+			t.Errorf("%s: failing %v, expected %v", test.name, failing, test.failing)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	var runs atomic.Int32
+	registry := New()
+	registry.Register(Check{Name: "counted", Cache: time.Hour, Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	first := registry.Liveness(context.Background())
+	second := registry.Liveness(context.Background())
+	if runs.Load() != 1 {
+		t.Errorf("a cached check ran %d times", runs.Load())
+	}
+	if first.Checks[0].Cached || !second.Checks[0].Cached {
+		t.Errorf("cached %v then %v", first.Checks[0].Cached, second.Checks[0].Cached)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ready := false
+	registry := New()
+	registry.Register(Check{Name: "store", Kind: Readiness, Run: func(context.Context) error {
+		if !ready {
+			return errors.New("not open yet")
+		}
+		return nil
+	}})
+	mux := http.NewServeMux()
+	registry.Mount(mux)
+	tests := []struct {
+		path   string
+		ready  bool
+		status int
+	}{
+		{"/healthz", false, 200},
+		{"/readyz", false, 503},
+		{"/readyz", true, 200},
+	}
+	for _, test := range tests {
+		ready = test.ready
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest("GET", test.path, nil))
+		var report Report
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if recorder.Code != test.status || report.Passing() != (test.status == 200) {
+			t.Errorf("%s answered %d %s, expected %d", test.path, recorder.Code, report.Status, test.status)
+		}
+	}
+}
@@ -5,7 +5,7 @@ import (
 )
 
 type IO struct {
-	Output *io.Writer
-	Error  *io.Writer
-	Input  *io.Reader
+	Output io.Writer
+	Error  io.Writer
+	Input  io.Reader
 }
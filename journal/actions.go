@@ -0,0 +1,30 @@
+package journal
+
+import (
+	"context"
+
+	"../controller"
+)
+
+type Selection struct {
+	ID uint64 `json:"id" bind:"arg" help:"the change, from history; the latest by default"`
+}
+
+type HistoryParams struct {
+	Limit int `json:"limit" help:"how many changes to list" default:"20" validate:"min=0"`
+}
+
+// Actions are undo, redo and history, for any interface to present.
+func (self *Journal) Actions() controller.Actions {
+	return controller.Actions{
+		controller.NewAction("undo", "put back what a change did, unless it was changed since", func(ctx context.Context, selection Selection) (Entry, error) {
+			return self.Undo(ctx, selection.ID)
+		}).With("journal", "false").With("dry_run", "true"),
+		controller.NewAction("redo", "do an undone change again", func(ctx context.Context, selection Selection) (Entry, error) {
+			return self.Redo(ctx, selection.ID)
+		}).With("journal", "false").With("dry_run", "true"),
+		controller.NewAction("history", "list the changes that can be undone or redone, latest first", func(ctx context.Context, params HistoryParams) (Entries, error) {
+			return self.Entries(params.Limit)
+		}).With("method", "GET"),
+	}
+}
@@ -0,0 +1,270 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"../fault"
+	"../kv"
+	"../model"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// The journal keeps what the actions that change user data did, so it can be
+// undone: every model written through a repository bound to the action's
+// context, and every file written with WriteFile or removed with Remove,
+// with what it was before and after.
+//
+//   func (self Notes) add(ctx context.Context, note Note) (Note, error) {
+//     err := self.notes.In(ctx).Create(&note)
+//     return note, err
+//   }
+//
+// `app-cli undo` puts back what the latest entry changed and `app-cli redo`
+// does it again, `app-cli history` lists the entries. An entry is only undone
+// if nothing it changed was changed since, so an undo never overwrites later
+// work; a new entry drops the entries that could be redone.
+//
+// The journal is a key-value store in the state directory, shared by the cli
+// and the daemon. It keeps the latest Keep entries.
+////////////////////////////////////////////////////////////////////////////////
+
+const Keep = 100
+
+type StepKind string
+
+const (
+	ModelStep StepKind = "model"
+	FileStep  StepKind = "file"
+)
+
+// Step is one thing an entry changed, as it was before and after.
+type Step struct {
+	Kind StepKind `json:"kind" yaml:"kind"`
+
+	Collection string         `json:"collection,omitempty" yaml:"collection,omitempty"`
+	ID         string         `json:"id,omitempty" yaml:"id,omitempty"`
+	Old        model.Document `json:"old,omitempty" yaml:"old,omitempty"`
+	New        model.Document `json:"new,omitempty" yaml:"new,omitempty"`
+	// NOTE: Revision is the revision the model is at while the step is done,
+	// or undone; zero when there is no model.
+	Revision uint64 `json:"revision,omitempty" yaml:"revision,omitempty"`
+
+	Path    string `json:"path,omitempty" yaml:"path,omitempty"`
+	OldFile *File  `json:"old_file,omitempty" yaml:"old_file,omitempty"`
+	NewFile *File  `json:"new_file,omitempty" yaml:"new_file,omitempty"`
+}
+
+// File is the content of a file a step wrote or removed.
+type File struct {
+	Data []byte      `json:"data" yaml:"data"`
+	Mode os.FileMode `json:"mode" yaml:"mode"`
+}
+
+func (self Step) String() string {
+	if self.Kind == FileStep {
+		switch {
+		case self.OldFile == nil:
+			return "created " + self.Path
+		case self.NewFile == nil:
+			return "removed " + self.Path
+		}
+		return "wrote " + self.Path
+	}
+	switch {
+	case self.Old == nil:
+		return fmt.Sprintf("created %s %s", self.Collection, self.ID)
+	case self.New == nil:
+		return fmt.Sprintf("deleted %s %s", self.Collection, self.ID)
+	}
+	return fmt.Sprintf("updated %s %s", self.Collection, self.ID)
+}
+
+// same is whether two steps change the same model or file.
+func (self Step) same(other Step) bool {
+	if self.Kind == FileStep {
+		return other.Kind == FileStep && self.Path == other.Path
+	}
+	return other.Kind == ModelStep && self.Collection == other.Collection && self.ID == other.ID
+}
+
+type Entry struct {
+	ID     uint64    `json:"id" yaml:"id"`
+	Action string    `json:"action" yaml:"action"`
+	Time   time.Time `json:"time" yaml:"time"`
+	Steps  []Step    `json:"steps" yaml:"steps"`
+	// NOTE: Error is set when the action failed after changing something.
+	Error  string    `json:"error,omitempty" yaml:"error,omitempty"`
+	Undone time.Time `json:"undone,omitempty" yaml:"undone,omitempty"`
+}
+
+func (self Entry) IsUndone() bool { return !self.Undone.IsZero() }
+
+func (self Entry) String() string {
+	steps := make([]string, 0, len(self.Steps))
+	for _, step := range self.Steps {
+		steps = append(steps, step.String())
+	}
+	state := "done"
+	if self.IsUndone() {
+		state = "undone"
+	}
+	return fmt.Sprintf("%d %s, %s: %s", self.ID, self.Action, state, strings.Join(steps, ", "))
+}
+
+// Entries are listed latest first.
+type Entries []Entry
+
+func (self Entries) String() string {
+	if len(self) == 0 {
+		return "nothing to undo\n"
+	}
+	var text strings.Builder
+	fmt.Fprintf(&text, "%-6s %-20s %-24s %-7s %s\n", "ID", "TIME", "ACTION", "STATE", "CHANGES")
+	for _, entry := range self {
+		state := "done"
+		if entry.IsUndone() {
+			state = "undone"
+		}
+		steps := make([]string, 0, len(entry.Steps))
+		for _, step := range entry.Steps {
+			steps = append(steps, step.String())
+		}
+		fmt.Fprintf(&text, "%-6d %-20s %-24s %-7s %s\n", entry.ID, entry.Time.Local().Format("2006-01-02 15:04:05"),
+			entry.Action, state, strings.Join(steps, ", "))
+	}
+	return text.String()
+}
+
+// Journal keeps the entries in a key-value store; models are put back on the
+// backend they were written to.
+type Journal struct {
+	Store   *kv.Store
+	Backend model.Backend
+}
+
+func New(store *kv.Store, backend model.Backend) *Journal {
+	return &Journal{Store: store, Backend: backend}
+}
+
+const entryPrefix = "entries/"
+
+func entryKey(id uint64) string { return fmt.Sprintf("%s%016d", entryPrefix, id) }
+
+// Entries returns the latest entries, latest first; a limit of zero returns
+// all of them.
+func (self *Journal) Entries(limit int) (Entries, error) {
+	var entries Entries
+	err := self.Store.Each(entryPrefix, func(key string, value []byte) error {
+		var entry Entry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return fault.Wrap(err, fault.IO, "io.journal", "journal entry %s is corrupt", strings.TrimPrefix(key, entryPrefix))
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, fault.Wrap(err, fault.IO, "io.journal", "failed to read the journal")
+	}
+	for left, right := 0, len(entries)-1; left < right; left, right = left+1, right-1 {
+		entries[left], entries[right] = entries[right], entries[left]
+	}
+	if 0 < limit && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// add appends an entry, dropping the entries that could be redone and the
+// oldest beyond Keep.
+func (self *Journal) add(entry Entry) error {
+	unlock, err := self.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	entries, err := self.Entries(0)
+	if err != nil {
+		return err
+	}
+	entry.ID, entry.Time = 1, time.Now().UTC()
+	if 0 < len(entries) {
+		entry.ID = entries[0].ID + 1
+	}
+	if err := self.put(entry); err != nil {
+		return err
+	}
+	for index, existing := range entries {
+		if existing.IsUndone() || Keep <= index+1 {
+			self.Store.Delete(entryKey(existing.ID))
+		}
+	}
+	return nil
+}
+
+func (self *Journal) put(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = self.Store.Put(entryKey(entry.ID), data)
+	}
+	if err != nil {
+		return fault.Wrap(err, fault.IO, "io.journal", "failed to write journal entry %d", entry.ID)
+	}
+	return nil
+}
+
+func (self *Journal) lock() (func(), error) {
+	unlock, err := self.Store.Lock("journal")
+	if err != nil {
+		return nil, fault.Wrap(err, fault.IO, "io.lock", "failed to lock the journal")
+	}
+	return unlock, nil
+}
+
+// Files //////////////////////////////////////////////////////////////////////
+// read returns the content of a file, nil when there is none.
+func read(path string) (*File, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Data: data, Mode: info.Mode().Perm()}, nil
+}
+
+// write puts a file in place atomically, or removes it when file is nil.
+func write(path string, file *File) error {
+	if file == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	temporary := path + ".tmp"
+	if err := os.WriteFile(temporary, file.Data, file.Mode); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	return nil
+}
+
+func sameFile(current, expected *File) bool {
+	if current == nil || expected == nil {
+		return current == expected
+	}
+	return bytes.Equal(current.Data, expected.Data)
+}
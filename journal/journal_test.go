@@ -0,0 +1,242 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"../controller"
+	"../fault"
+	"../kv"
+	"../model"
+)
+
+type note struct {
+	model.Model
+	Title string `json:"title"`
+}
+
+type edit struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Fail  bool   `json:"fail"`
+}
+
+type written struct {
+	Path string `json:"path"`
+	Data string `json:"data"`
+}
+
+type fixture struct {
+	journal  *Journal
+	registry *controller.Registry
+	notes    *model.Repository[note]
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	store, err := kv.Open(filepath.Join(t.TempDir(), "kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	backend := model.NewKV(store, model.JSON{})
+	self := &fixture{journal: New(store, backend), registry: controller.NewRegistry(), notes: model.NewRepository[note](backend, "notes")}
+	self.registry.Use(self.journal.Middleware())
+	self.registry.Add(self.journal.Actions()...)
+	self.registry.Add(
+		controller.NewAction("notes.add", "", func(ctx context.Context, input edit) (*note, error) {
+			item := &note{Model: model.Model{ID: input.ID}, Title: input.Title}
+			if err := self.notes.In(ctx).Create(item); err != nil {
+				return nil, err
+			}
+			if input.Fail {
+				return nil, errors.New("failed after creating")
+			}
+			return item, nil
+		}),
+		controller.NewAction("notes.rename", "", func(ctx context.Context, input edit) (*note, error) {
+			item, err := self.notes.Get(input.ID)
+			if err != nil {
+				return nil, err
+			}
+			item.Title = input.Title
+			return item, self.notes.In(ctx).Update(item)
+		}),
+		controller.NewAction("notes.remove", "", func(ctx context.Context, input edit) (bool, error) {
+			item, err := self.notes.Get(input.ID)
+			if err != nil {
+				return false, err
+			}
+			return true, self.notes.In(ctx).Delete(item.ID, item.Revision)
+		}),
+		controller.NewAction("notes.get", "", func(ctx context.Context, input edit) (*note, error) {
+			return self.notes.Get(input.ID)
+		}).With("method", "GET"),
+		controller.NewAction("files.write", "", func(ctx context.Context, input written) (bool, error) {
+			if input.Data == "" {
+				return true, Remove(ctx, input.Path)
+			}
+			return true, WriteFile(ctx, input.Path, []byte(input.Data), 0600)
+		}),
+	)
+	return self
+}
+
+func (self *fixture) run(t *testing.T, name string, input interface{}) {
+	t.Helper()
+	if _, err := self.registry.Dispatch(context.Background(), name, input); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+// title is the title of the note, or "-" when there is none.
+func (self *fixture) title(id string) string {
+	item, err := self.notes.Get(id)
+	if err != nil {
+		return "-"
+	}
+	return item.Title
+}
+
+func TestUndoRedo(t *testing.T) {
+	fixture := newFixture(t)
+	fixture.run(t, "notes.add", edit{ID: "n", Title: "a"})
+	fixture.run(t, "notes.rename", edit{ID: "n", Title: "b"})
+	fixture.run(t, "notes.get", edit{ID: "n"})
+	fixture.run(t, "notes.rename", edit{ID: "n", Title: "c"})
+	fixture.run(t, "notes.remove", edit{ID: "n"})
+
+	tests := []struct {
+		action string
+		id     uint64
+		title  string
+		code   string
+	}{
+		{"undo", 0, "c", ""},
+		{"undo", 0, "b", ""},
+		{"undo", 4, "", "usage.already_undone"},
+		{"redo", 0, "c", ""},
+		{"undo", 0, "b", ""},
+		{"undo", 0, "a", ""},
+		{"undo", 0, "-", ""},
+		{"undo", 0, "", "usage.nothing_to_undo"},
+		{"redo", 9, "", "usage.unknown_entry"},
+		{"redo", 0, "a", ""},
+		{"redo", 0, "b", ""},
+		{"redo", 0, "c", ""},
+		{"redo", 0, "-", ""},
+		{"redo", 0, "", "usage.nothing_to_redo"},
+	}
+	for index, test := range tests {
+		_, err := fixture.registry.Dispatch(context.Background(), test.action, Selection{ID: test.id})
+		if test.code != "" {
+			if fault.As(err).Code != test.code {
+				t.Errorf("%d %s %d: returned %v, expected %s", index, test.action, test.id, err, test.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%d %s %d: %v", index, test.action, test.id, err)
+		}
+		if title := fixture.title("n"); title != test.title {
+			t.Errorf("%d %s %d: the title is %s, expected %s", index, test.action, test.id, title, test.title)
+		}
+	}
+	entries, _ := fixture.journal.Entries(0)
+	if len(entries) != 4 || entries[0].Action != "notes.remove" || entries[3].Action != "notes.add" {
+		t.Errorf("journaled %v", entries)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	fixture := newFixture(t)
+	fixture.run(t, "notes.add", edit{ID: "n", Title: "a"})
+	fixture.run(t, "notes.add", edit{ID: "other", Title: "a"})
+	fixture.run(t, "notes.rename", edit{ID: "n", Title: "b"})
+
+	_, err := fixture.journal.Undo(context.Background(), 1)
+	if fault.As(err).Code != "unavailable.conflict" || !strings.Contains(fault.As(err).HintText(), "3 notes.rename changed it later") {
+		t.Errorf("undoing under a later change returned %v, %q", err, fault.As(err).HintText())
+	}
+	if _, err := fixture.journal.Undo(context.Background(), 2); err != nil || fixture.title("other") != "-" {
+		t.Errorf("undoing an unrelated entry returned %v", err)
+	}
+
+	item, _ := fixture.notes.Get("n")
+	item.Title = "outside"
+	fixture.notes.Update(item)
+	_, err = fixture.journal.Undo(context.Background(), 0)
+	if fault.As(err).Code != "unavailable.conflict" || !strings.Contains(fault.As(err).HintText(), "outside of the journal") {
+		t.Errorf("undoing under an outside change returned %v, %q", err, fault.As(err).HintText())
+	}
+	if fixture.title("n") != "outside" {
+		t.Errorf("a refused undo changed the title to %s", fixture.title("n"))
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	fixture := newFixture(t)
+	fixture.run(t, "notes.add", edit{ID: "n", Title: "a"})
+	entry, err := fixture.registry.Dispatch(controller.WithDryRun(context.Background()), "undo", Selection{})
+	if err != nil || entry.(Entry).ID != 1 || entry.(Entry).IsUndone() || fixture.title("n") != "a" {
+		t.Errorf("a dry run undo returned %v, %v, left %s", entry, err, fixture.title("n"))
+	}
+}
+
+func TestFiles(t *testing.T) {
+	fixture := newFixture(t)
+	path := filepath.Join(t.TempDir(), "file")
+	fixture.run(t, "files.write", written{Path: path, Data: "one"})
+	fixture.run(t, "files.write", written{Path: path, Data: "two"})
+	fixture.run(t, "files.write", written{Path: path})
+	content := func() string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "-"
+		}
+		return string(data)
+	}
+	for _, expected := range []string{"two", "one", "-"} {
+		if _, err := fixture.journal.Undo(context.Background(), 0); err != nil {
+			t.Fatal(err)
+		}
+		if content() != expected {
+			t.Errorf("undone to %s, expected %s", content(), expected)
+		}
+	}
+	fixture.journal.Redo(context.Background(), 0)
+	os.WriteFile(path, []byte("outside"), 0600)
+	if _, err := fixture.journal.Redo(context.Background(), 0); fault.As(err).Code != "unavailable.conflict" || content() != "outside" {
+		t.Errorf("redoing over an outside change returned %v, left %s", err, content())
+	}
+}
+
+func TestEntries(t *testing.T) {
+	fixture := newFixture(t)
+	_, err := fixture.registry.Dispatch(context.Background(), "notes.add", edit{ID: "failed", Title: "a", Fail: true})
+	entries, _ := fixture.journal.Entries(0)
+	if err == nil || len(entries) != 1 || entries[0].Error != "failed after creating" {
+		t.Errorf("a failed action returned %v and journaled %v", err, entries)
+	}
+	fixture.run(t, "notes.add", edit{ID: "n", Title: "a"})
+	fixture.journal.Undo(context.Background(), 0)
+	fixture.journal.Undo(context.Background(), 0)
+	fixture.run(t, "notes.add", edit{ID: "m", Title: "a"})
+	if entries, _ := fixture.journal.Entries(0); len(entries) != 1 || entries[0].ID != 3 {
+		t.Errorf("a new entry left %v", entries)
+	}
+	for index := 0; index < Keep+5; index++ {
+		fixture.run(t, "notes.rename", edit{ID: "m", Title: strings.Repeat("a", index%5+1)})
+	}
+	entries, _ = fixture.journal.Entries(0)
+	if len(entries) != Keep || entries[0].ID != Keep+8 {
+		t.Errorf("kept %d entries, the latest %d", len(entries), entries[0].ID)
+	}
+	if limited, _ := fixture.journal.Entries(3); len(limited) != 3 || limited[0].ID != entries[0].ID {
+		t.Errorf("limited to %d entries", len(limited))
+	}
+}
@@ -0,0 +1,118 @@
+package journal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"../controller"
+	"../fault"
+	"../model"
+)
+
+// recording collects the steps of the action running in a context.
+type recording struct {
+	mu    sync.Mutex
+	steps []Step
+}
+
+// add adds a step; a model or file changed again keeps one step, from how it
+// was first to how it is last, and none when it is as it was.
+func (self *recording) add(step Step) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for index, existing := range self.steps {
+		if !existing.same(step) {
+			continue
+		}
+		step.Old, step.OldFile = existing.Old, existing.OldFile
+		self.steps = append(self.steps[:index], self.steps[index+1:]...)
+		break
+	}
+	if step.Kind == ModelStep && step.Old == nil && step.New == nil ||
+		step.Kind == FileStep && sameFile(step.OldFile, step.NewFile) {
+		return
+	}
+	self.steps = append(self.steps, step)
+}
+
+func (self *recording) change(change model.Change) {
+	step := Step{Kind: ModelStep, Collection: change.Collection, ID: change.ID, Old: change.Old, New: change.New}
+	if change.New != nil {
+		step.Revision = change.Revision
+	}
+	self.add(step)
+}
+
+type recordingKey struct{}
+
+func recordingOf(ctx context.Context) *recording {
+	recording, _ := ctx.Value(recordingKey{}).(*recording)
+	return recording
+}
+
+// Middleware journals what each action changes as one entry. Actions that
+// only read, with the `method` metadata GET, and actions with the `journal`
+// metadata false are not journaled; an action run by another belongs to the
+// entry of the outer one.
+func (self *Journal) Middleware() controller.Middleware {
+	return func(action *controller.Action, next controller.Handler) controller.Handler {
+		if strings.EqualFold(action.Metadata["method"], "GET") || action.Metadata["journal"] == "false" {
+			return next
+		}
+		return func(ctx context.Context, input interface{}) (interface{}, error) {
+			if recordingOf(ctx) != nil {
+				return next(ctx, input)
+			}
+			recording := new(recording)
+			ctx = context.WithValue(ctx, recordingKey{}, recording)
+			ctx = model.Observing(ctx, recording.change)
+			output, err := next(ctx, input)
+			recording.mu.Lock()
+			steps := recording.steps
+			recording.mu.Unlock()
+			if len(steps) == 0 {
+				return output, err
+			}
+			entry := Entry{Action: action.Name, Steps: steps}
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if journalErr := self.add(entry); journalErr != nil && err == nil {
+				return nil, fault.Wrap(journalErr, fault.IO, "io.journal", "%s was done but can not be undone", action.Name)
+			}
+			return output, err
+		}
+	}
+}
+
+// WriteFile writes a file, as os.WriteFile does but atomically; within an
+// action the file as it was is journaled.
+func WriteFile(ctx context.Context, path string, data []byte, mode os.FileMode) error {
+	return change(ctx, path, &File{Data: data, Mode: mode})
+}
+
+// Remove removes a file; within an action it is journaled.
+func Remove(ctx context.Context, path string) error {
+	return change(ctx, path, nil)
+}
+
+func change(ctx context.Context, path string, file *File) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fault.Wrap(err, fault.IO, "io.failed", "failed to resolve %s", path)
+	}
+	old, err := read(path)
+	if err != nil {
+		return fault.Wrap(err, fault.IO, "io.failed", "failed to read %s", path)
+	}
+	if err := write(path, file); err != nil {
+		return fault.Wrap(err, fault.IO, "io.failed", "failed to write %s", path)
+	}
+	if recording := recordingOf(ctx); recording != nil {
+		recording.add(Step{Kind: FileStep, Path: path, OldFile: old, NewFile: file})
+	}
+	return nil
+}
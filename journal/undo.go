@@ -0,0 +1,232 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"../controller"
+	"../fault"
+	"../model"
+)
+
+// Undo puts back what an entry changed, the latest one not undone when id is
+// zero. It is refused, changing nothing, when anything the entry changed was
+// changed since; dry runs only check that.
+func (self *Journal) Undo(ctx context.Context, id uint64) (Entry, error) {
+	return self.replay(ctx, id, true)
+}
+
+// Redo does again what an undone entry changed, the earliest one undone when
+// id is zero.
+func (self *Journal) Redo(ctx context.Context, id uint64) (Entry, error) {
+	return self.replay(ctx, id, false)
+}
+
+func (self *Journal) replay(ctx context.Context, id uint64, undo bool) (Entry, error) {
+	unlock, err := self.lock()
+	if err != nil {
+		return Entry{}, err
+	}
+	defer unlock()
+	entries, err := self.Entries(0)
+	if err != nil {
+		return Entry{}, err
+	}
+	index, err := pick(entries, id, undo)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := entries[index]
+	if err := self.check(entries, index, undo); err != nil {
+		return entry, err
+	}
+	if controller.IsDryRun(ctx) {
+		return entry, nil
+	}
+	steps := append([]Step{}, entry.Steps...)
+	for position := range steps {
+		// NOTE: An undo puts the steps back in the reverse order they were done.
+		if undo {
+			position = len(steps) - 1 - position
+		}
+		if err := self.apply(ctx, &steps[position], undo); err != nil {
+			// NOTE: The steps done so far keep their new revisions, so the
+			// entry can be undone or redone again once the failure is fixed.
+			entry.Steps = steps
+			self.put(entry)
+			return entry, err
+		}
+	}
+	entry.Steps, entry.Undone = steps, time.Time{}
+	if undo {
+		entry.Undone = time.Now().UTC()
+	}
+	entries[index] = entry
+	if err := self.handOver(entries, index, undo); err != nil {
+		return entry, err
+	}
+	return entry, self.put(entry)
+}
+
+// handOver gives the revisions of the models an entry wrote to the entries
+// next to it that left, or find, them the same way: an undo puts a model back
+// as the earlier entry done left it, a redo as the later entry undone found
+// it, but at a new revision they would otherwise see as a change since.
+func (self *Journal) handOver(entries Entries, index int, undo bool) error {
+	// NOTE: Entries are latest first.
+	direction := -1
+	if undo {
+		direction = 1
+	}
+	for _, step := range entries[index].Steps {
+		if step.Kind != ModelStep {
+			continue
+		}
+	next:
+		for position := index + direction; 0 <= position && position < len(entries); position += direction {
+			if entries[position].IsUndone() != !undo {
+				continue
+			}
+			for number, other := range entries[position].Steps {
+				if other.same(step) {
+					entries[position].Steps[number].Revision = step.Revision
+					if err := self.put(entries[position]); err != nil {
+						return err
+					}
+					break next
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// pick is the index of the entry to undo or redo.
+func pick(entries Entries, id uint64, undo bool) (int, error) {
+	verb := map[bool]string{true: "undo", false: "redo"}[undo]
+	if id == 0 {
+		// NOTE: Entries are latest first: undo takes the first done, redo the
+		// last undone.
+		found := -1
+		for index, entry := range entries {
+			if entry.IsUndone() != undo {
+				found = index
+				if undo {
+					break
+				}
+			}
+		}
+		if found < 0 {
+			return 0, fault.UsageError("usage.nothing_to_"+verb, "nothing to %s", verb).
+				WithHint("list the changes with: history")
+		}
+		return found, nil
+	}
+	for index, entry := range entries {
+		if entry.ID != id {
+			continue
+		}
+		if entry.IsUndone() == undo {
+			done := map[bool]string{true: "undone", false: "redone"}[undo]
+			return 0, fault.UsageError("usage.already_"+done, "%d %s is already %s", id, entry.Action, done).
+				WithHint("list the changes with: history")
+		}
+		return index, nil
+	}
+	return 0, fault.UsageError("usage.unknown_entry", "there is no change %d in the journal", id).
+		WithHint("list the changes with: history")
+}
+
+// check refuses to replay an entry when what it changed is no longer as it
+// left it, naming the later entry that changed it when there is one.
+func (self *Journal) check(entries Entries, index int, undo bool) error {
+	entry := entries[index]
+	verb := map[bool]string{true: "undo", false: "redo"}[undo]
+	for _, step := range entry.Steps {
+		changed, err := self.changed(step, undo)
+		if err != nil {
+			return err
+		}
+		if changed == "" {
+			continue
+		}
+		conflict := fault.New(fault.Unavailable, "unavailable.conflict",
+			"can not %s %d %s: %s", verb, entry.ID, entry.Action, changed)
+		for later := index - 1; 0 <= later; later-- {
+			for _, other := range entries[later].Steps {
+				if other.same(step) && !entries[later].IsUndone() {
+					return conflict.WithHint("%d %s changed it later, undo it first", entries[later].ID, entries[later].Action)
+				}
+			}
+		}
+		return conflict.WithHint("it was changed outside of the journal; %s would overwrite that", verb)
+	}
+	return nil
+}
+
+// changed describes how what a step changed differs from how the step left
+// it, done or undone; empty when it does not.
+func (self *Journal) changed(step Step, undo bool) (string, error) {
+	if step.Kind == FileStep {
+		expected := step.OldFile
+		if undo {
+			expected = step.NewFile
+		}
+		current, err := read(step.Path)
+		if err != nil {
+			return "", fault.Wrap(err, fault.IO, "io.failed", "failed to read %s", step.Path)
+		}
+		if sameFile(current, expected) {
+			return "", nil
+		}
+		return step.Path + " was changed since", nil
+	}
+	data, err := self.Backend.Read(step.Collection, step.ID)
+	current := new(model.Model)
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		current = nil
+	case err != nil:
+		return "", fault.Wrap(err, fault.IO, "io.store", "failed to access %s %q", step.Collection, step.ID)
+	default:
+		if err := self.Backend.Codec().Unmarshal(data, current); err != nil {
+			return "", fault.Wrap(err, fault.IO, "io.decode", "failed to decode %s %q", step.Collection, step.ID)
+		}
+	}
+	switch {
+	case current == nil && step.Revision == 0:
+		return "", nil
+	case current == nil:
+		return step.Collection + " " + step.ID + " was deleted since", nil
+	case step.Revision == 0:
+		return step.Collection + " " + step.ID + " was created again since", nil
+	case current.Revision != step.Revision:
+		return step.Collection + " " + step.ID + " was changed since", nil
+	}
+	return "", nil
+}
+
+// apply undoes or redoes a step, keeping the revision it leaves the model at.
+func (self *Journal) apply(ctx context.Context, step *Step, undo bool) error {
+	if step.Kind == FileStep {
+		file := step.NewFile
+		if undo {
+			file = step.OldFile
+		}
+		if err := write(step.Path, file); err != nil {
+			return fault.Wrap(err, fault.IO, "io.failed", "failed to write %s", step.Path)
+		}
+		return nil
+	}
+	document := step.New
+	if undo {
+		document = step.Old
+	}
+	revision, err := model.Restore(ctx, self.Backend, step.Collection, step.ID, document, step.Revision)
+	if err != nil {
+		return err
+	}
+	step.Revision = revision
+	return nil
+}
@@ -0,0 +1,148 @@
+package kv
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"../filesystem"
+)
+
+// Compact rewrites the live records into a new segment and removes the
+// segments they were in. Writes go to another new segment meanwhile, so the
+// store stays usable; only one compaction runs at a time, across processes,
+// and Compact returns at once when another one is running.
+func (self *Store) Compact() error {
+	release, ok, err := self.compacting()
+	if err != nil || !ok {
+		return err
+	}
+	defer release()
+
+	// NOTE: The compacted segment takes the id after the old segments and
+	// writes go to the id after it, so a replay still applies the records in
+	// order whether or not the old segments were removed.
+	unlock, err := self.acquire(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	old := append([]*segment{}, self.segments...)
+	if len(old) == 0 {
+		unlock()
+		return nil
+	}
+	last := old[len(old)-1].id
+	if err := self.addSegment(last + 2); err != nil {
+		unlock()
+		return err
+	}
+	type entry struct {
+		key string
+		at  location
+	}
+	entries := make([]entry, 0, len(self.index))
+	for key, at := range self.index {
+		entries = append(entries, entry{key: key, at: at})
+	}
+	unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+
+	compacted := filepath.Join(self.Path, segmentName(last+1))
+	file, err := os.OpenFile(compacted+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	moved := make(map[string]location, len(entries))
+	var end uint32
+	for _, entry := range entries {
+		// NOTE: The old segments are only removed by a compaction, their
+		// records can be read without the store lock.
+		var source *segment
+		for _, segment := range old {
+			if segment.id == entry.at.segment {
+				source = segment
+			}
+		}
+		data, _, err := filesystem.ReadFromReader(source.file, entry.at.offset, blockSize)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return err
+		}
+		next, err := filesystem.WriteRecord(file, end, data)
+		if err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return err
+		}
+		moved[entry.key] = location{segment: last + 1, offset: end, size: next - end}
+		end = next
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+
+	unlock, err = self.acquire(syscall.LOCK_EX)
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	defer unlock()
+	if err := os.Rename(file.Name(), compacted); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := syncDirectory(self.Path); err != nil {
+		file.Close()
+		return err
+	}
+	for _, segment := range old {
+		os.Remove(filepath.Join(self.Path, segmentName(segment.id)))
+	}
+	syncDirectory(self.Path)
+	// NOTE: Keys written since the snapshot stay where they are now.
+	for _, entry := range entries {
+		if self.index[entry.key] == entry.at {
+			self.index[entry.key] = moved[entry.key]
+		}
+	}
+	self.closeSegments(old)
+	self.segments = append([]*segment{{id: last + 1, file: file, end: end}}, self.segments[len(old):]...)
+	return nil
+}
+
+// compacting takes the compaction lock, without waiting for it; stale
+// segments of a compaction that did not finish are removed.
+func (self *Store) compacting() (func(), bool, error) {
+	if err := os.MkdirAll(self.Path, 0700); err != nil {
+		return nil, false, err
+	}
+	file, err := os.OpenFile(filepath.Join(self.Path, ".compact"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if entries, err := os.ReadDir(self.Path); err == nil {
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), ".log.tmp") {
+				os.Remove(filepath.Join(self.Path, entry.Name()))
+			}
+		}
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, true, nil
+}
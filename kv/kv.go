@@ -0,0 +1,422 @@
+package kv
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"../filesystem"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// A log-structured key-value store: every put and delete is appended as a
+// record (see filesystem/record.go) to the newest segment of a directory,
+// and an in-memory hash index maps each key to its latest record. Opening a
+// store replays the segments to rebuild the index; deletes are tombstones
+// that the replay applies.
+//
+//   <path>/00000001.log   segments, replayed in order; the last one is
+//   <path>/00000002.log   the only one written to
+//   <path>/.lock          shared by readers, exclusive for writers
+//
+// The same store can be open in several processes, such as the daemon and
+// the cli: every operation takes the lock and first reads what the others
+// appended since. Compact rewrites the live records into a new segment while
+// writes go on, and removes the old segments.
+////////////////////////////////////////////////////////////////////////////////
+
+var ErrNotFound = errors.New("not found")
+
+const (
+	put byte = iota + 1
+	tombstone
+)
+
+// NOTE: Reads fetch a block at once, which holds most records whole.
+const blockSize = 4096
+
+type segment struct {
+	id   int
+	file *os.File
+	// NOTE: end is the offset after the last record read, in PAD units.
+	end uint32
+}
+
+type location struct {
+	segment int
+	offset  uint32
+	size    uint32
+}
+
+type Store struct {
+	Path string
+
+	mu       sync.Mutex
+	loaded   bool
+	lock     *os.File
+	segments []*segment
+	index    map[string]location
+	live     int64
+}
+
+// New returns the store in a directory; it is read on first use.
+func New(path string) *Store {
+	return &Store{Path: path, index: make(map[string]location)}
+}
+
+// Open returns the store in a directory, read.
+func Open(path string) (*Store, error) {
+	store := New(path)
+	_, err := store.Stats()
+	return store, err
+}
+
+// Operations /////////////////////////////////////////////////////////////////
+func (self *Store) Get(key string) ([]byte, error) {
+	unlock, err := self.acquire(syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return self.read(key)
+}
+
+// Put stores a value, durably once it returns.
+func (self *Store) Put(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("kv: empty key")
+	}
+	return self.append(put, key, value)
+}
+
+// Delete removes a key, ErrNotFound if there is none.
+func (self *Store) Delete(key string) error {
+	return self.append(tombstone, key, nil)
+}
+
+// Keys returns the keys starting with prefix, sorted.
+func (self *Store) Keys(prefix string) ([]string, error) {
+	unlock, err := self.acquire(syscall.LOCK_SH)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	var keys []string
+	for key := range self.index {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Each calls fn with every key starting with prefix and its value, in key
+// order; it stops at the first error fn returns. The store is not locked
+// while fn runs, keys deleted meanwhile are skipped.
+func (self *Store) Each(prefix string, fn func(key string, value []byte) error) error {
+	keys, err := self.Keys(prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		value, err := self.Get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock takes the named lock of the store, e.g. for a read, modify, write
+// sequence; it excludes other holders in this process and others.
+func (self *Store) Lock(name string) (func(), error) {
+	directory := filepath.Join(self.Path, "locks")
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(directory, name+".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+func (self *Store) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.closeSegments(self.segments)
+	self.segments, self.index, self.live, self.loaded = nil, make(map[string]location), 0, false
+	if self.lock != nil {
+		self.lock.Close()
+		self.lock = nil
+	}
+	return nil
+}
+
+// Stats //////////////////////////////////////////////////////////////////////
+type Stats struct {
+	Segments int `json:"segments" yaml:"segments"`
+	Keys     int `json:"keys" yaml:"keys"`
+	// NOTE: Live are the bytes of the latest records, Garbage the bytes of
+	// overwritten records and tombstones, which compaction reclaims.
+	Live    int64 `json:"live" yaml:"live"`
+	Garbage int64 `json:"garbage" yaml:"garbage"`
+}
+
+func (self *Store) Stats() (Stats, error) {
+	unlock, err := self.acquire(syscall.LOCK_SH)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer unlock()
+	var total int64
+	for _, segment := range self.segments {
+		total += int64(segment.end) * filesystem.PAD
+	}
+	return Stats{Segments: len(self.segments), Keys: len(self.index), Live: self.live, Garbage: total - self.live}, nil
+}
+
+// Locking ////////////////////////////////////////////////////////////////////
+// acquire locks the store for this process and others, then catches up with
+// what other processes wrote.
+func (self *Store) acquire(how int) (func(), error) {
+	self.mu.Lock()
+	if self.lock == nil {
+		if err := os.MkdirAll(self.Path, 0700); err != nil {
+			self.mu.Unlock()
+			return nil, err
+		}
+		file, err := os.OpenFile(filepath.Join(self.Path, ".lock"), os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			self.mu.Unlock()
+			return nil, err
+		}
+		self.lock = file
+	}
+	if err := syscall.Flock(int(self.lock.Fd()), how); err != nil {
+		self.mu.Unlock()
+		return nil, err
+	}
+	unlock := func() {
+		syscall.Flock(int(self.lock.Fd()), syscall.LOCK_UN)
+		self.mu.Unlock()
+	}
+	if err := self.refresh(); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// Segments ///////////////////////////////////////////////////////////////////
+func segmentName(id int) string { return fmt.Sprintf("%08d.log", id) }
+
+func (self *Store) segmentIDs() ([]int, error) {
+	entries, err := ioutil.ReadDir(self.Path)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".log") {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimSuffix(name, ".log")); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// refresh reads the records appended since the last operation; when the
+// segments were replaced by a compaction the index is rebuilt.
+func (self *Store) refresh() error {
+	ids, err := self.segmentIDs()
+	if err != nil {
+		return err
+	}
+	known := len(self.segments)
+	if !self.loaded || len(ids) < known || !sameIDs(ids[:known], self.segments) {
+		self.closeSegments(self.segments)
+		self.segments, self.index, self.live, known = nil, make(map[string]location), 0, 0
+	}
+	self.loaded = true
+	for _, id := range ids[known:] {
+		file, err := os.OpenFile(filepath.Join(self.Path, segmentName(id)), os.O_RDWR, 0600)
+		if err != nil {
+			return err
+		}
+		self.segments = append(self.segments, &segment{id: id, file: file})
+	}
+	// NOTE: Only the last known segment could have been appended to, but a
+	// segment added since was preceded by appends to the one before it.
+	from := known - 1
+	if from < 0 {
+		from = 0
+	}
+	for _, segment := range self.segments[from:] {
+		if err := self.replay(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameIDs(ids []int, segments []*segment) bool {
+	for index, segment := range segments {
+		if ids[index] != segment.id {
+			return false
+		}
+	}
+	return true
+}
+
+func (self *Store) replay(segment *segment) error {
+	return filesystem.ScanFromReader(segment.file, segment.end, blockSize, func(data []byte, offset, next uint32) error {
+		segment.end = next
+		op, key, _, err := decode(data)
+		if err != nil {
+			// NOTE: A record with a valid checksum that does not decode was
+			// written by something else, it is skipped like a corrupt one.
+			return nil
+		}
+		self.apply(op, key, location{segment: segment.id, offset: offset, size: next - offset})
+		return nil
+	})
+}
+
+func (self *Store) apply(op byte, key string, at location) {
+	if previous, ok := self.index[key]; ok {
+		self.live -= int64(previous.size) * filesystem.PAD
+		delete(self.index, key)
+	}
+	if op == put {
+		self.index[key] = at
+		self.live += int64(at.size) * filesystem.PAD
+	}
+}
+
+func (self *Store) closeSegments(segments []*segment) {
+	for _, segment := range segments {
+		segment.file.Close()
+	}
+}
+
+func (self *Store) segment(id int) *segment {
+	for _, segment := range self.segments {
+		if segment.id == id {
+			return segment
+		}
+	}
+	return nil
+}
+
+// Records ////////////////////////////////////////////////////////////////////
+// NOTE: A record is the operation, the key length as a uvarint, the key and
+// the value.
+func encode(op byte, key string, value []byte) []byte {
+	data := make([]byte, 1, 1+binary.MaxVarintLen64+len(key)+len(value))
+	data[0] = op
+	data = binary.AppendUvarint(data, uint64(len(key)))
+	data = append(data, key...)
+	return append(data, value...)
+}
+
+func decode(data []byte) (op byte, key string, value []byte, err error) {
+	if len(data) < 2 || (data[0] != put && data[0] != tombstone) {
+		return 0, "", nil, fmt.Errorf("kv: invalid record")
+	}
+	length, read := binary.Uvarint(data[1:])
+	if read <= 0 || uint64(len(data)-1-read) < length {
+		return 0, "", nil, fmt.Errorf("kv: invalid record")
+	}
+	start := 1 + read
+	return data[0], string(data[start : start+int(length)]), data[start+int(length):], nil
+}
+
+func (self *Store) read(key string) ([]byte, error) {
+	at, ok := self.index[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data, _, err := filesystem.ReadFromReader(self.segment(at.segment).file, at.offset, blockSize)
+	if err != nil {
+		return nil, err
+	}
+	_, _, value, err := decode(data)
+	return value, err
+}
+
+func (self *Store) append(op byte, key string, value []byte) error {
+	unlock, err := self.acquire(syscall.LOCK_EX)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, ok := self.index[key]; !ok && op == tombstone {
+		return ErrNotFound
+	}
+	if len(self.segments) == 0 {
+		if err := self.addSegment(1); err != nil {
+			return err
+		}
+	}
+	active := self.segments[len(self.segments)-1]
+	next, err := filesystem.WriteRecord(active.file, active.end, encode(op, key, value))
+	if err != nil {
+		return err
+	}
+	if err := active.file.Sync(); err != nil {
+		return err
+	}
+	self.apply(op, key, location{segment: active.id, offset: active.end, size: next - active.end})
+	active.end = next
+	return nil
+}
+
+// addSegment creates a new, empty, segment to append to.
+func (self *Store) addSegment(id int) error {
+	file, err := os.OpenFile(filepath.Join(self.Path, segmentName(id)), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if err := syncDirectory(self.Path); err != nil {
+		file.Close()
+		return err
+	}
+	self.segments = append(self.segments, &segment{id: id, file: file})
+	return nil
+}
+
+func syncDirectory(path string) error {
+	directory, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer directory.Close()
+	return directory.Sync()
+}
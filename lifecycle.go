@@ -0,0 +1,145 @@
+package application
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"./config"
+	"./server"
+)
+
+// UpgradeTimeout is how long the new process has to become ready.
+const UpgradeTimeout = 30 * time.Second
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// The application context is cancelled when shutdown starts, everything
+// started by the application (listeners, background work) should stop when it
+// is done. Run is the "method that holds open until a signal is given" from the
+// design notes, for daemons.
+////////////////////////////////////////////////////////////////////////////////
+
+func (self *Application) Context() context.Context { return self.context }
+
+// OnShutdown registers a hook run during shutdown, after the server has been
+// drained; hooks run in reverse order of registration.
+func (self *Application) OnShutdown(hook func(context.Context) error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.shutdown = append(self.shutdown, hook)
+}
+
+// Ready is called by a daemon once it is serving: it writes the PID file,
+// starts the scheduled jobs and the controllers' watches and, when started by
+// an upgrade, tells the old process to hand over.
+func (self *Application) Ready() error {
+	if err := self.WritePID(); err != nil {
+		return err
+	}
+	self.OnShutdown(func(ctx context.Context) error { return self.RemovePID() })
+	self.Jobs.Start()
+	self.OnShutdown(self.Jobs.Wait)
+	self.Actions.Watch(self.context, self.Store, self.Log.Named("watch"))
+	Started.Publish(self.Events, VersionResult{Name: self.Name, Version: self.Version.String()})
+	return server.Ready()
+}
+
+// Run blocks until SIGINT or SIGTERM is received, or the application context
+// is cancelled (the `stop` control method), then shuts down gracefully.
+// SIGHUP reloads the config, SIGUSR2 upgrades to the binary on disk and
+// SIGUSR1 reopens the log files.
+//
+// NOTE: SIGUSR1 rather than the SIGUSR2 logrotate setups often send, which
+// is taken by upgrades; SIGUSR1 is the nginx convention for reopening logs.
+func (self *Application) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case received := <-signals:
+			switch received {
+			case syscall.SIGHUP:
+				if err := self.Reload(); err != nil {
+					self.Log.Error("reload failed", "error", err)
+				}
+				continue
+			case syscall.SIGUSR1:
+				if err := self.Log.Reopen(); err != nil {
+					self.Log.Error("reopening logs failed", "error", err)
+				}
+				continue
+			case syscall.SIGUSR2:
+				if _, err := self.Upgrade(); err != nil {
+					self.Log.Error("upgrade failed, still serving", "error", err)
+					continue
+				}
+			}
+			self.Log.Info("shutting down", "signal", received)
+		case <-self.context.Done():
+		}
+		return self.Shutdown()
+	}
+}
+
+// Upgrade re-executes the binary with the listening sockets, and once the new
+// process is ready starts draining this one.
+func (self *Application) Upgrade() (int, error) {
+	process, err := self.Server.Upgrade(UpgradeTimeout)
+	if err != nil {
+		return 0, err
+	}
+	self.Stop()
+	return process.Pid, nil
+}
+
+// Stop starts a graceful shutdown of a running application.
+func (self *Application) Stop() { self.cancel() }
+
+// OnReload registers a hook run with the new settings after the config file
+// has been reloaded.
+func (self *Application) OnReload(hook func(*config.Config) error) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.reload = append(self.reload, hook)
+}
+
+func (self *Application) Reload() error {
+	if err := self.LoadConfig(); err != nil {
+		return err
+	}
+	self.mu.Lock()
+	hooks := self.reload
+	self.mu.Unlock()
+	for _, hook := range hooks {
+		if err := hook(self.Settings); err != nil {
+			return err
+		}
+	}
+	ConfigReloaded.Publish(self.Events, self.Settings)
+	return nil
+}
+
+// Shutdown cancels the application context, drains the server for at most the
+// configured drain timeout and runs the shutdown hooks.
+func (self *Application) Shutdown() error {
+	Stopping.Publish(self.Events, VersionResult{Name: self.Name, Version: self.Version.String()})
+	self.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), self.Server.Config.DrainTimeout)
+	defer cancel()
+
+	err := self.Server.Shutdown(ctx)
+	self.mu.Lock()
+	hooks := self.shutdown
+	self.shutdown = nil
+	self.mu.Unlock()
+	for index := len(hooks) - 1; 0 <= index; index-- {
+		if hookErr := hooks[index](ctx); hookErr != nil && err == nil {
+			err = hookErr
+		}
+	}
+	return err
+}
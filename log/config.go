@@ -0,0 +1,47 @@
+package log
+
+import (
+	"time"
+)
+
+type Config struct {
+	Level  Level  `yaml:"level" json:"level"`
+	Format Format `yaml:"format" json:"format" validate:"enum=text|json|logfmt"`
+	// NOTE: Components are levels by component name, e.g. `server: debug`.
+	Components map[string]Level `yaml:"components,omitempty" json:"components,omitempty"`
+	Stderr     bool             `yaml:"stderr" json:"stderr"`
+	File       FileConfig       `yaml:"file" json:"file"`
+	Syslog     bool             `yaml:"syslog" json:"syslog"`
+}
+
+type FileConfig struct {
+	Enabled  bool          `yaml:"enabled" json:"enabled"`
+	MaxSize  int64         `yaml:"max_size" json:"max_size" validate:"min=0"`
+	MaxAge   time.Duration `yaml:"max_age" json:"max_age" validate:"min=0s"`
+	Backups  int           `yaml:"backups" json:"backups" validate:"min=0"`
+	Compress bool          `yaml:"compress" json:"compress"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Level:  Info,
+		Format: Text,
+		Stderr: true,
+		File: FileConfig{
+			Enabled:  true,
+			MaxSize:  10 << 20,
+			MaxAge:   24 * time.Hour,
+			Backups:  7,
+			Compress: true,
+		},
+	}
+}
+
+// Apply sets the levels of the config on a logger.
+func (self Config) Apply(logger *Logger) {
+	logger.ResetLevels()
+	logger.SetLevel("", self.Level)
+	for component, level := range self.Components {
+		logger.SetLevel(component, level)
+	}
+}
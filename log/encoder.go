@@ -0,0 +1,147 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+type Format string
+
+const (
+	Text   Format = "text"
+	JSON   Format = "json"
+	Logfmt Format = "logfmt"
+)
+
+// Encoder turns a record into a single line, including the newline.
+type Encoder interface {
+	Encode(Record) []byte
+}
+
+func ParseFormat(value string) (Encoder, error) {
+	switch Format(value) {
+	case Text, "":
+		return TextEncoder{}, nil
+	case JSON:
+		return JSONEncoder{}, nil
+	case Logfmt:
+		return LogfmtEncoder{}, nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", value)
+}
+
+// TextEncoder is for people reading a terminal:
+//
+//	2026-01-02T15:04:05.000Z INFO  server: listening address=127.0.0.1:8080
+type TextEncoder struct {
+	// NOTE: Syslog adds its own timestamp.
+	OmitTime bool
+}
+
+func (self TextEncoder) Encode(record Record) []byte {
+	var line bytes.Buffer
+	if !self.OmitTime {
+		line.WriteString(record.Time.UTC().Format(timeFormat))
+		line.WriteByte(' ')
+	}
+	fmt.Fprintf(&line, "%-5s ", strings.ToUpper(record.Level.String()))
+	if record.Component != "" {
+		line.WriteString(record.Component)
+		line.WriteString(": ")
+	}
+	line.WriteString(record.Message)
+	for _, field := range record.Fields {
+		line.WriteByte(' ')
+		line.WriteString(field.Key)
+		line.WriteByte('=')
+		line.WriteString(quote(value(field.Value)))
+	}
+	line.WriteByte('\n')
+	return line.Bytes()
+}
+
+// LogfmtEncoder writes key=value pairs, see https://brandur.org/logfmt.
+type LogfmtEncoder struct{}
+
+func (LogfmtEncoder) Encode(record Record) []byte {
+	var line bytes.Buffer
+	fmt.Fprintf(&line, "time=%s level=%s", record.Time.UTC().Format(timeFormat), record.Level)
+	if record.Component != "" {
+		fmt.Fprintf(&line, " component=%s", quote(record.Component))
+	}
+	fmt.Fprintf(&line, " msg=%s", quote(record.Message))
+	for _, field := range record.Fields {
+		fmt.Fprintf(&line, " %s=%s", field.Key, quote(value(field.Value)))
+	}
+	line.WriteByte('\n')
+	return line.Bytes()
+}
+
+// JSONEncoder writes one object per line, fields beside the fixed keys.
+type JSONEncoder struct{}
+
+func (JSONEncoder) Encode(record Record) []byte {
+	var line bytes.Buffer
+	line.WriteString(`{"time":`)
+	writeJSON(&line, record.Time.UTC().Format(timeFormat))
+	line.WriteString(`,"level":`)
+	writeJSON(&line, record.Level.String())
+	if record.Component != "" {
+		line.WriteString(`,"component":`)
+		writeJSON(&line, record.Component)
+	}
+	line.WriteString(`,"message":`)
+	writeJSON(&line, record.Message)
+	for _, field := range record.Fields {
+		line.WriteByte(',')
+		writeJSON(&line, field.Key)
+		line.WriteByte(':')
+		switch value := field.Value.(type) {
+		case error:
+			writeJSON(&line, value.Error())
+		case time.Duration:
+			writeJSON(&line, value.String())
+		case fmt.Stringer:
+			writeJSON(&line, value.String())
+		default:
+			writeJSON(&line, value)
+		}
+	}
+	line.WriteString("}\n")
+	return line.Bytes()
+}
+
+func writeJSON(line *bytes.Buffer, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	line.Write(data)
+}
+
+func value(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return value
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	}
+	return fmt.Sprint(value)
+}
+
+func quote(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\n\r\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,206 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// FileSink rotates its file once it grows past MaxSize or gets older than
+// MaxAge: the file is renamed with a timestamp, `app-20260102T150405.log`,
+// compressed in the background when Compress is set, and only the newest
+// Backups rotated files are kept. A zero MaxSize or MaxAge disables that
+// trigger.
+//
+// When an outside tool rotates the file instead, Reopen (SIGUSR1) makes the
+// sink start a new file at the same path.
+////////////////////////////////////////////////////////////////////////////////
+
+const rotatedTimeFormat = "20060102T150405.000"
+
+type FileSink struct {
+	Path     string
+	Encoder  Encoder
+	MaxSize  int64
+	MaxAge   time.Duration
+	Backups  int
+	Compress bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	opened   time.Time
+	compress sync.WaitGroup
+}
+
+func NewFileSink(path string, encoder Encoder) (*FileSink, error) {
+	sink := &FileSink{Path: path, Encoder: encoder}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return sink, sink.open()
+}
+
+func (self *FileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(self.Path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(self.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	self.file, self.size, self.opened = file, info.Size(), info.ModTime()
+	if info.Size() == 0 {
+		self.opened = time.Now()
+	}
+	return nil
+}
+
+func (self *FileSink) Write(record Record) error {
+	line := self.Encoder.Encode(record)
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.file == nil {
+		if err := self.open(); err != nil {
+			return err
+		}
+	}
+	if self.due(int64(len(line)), record.Time) {
+		if err := self.rotate(); err != nil {
+			return err
+		}
+	}
+	written, err := self.file.Write(line)
+	self.size += int64(written)
+	return err
+}
+
+func (self *FileSink) due(size int64, now time.Time) bool {
+	if self.size == 0 {
+		return false
+	}
+	if 0 < self.MaxSize && self.MaxSize < self.size+size {
+		return true
+	}
+	return 0 < self.MaxAge && self.MaxAge < now.Sub(self.opened)
+}
+
+// Rotate starts a new file now.
+func (self *FileSink) Rotate() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.rotate()
+}
+
+func (self *FileSink) rotate() error {
+	if self.file != nil {
+		self.file.Close()
+		self.file = nil
+	}
+	extension := filepath.Ext(self.Path)
+	rotated := strings.TrimSuffix(self.Path, extension) + "-" + time.Now().UTC().Format(rotatedTimeFormat) + extension
+	if err := os.Rename(self.Path, rotated); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := self.open(); err != nil {
+		return err
+	}
+	self.compress.Add(1)
+	go func() {
+		defer self.compress.Done()
+		if self.Compress {
+			compress(rotated)
+		}
+		self.prune()
+	}()
+	return nil
+}
+
+func compress(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(path+".gz.tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		target.Close()
+		os.Remove(target.Name())
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		target.Close()
+		os.Remove(target.Name())
+		return err
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(target.Name())
+		return err
+	}
+	if err := os.Rename(target.Name(), path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune removes the oldest rotated files beyond Backups.
+func (self *FileSink) prune() {
+	if self.Backups <= 0 {
+		return
+	}
+	extension := filepath.Ext(self.Path)
+	pattern := strings.TrimSuffix(self.Path, extension) + "-*" + extension + "*"
+	rotated, err := filepath.Glob(pattern)
+	if err != nil {
+		return
+	}
+	var backups []string
+	for _, path := range rotated {
+		if !strings.HasSuffix(path, ".tmp") {
+			backups = append(backups, path)
+		}
+	}
+	// NOTE: The timestamp format sorts by name.
+	sort.Strings(backups)
+	for len(backups) > self.Backups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+func (self *FileSink) Reopen() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.file != nil {
+		self.file.Close()
+		self.file = nil
+	}
+	return self.open()
+}
+
+func (self *FileSink) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	var err error
+	if self.file != nil {
+		err = self.file.Close()
+		self.file = nil
+	}
+	self.compress.Wait()
+	return err
+}
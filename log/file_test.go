@@ -0,0 +1,99 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rotated(t *testing.T, path string) []string {
+	t.Helper()
+	extension := filepath.Ext(path)
+	files, err := filepath.Glob(strings.TrimSuffix(path, extension) + "-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestFileRotation(t *testing.T) {
+	tests := []struct {
+		compress bool
+		suffix   string
+	}{
+		{false, ".log"},
+		{true, ".log.gz"},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "app.log")
+		sink, err := NewFileSink(path, TextEncoder{OmitTime: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sink.MaxSize, sink.Backups, sink.Compress = 40, 2, test.compress
+		for index := 0; index < 5; index++ {
+			// NOTE: Rotated names have millisecond resolution.
+			time.Sleep(2 * time.Millisecond)
+			if err := sink.Write(Record{Time: time.Now(), Message: "a line of about thirty bytes"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatal(err)
+		}
+		backups := rotated(t, path)
+		if len(backups) != 2 {
+			t.Errorf("compress %v: kept %v, expected 2 backups", test.compress, backups)
+		}
+		for _, backup := range backups {
+			if !strings.HasSuffix(backup, test.suffix) {
+				t.Errorf("compress %v: rotated to %s", test.compress, backup)
+			}
+		}
+		if data, _ := os.ReadFile(path); strings.Count(string(data), "\n") != 1 {
+			t.Errorf("compress %v: the current file holds %q", test.compress, data)
+		}
+	}
+}
+
+func TestFileAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	sink, err := NewFileSink(path, TextEncoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.MaxAge = time.Hour
+	sink.Write(Record{Time: time.Now(), Message: "first"})
+	sink.Write(Record{Time: time.Now().Add(30 * time.Minute), Message: "young"})
+	if backups := rotated(t, path); len(backups) != 0 {
+		t.Errorf("rotated a young file: %v", backups)
+	}
+	sink.Write(Record{Time: time.Now().Add(2 * time.Hour), Message: "old"})
+	if backups := rotated(t, path); len(backups) != 1 {
+		t.Errorf("an old file was not rotated: %v", backups)
+	}
+}
+
+func TestFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	sink, err := NewFileSink(path, TextEncoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.Write(Record{Time: time.Now(), Message: "before"})
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	sink.Write(Record{Time: time.Now(), Message: "after"})
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "before") || !strings.Contains(string(data), "after") {
+		t.Errorf("the reopened file holds %q", data)
+	}
+}
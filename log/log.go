@@ -0,0 +1,287 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Application.IO.Error is for the user of a command; diagnostics go through a
+// Logger. Loggers are cheap: Named returns a logger for a component and With
+// one carrying key-values, all sharing the same sinks and levels. Levels can
+// be set for the whole application or per component, also at runtime over the
+// control socket (the `log.level` method).
+//
+//   app.Log.Named("server").Info("listening", "address", addr)
+//
+////////////////////////////////////////////////////////////////////////////////
+
+type Level int
+
+const (
+	Debug Level = iota - 1
+	Info
+	Warn
+	Error
+)
+
+func (self Level) String() string {
+	switch self {
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Warn:
+		return "warn"
+	case Error:
+		return "error"
+	}
+	return fmt.Sprintf("level(%d)", int(self))
+}
+
+func ParseLevel(value string) (Level, error) {
+	switch strings.ToLower(value) {
+	case "debug":
+		return Debug, nil
+	case "info", "":
+		return Info, nil
+	case "warn", "warning":
+		return Warn, nil
+	case "error":
+		return Error, nil
+	}
+	return Info, fmt.Errorf("unknown log level %q", value)
+}
+
+func (self Level) MarshalText() ([]byte, error) { return []byte(self.String()), nil }
+
+func (self *Level) UnmarshalText(text []byte) (err error) {
+	*self, err = ParseLevel(string(text))
+	return err
+}
+
+// Field is a structured key-value.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+type Record struct {
+	Time      time.Time
+	Level     Level
+	Component string
+	Message   string
+	Fields    []Field
+}
+
+// Sink is where encoded records are written.
+type Sink interface {
+	Write(Record) error
+	// Reopen closes and reopens files, after they were rotated by an outside
+	// tool such as logrotate.
+	Reopen() error
+	Close() error
+}
+
+type core struct {
+	mu     sync.RWMutex
+	level  Level
+	levels map[string]Level
+	sinks  []Sink
+}
+
+type Logger struct {
+	core      *core
+	component string
+	fields    []Field
+}
+
+func New(level Level, sinks ...Sink) *Logger {
+	return &Logger{core: &core{level: level, levels: make(map[string]Level), sinks: sinks}}
+}
+
+// Named returns the logger of a component, components nest with dots.
+func (self *Logger) Named(component string) *Logger {
+	if self.component != "" {
+		component = self.component + "." + component
+	}
+	return &Logger{core: self.core, component: component, fields: self.fields}
+}
+
+// With returns a logger adding the key-values to every record.
+func (self *Logger) With(keyvalues ...interface{}) *Logger {
+	fields := append(append([]Field{}, self.fields...), pairs(keyvalues)...)
+	return &Logger{core: self.core, component: self.component, fields: fields}
+}
+
+func (self *Logger) Component() string { return self.component }
+
+func (self *Logger) Debug(message string, keyvalues ...interface{}) {
+	self.Log(Debug, message, keyvalues...)
+}
+
+func (self *Logger) Info(message string, keyvalues ...interface{}) {
+	self.Log(Info, message, keyvalues...)
+}
+
+func (self *Logger) Warn(message string, keyvalues ...interface{}) {
+	self.Log(Warn, message, keyvalues...)
+}
+
+func (self *Logger) Error(message string, keyvalues ...interface{}) {
+	self.Log(Error, message, keyvalues...)
+}
+
+func (self *Logger) Enabled(level Level) bool {
+	self.core.mu.RLock()
+	defer self.core.mu.RUnlock()
+	return self.core.levelOf(self.component) <= level
+}
+
+func (self *Logger) Log(level Level, message string, keyvalues ...interface{}) {
+	if !self.Enabled(level) {
+		return
+	}
+	record := Record{
+		Time:      time.Now(),
+		Level:     level,
+		Component: self.component,
+		Message:   message,
+		Fields:    append(append([]Field{}, self.fields...), pairs(keyvalues)...),
+	}
+	self.core.mu.RLock()
+	sinks := self.core.sinks
+	self.core.mu.RUnlock()
+	for _, sink := range sinks {
+		if err := sink.Write(record); err != nil {
+			// NOTE: A failing sink must not take the application down, the
+			// failure is reported where it can still be seen.
+			fmt.Fprintf(os.Stderr, "log: %v\n", err)
+		}
+	}
+}
+
+// levelOf returns the level of the most specific configured component: the
+// level of "server" applies to "server.http" unless it has its own.
+func (self *core) levelOf(component string) Level {
+	for component != "" {
+		if level, ok := self.levels[component]; ok {
+			return level
+		}
+		index := strings.LastIndex(component, ".")
+		if index < 0 {
+			break
+		}
+		component = component[:index]
+	}
+	return self.level
+}
+
+// SetLevel sets the level of a component, or of the application when the
+// component is empty.
+func (self *Logger) SetLevel(component string, level Level) {
+	self.core.mu.Lock()
+	defer self.core.mu.Unlock()
+	if component == "" {
+		self.core.level = level
+		return
+	}
+	self.core.levels[component] = level
+}
+
+// ResetLevels drops every component level.
+func (self *Logger) ResetLevels() {
+	self.core.mu.Lock()
+	defer self.core.mu.Unlock()
+	self.core.levels = make(map[string]Level)
+}
+
+// Levels are the effective levels, the application level under "".
+func (self *Logger) Levels() Levels {
+	self.core.mu.RLock()
+	defer self.core.mu.RUnlock()
+	levels := Levels{"": self.core.level}
+	for component, level := range self.core.levels {
+		levels[component] = level
+	}
+	return levels
+}
+
+type Levels map[string]Level
+
+func (self Levels) String() string {
+	components := make([]string, 0, len(self))
+	for component := range self {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	var output strings.Builder
+	for index, component := range components {
+		if index > 0 {
+			output.WriteString("\n")
+		}
+		name := component
+		if name == "" {
+			name = "*"
+		}
+		fmt.Fprintf(&output, "%-20s %s", name, self[component])
+	}
+	return output.String()
+}
+
+// SetSinks replaces the sinks, closing the old ones.
+func (self *Logger) SetSinks(sinks ...Sink) error {
+	self.core.mu.Lock()
+	old := self.core.sinks
+	self.core.sinks = sinks
+	self.core.mu.Unlock()
+	return closeAll(old)
+}
+
+func (self *Logger) Reopen() error {
+	self.core.mu.RLock()
+	defer self.core.mu.RUnlock()
+	var err error
+	for _, sink := range self.core.sinks {
+		if reopenErr := sink.Reopen(); reopenErr != nil && err == nil {
+			err = reopenErr
+		}
+	}
+	return err
+}
+
+func (self *Logger) Close() error {
+	return self.SetSinks()
+}
+
+func closeAll(sinks []Sink) (err error) {
+	for _, sink := range sinks {
+		if closeErr := sink.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func pairs(keyvalues []interface{}) []Field {
+	fields := make([]Field, 0, (len(keyvalues)+1)/2)
+	for index := 0; index < len(keyvalues); index += 2 {
+		key, ok := keyvalues[index].(string)
+		if !ok {
+			key = fmt.Sprint(keyvalues[index])
+		}
+		if index+1 == len(keyvalues) {
+			// NOTE: A missing value is a bug at the call site, it is kept
+			// visible rather than dropped.
+			fields = append(fields, Field{Key: "!missing", Value: key})
+			break
+		}
+		fields = append(fields, Field{Key: key, Value: keyvalues[index+1]})
+	}
+	return fields
+}
@@ -0,0 +1,119 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func TestLevels(t *testing.T) {
+	var output bytes.Buffer
+	logger := New(Info, NewWriterSink(&output, TextEncoder{OmitTime: true}))
+	logger.SetLevel("server", Debug)
+	logger.SetLevel("server.http", Error)
+	tests := []struct {
+		component string
+		level     Level
+		enabled   bool
+	}{
+		{"", Debug, false},
+		{"", Info, true},
+		{"store", Debug, false},
+		{"server", Debug, true},
+		{"server.rpc", Debug, true},
+		{"server.http", Warn, false},
+		{"server.http.access", Error, true},
+	}
+	for _, test := range tests {
+		component := logger
+		for _, name := range strings.Split(test.component, ".") {
+			if name != "" {
+				component = component.Named(name)
+			}
+		}
+		if component.Enabled(test.level) != test.enabled {
+			t.Errorf("%q at %s enabled %v, expected %v", test.component, test.level, !test.enabled, test.enabled)
+		}
+	}
+	logger.Named("store").Debug("hidden")
+	logger.Named("server").With("id", 7).Debug("shown", "path", "/a b")
+	if output.String() != "DEBUG server: shown id=7 path=\"/a b\"\n" {
+		t.Errorf("logged %q", output.String())
+	}
+	logger.ResetLevels()
+	if levels := logger.Levels(); len(levels) != 1 || levels[""] != Info {
+		t.Errorf("levels after a reset %v", levels)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		value string
+		level Level
+		valid bool
+	}{
+		{"debug", Debug, true},
+		{"", Info, true},
+		{"WARNING", Warn, true},
+		{"error", Error, true},
+		{"loud", Info, false},
+	}
+	for _, test := range tests {
+		level, err := ParseLevel(test.value)
+		if level != test.level || (err == nil) != test.valid {
+			t.Errorf("%q parsed as %s, %v", test.value, level, err)
+		}
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	record := Record{
+		Time:      testTime,
+		Level:     Warn,
+		Component: "server",
+		Message:   "slow request",
+		Fields:    []Field{{"path", "/notes"}, {"took", 1500 * time.Millisecond}, {"err", errors.New("timed out")}},
+	}
+	tests := []struct {
+		encoder Encoder
+		line    string
+	}{
+		{TextEncoder{}, `2026-01-02T15:04:05.000Z WARN  server: slow request path=/notes took=1.5s err="timed out"` + "\n"},
+		{TextEncoder{OmitTime: true}, `WARN  server: slow request path=/notes took=1.5s err="timed out"` + "\n"},
+		{LogfmtEncoder{}, `time=2026-01-02T15:04:05.000Z level=warn component=server msg="slow request" path=/notes took=1.5s err="timed out"` + "\n"},
+		{JSONEncoder{}, `{"time":"2026-01-02T15:04:05.000Z","level":"warn","component":"server","message":"slow request","path":"/notes","took":"1.5s","err":"timed out"}` + "\n"},
+	}
+	for _, test := range tests {
+		if line := string(test.encoder.Encode(record)); line != test.line {
+			t.Errorf("%T encoded\n%s expected\n%s", test.encoder, line, test.line)
+		}
+	}
+}
+
+func TestPairs(t *testing.T) {
+	tests := []struct {
+		keyvalues []interface{}
+		fields    []Field
+	}{
+		{nil, []Field{}},
+		{[]interface{}{"a", 1, "b", "x"}, []Field{{"a", 1}, {"b", "x"}}},
+		{[]interface{}{"a", 1, "dangling"}, []Field{{"a", 1}, {"!missing", "dangling"}}},
+		{[]interface{}{7, true}, []Field{{"7", true}}},
+	}
+	for _, test := range tests {
+		fields := pairs(test.keyvalues)
+		if len(fields) != len(test.fields) {
+			t.Errorf("%v paired as %v", test.keyvalues, fields)
+			continue
+		}
+		for index := range fields {
+			if fields[index] != test.fields[index] {
+				t.Errorf("%v paired as %v", test.keyvalues, fields)
+			}
+		}
+	}
+}
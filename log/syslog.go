@@ -0,0 +1,102 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyslogSockets are the local syslog sockets, in the order they are tried.
+var SyslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// facility is LOG_DAEMON.
+const facility = 3
+
+// SyslogSink writes RFC 3164 messages to the local syslog socket. A message
+// that cannot be delivered is retried once on a fresh connection, so a syslog
+// restart loses nothing but the time it was down.
+type SyslogSink struct {
+	Tag     string
+	Encoder Encoder
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func NewSyslogSink(tag string) (*SyslogSink, error) {
+	sink := &SyslogSink{Tag: tag, Encoder: TextEncoder{OmitTime: true}}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return sink, sink.dial()
+}
+
+func (self *SyslogSink) dial() error {
+	var err error
+	for _, path := range SyslogSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			var conn net.Conn
+			if conn, err = net.Dial(network, path); err == nil {
+				self.conn = conn
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no syslog socket: %w", err)
+}
+
+func severity(level Level) int {
+	switch level {
+	case Debug:
+		return 7
+	case Info:
+		return 6
+	case Warn:
+		return 4
+	}
+	return 3
+}
+
+func (self *SyslogSink) Write(record Record) error {
+	line := strings.TrimSuffix(string(self.Encoder.Encode(record)), "\n")
+	message := fmt.Sprintf("<%d>%s %s[%d]: %s\n", facility*8+severity(record.Level),
+		record.Time.Format(time.Stamp), self.Tag, os.Getpid(), line)
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		if self.conn == nil {
+			if err := self.dial(); err != nil {
+				return err
+			}
+		}
+		if _, err := self.conn.Write([]byte(message)); err == nil {
+			return nil
+		}
+		self.conn.Close()
+		self.conn = nil
+	}
+	return fmt.Errorf("syslog write failed")
+}
+
+func (self *SyslogSink) Reopen() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.conn != nil {
+		self.conn.Close()
+		self.conn = nil
+	}
+	return self.dial()
+}
+
+func (self *SyslogSink) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.conn == nil {
+		return nil
+	}
+	err := self.conn.Close()
+	self.conn = nil
+	return err
+}
@@ -0,0 +1,69 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"./config"
+	"./fault"
+	"./log"
+)
+
+// LogFile is where the daemon logs when the file sink is enabled.
+func (self *Application) LogFile() string {
+	return fmt.Sprintf("%s/logs/%s.log", self.State.Path, self.Name)
+}
+
+// OpenLog replaces the stderr logger with the sinks of the log config. The
+// sinks are rebuilt when the config is reloaded and closed on shutdown.
+func (self *Application) OpenLog() error {
+	err := self.openLog()
+	self.OnReload(func(*config.Config) error { return self.openLog() })
+	self.OnShutdown(func(context.Context) error { return self.Log.Close() })
+	return err
+}
+
+func (self *Application) openLog() error {
+	settings := config.Default().Log
+	if self.Settings != nil {
+		settings = self.Settings.Log
+	}
+	encoder, err := log.ParseFormat(string(settings.Format))
+	if err != nil {
+		return fault.Wrap(err, fault.Config, "config.log", "invalid log format").
+			WithHint("use text, json or logfmt")
+	}
+	var sinks []log.Sink
+	if settings.Stderr {
+		sinks = append(sinks, log.NewWriterSink(self.IO.Error, encoder))
+	}
+	if settings.File.Enabled {
+		file, fileErr := log.NewFileSink(self.LogFile(), encoder)
+		if fileErr != nil {
+			err = fault.Wrap(fileErr, fault.IO, "io.log", "failed to open %s", self.LogFile())
+		} else {
+			file.MaxSize, file.MaxAge = settings.File.MaxSize, settings.File.MaxAge
+			file.Backups, file.Compress = settings.File.Backups, settings.File.Compress
+			sinks = append(sinks, file)
+		}
+	}
+	if settings.Syslog {
+		syslog, syslogErr := log.NewSyslogSink(self.Name)
+		if syslogErr != nil && err == nil {
+			err = fault.Wrap(syslogErr, fault.Unavailable, "unavailable.syslog", "failed to connect to syslog")
+		}
+		if syslogErr == nil {
+			sinks = append(sinks, syslog)
+		}
+	}
+	// NOTE: Logs must go somewhere, stderr is kept when nothing else works.
+	if len(sinks) == 0 {
+		sinks = append(sinks, log.NewWriterSink(os.Stderr, encoder))
+	}
+	if closeErr := self.Log.SetSinks(sinks...); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	settings.Apply(self.Log)
+	return err
+}
@@ -0,0 +1,22 @@
+package application
+
+import (
+	"./metrics"
+)
+
+// instrument registers the built-in metrics: the process, the listeners, the
+// control socket, the scheduled jobs, the event bus and the actions.
+func (self *Application) instrument() {
+	metrics.RegisterProcess(self.Metrics)
+	self.Server.Instrument(self.Metrics)
+	self.RPC.Instrument(self.Metrics)
+	self.Jobs.Instrument(self.Metrics)
+	self.Events.Instrument(self.Metrics)
+	self.Actions.Instrument(self.Metrics)
+	self.Metrics.Collect("app_info", "name and version of the application", metrics.GaugeType, func() []metrics.Sample {
+		return []metrics.Sample{{Labels: []metrics.Label{
+			{Name: "name", Value: self.Name},
+			{Name: "version", Value: self.Version.String()},
+		}, Value: 1}}
+	})
+}
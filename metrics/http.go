@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	// NOTE: HTTP mounts the metrics on the web listener, they are always
+	// available over the control socket (`app-cli daemon metrics`).
+	HTTP bool   `yaml:"http" json:"http"`
+	Path string `yaml:"path" json:"path" validate:"pattern=^/"`
+}
+
+func DefaultConfig() Config {
+	return Config{Path: "/metrics"}
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (self *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		self.Gather().WriteTo(w)
+	})
+}
+
+// Mount adds the metrics endpoint to a mux, at /metrics when path is empty.
+func (self *Registry) Mount(mux *http.ServeMux, path string) {
+	if path == "" {
+		path = DefaultConfig().Path
+	}
+	mux.Handle(path, self.Handler())
+}
+
+// InstrumentHandler counts requests by method and status code and observes
+// their duration, labelled with the listener name.
+func (self *Registry) InstrumentHandler(listener string, next http.Handler) http.Handler {
+	requests := self.Counter("http_requests_total", "HTTP requests served", "listener", "method", "code")
+	durations := self.Histogram("http_request_duration_seconds", "HTTP request duration", nil, "listener")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		durations.Observe(time.Since(started).Seconds(), listener)
+		requests.Inc(listener, method(r.Method), strconv.Itoa(recorder.status))
+	})
+}
+
+// method keeps arbitrary request methods from growing the label set.
+func method(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "other"
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (self *statusRecorder) WriteHeader(status int) {
+	if !self.wroteHeader {
+		self.status, self.wroteHeader = status, true
+	}
+	self.ResponseWriter.WriteHeader(status)
+}
+
+func (self *statusRecorder) Flush() {
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (self *statusRecorder) Unwrap() http.ResponseWriter { return self.ResponseWriter }
@@ -0,0 +1,358 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Metrics are registered once, by name, on a Registry and updated with label
+// values in the order the label names were declared:
+//
+//   requests := app.Metrics.Counter("jobs_runs_total", "job runs", "job", "result")
+//   requests.Inc("backup", "ok")
+//
+// Registering an existing name again returns the existing metric, so code can
+// instrument itself without coordinating. A Registry is exposed in the
+// Prometheus text format, over HTTP (Handler) and the control socket.
+////////////////////////////////////////////////////////////////////////////////
+
+type Type string
+
+const (
+	CounterType   Type = "counter"
+	GaugeType     Type = "gauge"
+	HistogramType Type = "histogram"
+)
+
+type Label struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+}
+
+type Sample struct {
+	// NOTE: Name is only set when it differs from the family name, as for the
+	// _bucket, _sum and _count samples of a histogram.
+	Name   string  `json:"name,omitempty" yaml:"name,omitempty"`
+	Labels []Label `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Value  float64 `json:"value" yaml:"value"`
+}
+
+type Family struct {
+	Name    string   `json:"name" yaml:"name"`
+	Help    string   `json:"help" yaml:"help"`
+	Type    Type     `json:"type" yaml:"type"`
+	Samples []Sample `json:"samples" yaml:"samples"`
+}
+
+type collector interface {
+	family() Family
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+func (self *Registry) register(name string, kind Type, create func() collector) collector {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if existing, ok := self.collectors[name]; ok {
+		if existing.family().Type != kind {
+			panic(fmt.Sprintf("metrics: %s is already registered as a %s", name, existing.family().Type))
+		}
+		return existing
+	}
+	created := create()
+	self.collectors[name] = created
+	return created
+}
+
+// Gather returns every metric, sorted by name.
+func (self *Registry) Gather() Snapshot {
+	self.mu.Lock()
+	collectors := make([]collector, 0, len(self.collectors))
+	for _, collector := range self.collectors {
+		collectors = append(collectors, collector)
+	}
+	self.mu.Unlock()
+	snapshot := make(Snapshot, 0, len(collectors))
+	for _, collector := range collectors {
+		snapshot = append(snapshot, collector.family())
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Name < snapshot[j].Name })
+	return snapshot
+}
+
+// vector ////////////////////////////////////////////////////////////////////
+type vector struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (self vector) key(values []string) string {
+	if len(values) != len(self.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", self.name, len(self.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func (self vector) pairs(key string) []Label {
+	if len(self.labels) == 0 {
+		return nil
+	}
+	values := strings.Split(key, "\xff")
+	labels := make([]Label, len(self.labels))
+	for index, name := range self.labels {
+		labels[index] = Label{Name: name, Value: values[index]}
+	}
+	return labels
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter ////////////////////////////////////////////////////////////////////
+// Counter only goes up, it is reset when the process restarts.
+type Counter struct {
+	vector
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func (self *Registry) Counter(name, help string, labels ...string) *Counter {
+	return self.register(name, CounterType, func() collector {
+		return &Counter{vector: vector{name, help, labels}, values: make(map[string]float64)}
+	}).(*Counter)
+}
+
+func (self *Counter) Inc(labels ...string) { self.Add(1, labels...) }
+
+func (self *Counter) Add(value float64, labels ...string) {
+	if value < 0 {
+		return
+	}
+	key := self.key(labels)
+	self.mu.Lock()
+	self.values[key] += value
+	self.mu.Unlock()
+}
+
+func (self *Counter) family() Family {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	family := Family{Name: self.name, Help: self.help, Type: CounterType}
+	for _, key := range sortedKeys(self.values) {
+		family.Samples = append(family.Samples, Sample{Labels: self.pairs(key), Value: self.values[key]})
+	}
+	return family
+}
+
+// Gauge //////////////////////////////////////////////////////////////////////
+type Gauge struct {
+	vector
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func (self *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	return self.register(name, GaugeType, func() collector {
+		return &Gauge{vector: vector{name, help, labels}, values: make(map[string]float64)}
+	}).(*Gauge)
+}
+
+func (self *Gauge) Set(value float64, labels ...string) {
+	key := self.key(labels)
+	self.mu.Lock()
+	self.values[key] = value
+	self.mu.Unlock()
+}
+
+func (self *Gauge) Add(value float64, labels ...string) {
+	key := self.key(labels)
+	self.mu.Lock()
+	self.values[key] += value
+	self.mu.Unlock()
+}
+
+func (self *Gauge) Inc(labels ...string) { self.Add(1, labels...) }
+func (self *Gauge) Dec(labels ...string) { self.Add(-1, labels...) }
+
+func (self *Gauge) family() Family {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	family := Family{Name: self.name, Help: self.help, Type: GaugeType}
+	for _, key := range sortedKeys(self.values) {
+		family.Samples = append(family.Samples, Sample{Labels: self.pairs(key), Value: self.values[key]})
+	}
+	return family
+}
+
+// Histogram //////////////////////////////////////////////////////////////////
+// DefaultBuckets suit durations in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type Histogram struct {
+	vector
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*observations
+}
+
+type observations struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Histogram registers a histogram, with DefaultBuckets when buckets is nil.
+func (self *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return self.register(name, HistogramType, func() collector {
+		return &Histogram{vector: vector{name, help, labels}, buckets: buckets, values: make(map[string]*observations)}
+	}).(*Histogram)
+}
+
+func (self *Histogram) Observe(value float64, labels ...string) {
+	key := self.key(labels)
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	observed, ok := self.values[key]
+	if !ok {
+		observed = &observations{counts: make([]uint64, len(self.buckets))}
+		self.values[key] = observed
+	}
+	for index, bound := range self.buckets {
+		if value <= bound {
+			observed.counts[index]++
+		}
+	}
+	observed.count++
+	observed.sum += value
+}
+
+func (self *Histogram) family() Family {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	keys := make([]string, 0, len(self.values))
+	for key := range self.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	family := Family{Name: self.name, Help: self.help, Type: HistogramType}
+	for _, key := range keys {
+		observed, labels := self.values[key], self.pairs(key)
+		for index, bound := range self.buckets {
+			family.Samples = append(family.Samples, Sample{
+				Name:   self.name + "_bucket",
+				Labels: append(append([]Label{}, labels...), Label{Name: "le", Value: formatFloat(bound)}),
+				Value:  float64(observed.counts[index]),
+			})
+		}
+		family.Samples = append(family.Samples,
+			Sample{Name: self.name + "_bucket", Labels: append(append([]Label{}, labels...), Label{Name: "le", Value: "+Inf"}), Value: float64(observed.count)},
+			Sample{Name: self.name + "_sum", Labels: labels, Value: observed.sum},
+			Sample{Name: self.name + "_count", Labels: labels, Value: float64(observed.count)},
+		)
+	}
+	return family
+}
+
+// Collect ////////////////////////////////////////////////////////////////////
+// Collect registers a metric read when the registry is gathered, for values
+// owned by something else such as the runtime or a listener.
+func (self *Registry) Collect(name, help string, kind Type, collect func() []Sample) {
+	self.register(name, kind, func() collector {
+		return &function{Family: Family{Name: name, Help: help, Type: kind}, collect: collect}
+	})
+}
+
+type function struct {
+	Family
+	collect func() []Sample
+}
+
+func (self *function) family() Family {
+	family := self.Family
+	family.Samples = self.collect()
+	return family
+}
+
+// Snapshot ///////////////////////////////////////////////////////////////////
+type Snapshot []Family
+
+// String is the Prometheus text exposition format.
+func (self Snapshot) String() string {
+	var output strings.Builder
+	self.WriteTo(&output)
+	return strings.TrimSuffix(output.String(), "\n")
+}
+
+func (self Snapshot) WriteTo(w io.Writer) (int64, error) {
+	var output strings.Builder
+	for _, family := range self {
+		fmt.Fprintf(&output, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(&output, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			name := sample.Name
+			if name == "" {
+				name = family.Name
+			}
+			output.WriteString(name)
+			if 0 < len(sample.Labels) {
+				output.WriteString("{")
+				for index, label := range sample.Labels {
+					if index > 0 {
+						output.WriteString(",")
+					}
+					fmt.Fprintf(&output, "%s=\"%s\"", label.Name, escapeLabel(label.Value))
+				}
+				output.WriteString("}")
+			}
+			fmt.Fprintf(&output, " %s\n", formatFloat(sample.Value))
+		}
+	}
+	written, err := io.WriteString(w, output.String())
+	return int64(written), err
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(help string) string   { return helpEscaper.Replace(help) }
+func escapeLabel(value string) string { return labelEscaper.Replace(value) }
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExposition(t *testing.T) {
+	registry := NewRegistry()
+	runs := registry.Counter("jobs_runs_total", "job runs", "job", "result")
+	runs.Inc("backup", "ok")
+	runs.Add(2, "backup", "ok")
+	runs.Add(-1, "backup", "ok")
+	runs.Inc("sync", "error")
+	registry.Gauge("queue_depth", "queued jobs").Set(3)
+	registry.Gauge("queue_depth", "queued jobs").Dec()
+	latency := registry.Histogram("latency_seconds", "line one\nline \\two", []float64{1, 0.1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(5)
+	registry.Collect("build_info", "build", GaugeType, func() []Sample {
+		return []Sample{{Labels: []Label{{Name: "version", Value: `1.0 "rc"`}}, Value: 1}}
+	})
+	expected := strings.Join([]string{
+		`# HELP build_info build`,
+		`# TYPE build_info gauge`,
+		`build_info{version="1.0 \"rc\""} 1`,
+		`# HELP jobs_runs_total job runs`,
+		`# TYPE jobs_runs_total counter`,
+		`jobs_runs_total{job="backup",result="ok"} 3`,
+		`jobs_runs_total{job="sync",result="error"} 1`,
+		`# HELP latency_seconds line one\nline \\two`,
+		`# TYPE latency_seconds histogram`,
+		`latency_seconds_bucket{le="0.1"} 1`,
+		`latency_seconds_bucket{le="1"} 2`,
+		`latency_seconds_bucket{le="+Inf"} 3`,
+		`latency_seconds_sum 5.55`,
+		`latency_seconds_count 3`,
+		`# HELP queue_depth queued jobs`,
+		`# TYPE queue_depth gauge`,
+		`queue_depth 2`,
+	}, "\n")
+	if output := registry.Gather().String(); output != expected {
+		t.Errorf("exposed\n%s\nexpected\n%s", output, expected)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	registry := NewRegistry()
+	if registry.Counter("hits_total", "hits") != registry.Counter("hits_total", "hits") {
+		t.Error("registering a name again did not return the existing metric")
+	}
+	tests := []struct {
+		name string
+		call func()
+	}{
+		{"type mismatch", func() { registry.Gauge("hits_total", "hits") }},
+		{"label count", func() { registry.Counter("hits_total", "hits").Inc("extra") }},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", test.name)
+				}
+			}()
+			test.call()
+		}()
+	}
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	registry := NewRegistry()
+	handler := registry.InstrumentHandler("web", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/"},
+		{"GET", "/missing"},
+		{"BREW", "/"},
+	}
+	for _, test := range tests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(test.method, test.path, nil))
+	}
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	for _, line := range []string{
+		`http_requests_total{listener="web",method="GET",code="200"} 1`,
+		`http_requests_total{listener="web",method="GET",code="404"} 1`,
+		`http_requests_total{listener="web",method="other",code="200"} 1`,
+		`http_request_duration_seconds_count{listener="web"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("the exposition lacks %s", line)
+		}
+	}
+	if !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("served as %s", recorder.Header().Get("Content-Type"))
+	}
+}
+
+func TestProcess(t *testing.T) {
+	registry := NewRegistry()
+	RegisterProcess(registry)
+	gathered := make(map[string]Family)
+	for _, family := range registry.Gather() {
+		gathered[family.Name] = family
+	}
+	for _, name := range []string{"process_start_time_seconds", "process_max_fds", "go_goroutines"} {
+		if family, ok := gathered[name]; !ok || len(family.Samples) == 0 || family.Samples[0].Value <= 0 {
+			t.Errorf("%s gathered as %+v", name, family)
+		}
+	}
+}
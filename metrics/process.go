@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// NOTE: Linux reports CPU time in clock ticks, USER_HZ is 100 on every
+// supported architecture.
+const clockTicks = 100
+
+// RegisterProcess adds the process metrics: resident memory, open file
+// descriptors and CPU time from /proc, goroutines and GC from the runtime.
+func RegisterProcess(registry *Registry) {
+	started := float64(time.Now().Unix())
+	registry.Collect("process_start_time_seconds", "start time of the process since the unix epoch", GaugeType, func() []Sample {
+		return []Sample{{Value: started}}
+	})
+	registry.Collect("process_resident_memory_bytes", "resident memory size in bytes", GaugeType, func() []Sample {
+		return procSample(residentMemory)
+	})
+	registry.Collect("process_cpu_seconds_total", "user and system CPU time spent in seconds", CounterType, func() []Sample {
+		return procSample(cpuSeconds)
+	})
+	registry.Collect("process_open_fds", "number of open file descriptors", GaugeType, func() []Sample {
+		return procSample(openFDs)
+	})
+	registry.Collect("process_max_fds", "maximum number of open file descriptors", GaugeType, func() []Sample {
+		var limit syscall.Rlimit
+		if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit); err != nil {
+			return nil
+		}
+		return []Sample{{Value: float64(limit.Cur)}}
+	})
+	registry.Collect("go_goroutines", "number of goroutines", GaugeType, func() []Sample {
+		return []Sample{{Value: float64(runtime.NumGoroutine())}}
+	})
+	registry.Collect("go_threads", "number of OS threads", GaugeType, func() []Sample {
+		threads, _ := runtime.ThreadCreateProfile(nil)
+		return []Sample{{Value: float64(threads)}}
+	})
+	registry.Collect("go_memstats_heap_alloc_bytes", "heap bytes allocated and in use", GaugeType, func() []Sample {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return []Sample{{Value: float64(stats.HeapAlloc)}}
+	})
+	registry.Collect("go_gc_cycles_total", "completed GC cycles", CounterType, func() []Sample {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return []Sample{{Value: float64(stats.NumGC)}}
+	})
+	registry.Collect("go_gc_pause_seconds_total", "total time the GC stopped the world", CounterType, func() []Sample {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return []Sample{{Value: float64(stats.PauseTotalNs) / float64(time.Second)}}
+	})
+}
+
+// procSample returns no sample where /proc is not available.
+func procSample(read func() (float64, error)) []Sample {
+	value, err := read()
+	if err != nil {
+		return nil
+	}
+	return []Sample{{Value: value}}
+}
+
+// stat returns the fields of /proc/self/stat after the command name, which
+// may itself contain spaces; field 3 (state) is at index 0.
+func stat() ([]string, error) {
+	data, err := ioutil.ReadFile("/proc/self/stat")
+	if err != nil {
+		return nil, err
+	}
+	text := string(data)
+	return strings.Fields(text[strings.LastIndexByte(text, ')')+1:]), nil
+}
+
+func statField(fields []string, field int) float64 {
+	if len(fields) <= field-3 {
+		return 0
+	}
+	value, _ := strconv.ParseFloat(fields[field-3], 64)
+	return value
+}
+
+func residentMemory() (float64, error) {
+	fields, err := stat()
+	if err != nil {
+		return 0, err
+	}
+	return statField(fields, 24) * float64(os.Getpagesize()), nil
+}
+
+func cpuSeconds() (float64, error) {
+	fields, err := stat()
+	if err != nil {
+		return 0, err
+	}
+	return (statField(fields, 14) + statField(fields, 15)) / clockTicks, nil
+}
+
+func openFDs() (float64, error) {
+	entries, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	return float64(len(entries)), nil
+}
@@ -0,0 +1,223 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"../fault"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Every create, update and delete through a repository is recorded as a
+// Change, with the document before and after it, in the changes collection
+// beside the models (`<collection>.changes`). Changes are numbered by a
+// cursor that only grows, so a watcher that stopped resumes after the last
+// cursor it handled:
+//
+//   cursor, _ := model.Latest(app.Store, "notes")
+//   model.Watch(ctx, app.Store, "notes", cursor, func(change model.Change) error {
+//     ...
+//     return nil
+//   })
+//
+// The latest KeepChanges changes are kept; resuming from an older cursor
+// fails, the watcher must read the models again.
+////////////////////////////////////////////////////////////////////////////////
+
+const KeepChanges = 1000
+
+type ChangeType string
+
+const (
+	Created ChangeType = "create"
+	Updated ChangeType = "update"
+	Deleted ChangeType = "delete"
+)
+
+type Change struct {
+	Cursor     uint64     `json:"cursor" yaml:"cursor"`
+	Collection string     `json:"collection" yaml:"collection"`
+	Type       ChangeType `json:"type" yaml:"type"`
+	ID         string     `json:"id" yaml:"id"`
+	Revision   uint64     `json:"revision" yaml:"revision"`
+	Time       time.Time  `json:"time" yaml:"time"`
+	// NOTE: Old is missing from creates, New from deletes.
+	Old Document `json:"old,omitempty" yaml:"old,omitempty"`
+	New Document `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+func (self Change) String() string {
+	return fmt.Sprintf("%d %s %s %s revision %d", self.Cursor, self.Type, self.Collection, self.ID, self.Revision)
+}
+
+var ErrCursorExpired = errors.New("cursor expired")
+
+func changes(collection string) string { return collection + ".changes" }
+
+func changeID(cursor uint64) string { return fmt.Sprintf("%016d", cursor) }
+
+// sequence is the document holding the latest cursor of a collection.
+const sequence = "sequence"
+
+// Latest is the cursor of the latest change of a collection, 0 if none.
+func Latest(backend Backend, collection string) (uint64, error) {
+	data, err := backend.Read(changes(collection), sequence)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	var latest struct {
+		Cursor uint64 `json:"cursor" yaml:"cursor"`
+	}
+	err = backend.Codec().Unmarshal(data, &latest)
+	return latest.Cursor, err
+}
+
+// Changes returns the changes of a collection after a cursor, oldest first.
+func Changes(backend Backend, collection string, after uint64) ([]Change, error) {
+	latest, err := Latest(backend, collection)
+	if err != nil {
+		return nil, err
+	}
+	// NOTE: Changes are read by cursor, without listing the collection, so a
+	// watcher that is up to date only reads the sequence. From the start,
+	// the changes pruned are skipped: prune keeps at most 63 more than
+	// KeepChanges.
+	from := after + 1
+	if after == 0 && KeepChanges+64 < latest {
+		from = latest - KeepChanges - 63
+	}
+	var found []Change
+	for cursor := from; cursor <= latest; cursor++ {
+		data, err := backend.Read(changes(collection), changeID(cursor))
+		if errors.Is(err, ErrNotFound) {
+			if 0 < after && cursor == from {
+				return nil, fault.Wrap(ErrCursorExpired, fault.Usage, "usage.cursor_expired",
+					"%s changes after cursor %d are no longer kept", collection, after).
+					WithHint("read the models again and watch from the latest cursor")
+			}
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var change Change
+		if err := backend.Codec().Unmarshal(data, &change); err != nil {
+			return nil, fault.Wrap(err, fault.IO, "io.corrupt", "change %d of %s is unreadable", cursor, collection)
+		}
+		found = append(found, change)
+	}
+	return found, nil
+}
+
+// Watch calls handler with every change of a collection after cursor, as they
+// happen, until ctx is done or handler fails. Changes made in this process
+// wake it at once; for changes made by others it checks the latest cursor
+// every second.
+func Watch(ctx context.Context, backend Backend, collection string, cursor uint64, handler func(Change) error) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		// NOTE: Waiting starts before reading so a change in between wakes
+		// the next wait.
+		changed := waiting(collection)
+		found, err := Changes(backend, collection, cursor)
+		if err != nil {
+			return err
+		}
+		for _, change := range found {
+			if err := handler(change); err != nil {
+				return err
+			}
+			cursor = change.Cursor
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// record appends a change, filling in its cursor, and updates the indexes;
+// the caller holds the collection lock.
+func record(backend Backend, collection string, change *Change) error {
+	if err := updateIndexes(backend, collection, change); err != nil {
+		return err
+	}
+	latest, err := Latest(backend, collection)
+	if err != nil {
+		return err
+	}
+	change.Cursor, change.Collection, change.Time = latest+1, collection, time.Now().UTC()
+	data, err := backend.Codec().Marshal(change)
+	if err != nil {
+		return err
+	}
+	if err := backend.Write(changes(collection), changeID(change.Cursor), data); err != nil {
+		return err
+	}
+	data, err = backend.Codec().Marshal(map[string]uint64{"cursor": change.Cursor})
+	if err != nil {
+		return err
+	}
+	if err := backend.Write(changes(collection), sequence, data); err != nil {
+		return err
+	}
+	if change.Cursor%64 == 0 && KeepChanges < change.Cursor {
+		prune(backend, collection, change.Cursor-KeepChanges)
+	}
+	notify(collection)
+	return nil
+}
+
+// prune removes the changes up to a cursor.
+func prune(backend Backend, collection string, until uint64) {
+	ids, _ := backend.List(changes(collection))
+	for _, id := range ids {
+		if cursor, err := strconv.ParseUint(id, 10, 64); err == nil && cursor <= until {
+			backend.Delete(changes(collection), id)
+		}
+	}
+}
+
+// Notifications ////////////////////////////////////////////////////////////
+var (
+	notifyMu sync.Mutex
+	waiters  = make(map[string]chan struct{})
+)
+
+func waiting(collection string) <-chan struct{} {
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+	if waiters[collection] == nil {
+		waiters[collection] = make(chan struct{})
+	}
+	return waiters[collection]
+}
+
+func notify(collection string) {
+	notifyMu.Lock()
+	defer notifyMu.Unlock()
+	if waiters[collection] != nil {
+		close(waiters[collection])
+		delete(waiters, collection)
+	}
+}
+
+// document decodes a model into the form a change carries.
+func document(codec Codec, item interface{}) (Document, error) {
+	data, err := codec.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	document := make(Document)
+	err = codec.Unmarshal(data, &document)
+	return document, err
+}
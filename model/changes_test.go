@@ -0,0 +1,149 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"../fault"
+)
+
+func TestChanges(t *testing.T) {
+	for name, backend := range backends(t) {
+		notes := NewRepository[note](backend, "notes")
+		created := &note{Title: "first"}
+		notes.Create(created)
+		created.Title = "second"
+		notes.Update(created)
+		notes.Delete(created.ID, created.Revision)
+
+		tests := []struct {
+			after uint64
+			types []ChangeType
+		}{
+			{0, []ChangeType{Created, Updated, Deleted}},
+			{1, []ChangeType{Updated, Deleted}},
+			{3, nil},
+			{10, nil},
+		}
+		for _, test := range tests {
+			found, err := Changes(backend, "notes", test.after)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			var types []ChangeType
+			for index, change := range found {
+				types = append(types, change.Type)
+				if change.Cursor != test.after+uint64(index)+1 || change.ID != created.ID || change.Collection != "notes" {
+					t.Errorf("%s: after %d found %s", name, test.after, change)
+				}
+			}
+			if !reflect.DeepEqual(types, test.types) {
+				t.Errorf("%s: after %d found %v, expected %v", name, test.after, types, test.types)
+			}
+		}
+		found, _ := Changes(backend, "notes", 1)
+		if found[0].Old["title"] != "first" || found[0].New["title"] != "second" || found[1].New != nil {
+			t.Errorf("%s: the documents of the changes are %v", name, found)
+		}
+		if latest, err := Latest(backend, "notes"); latest != 3 || err != nil {
+			t.Errorf("%s: the latest cursor is %d, %v", name, latest, err)
+		}
+	}
+}
+
+func TestExpired(t *testing.T) {
+	backend := backends(t)["kv"]
+	notes := NewRepository[note](backend, "notes")
+	item := &note{Title: "counted"}
+	notes.Create(item)
+	for cursor := 1; cursor < KeepChanges+100; cursor++ {
+		if err := notes.Update(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	all, err := Changes(backend, "notes", 0)
+	if err != nil || len(all) < KeepChanges || all[len(all)-1].Cursor != KeepChanges+100 {
+		t.Fatalf("read %d changes from the start, %v", len(all), err)
+	}
+	if _, err := Changes(backend, "notes", 1); !errors.Is(err, ErrCursorExpired) || fault.As(err).Code != "usage.cursor_expired" {
+		t.Errorf("an expired cursor returned %v", err)
+	}
+	if found, err := Changes(backend, "notes", all[0].Cursor); err != nil || len(found) != len(all)-1 {
+		t.Errorf("the oldest kept cursor read %d changes, %v", len(found), err)
+	}
+}
+
+// counting is a backend that counts the documents read and the changes
+// listed.
+type counting struct {
+	Backend
+	lists, reads atomic.Int32
+}
+
+func (self *counting) List(collection string) ([]string, error) {
+	if collection == changes("notes") {
+		self.lists.Add(1)
+	}
+	return self.Backend.List(collection)
+}
+
+func (self *counting) Read(collection, id string) ([]byte, error) {
+	self.reads.Add(1)
+	return self.Backend.Read(collection, id)
+}
+
+func TestWatch(t *testing.T) {
+	backend := &counting{Backend: backends(t)["kv"]}
+	notes := NewRepository[note](backend, "notes")
+	notes.Create(&note{Title: "before"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watched := make(chan Change, 10)
+	done := make(chan error)
+	go func() {
+		done <- notes.Watch(ctx, 1, func(change Change) error {
+			watched <- change
+			return nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	for _, title := range []string{"a", "b"} {
+		backend.reads.Store(0)
+		item := &note{Title: title}
+		notes.Create(item)
+		select {
+		case change := <-watched:
+			if change.Type != Created || change.ID != item.ID {
+				t.Errorf("watched %s, expected the create of %s", change, item.ID)
+			}
+		case <-time.After(500 * time.Millisecond):
+			t.Fatalf("the create of %s was not watched at once", title)
+		}
+	}
+	// NOTE: A create reads whether the model exists and the sequence, the
+	// watcher the sequence and the change.
+	time.Sleep(20 * time.Millisecond)
+	if reads := backend.reads.Load(); 6 < reads || backend.lists.Load() != 0 {
+		t.Errorf("watching a change read %d documents and listed the changes %d times", reads, backend.lists.Load())
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("watching returned %v", err)
+	}
+}
+
+func TestWatchFails(t *testing.T) {
+	backend := backends(t)["json files"]
+	notes := NewRepository[note](backend, "notes")
+	notes.Create(&note{Title: "a"})
+	failure := errors.New("handler failed")
+	err := notes.Watch(context.Background(), 0, func(Change) error { return failure })
+	if err != failure {
+		t.Errorf("watching returned %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"encoding/json"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Codec is how documents are encoded on disk.
+type Codec interface {
+	Extension() string
+	Marshal(interface{}) ([]byte, error)
+	Unmarshal([]byte, interface{}) error
+}
+
+type JSON struct{}
+
+func (JSON) Extension() string { return ".json" }
+
+func (JSON) Marshal(value interface{}) ([]byte, error) {
+	data, err := json.MarshalIndent(value, "", "  ")
+	return append(data, '\n'), err
+}
+
+func (JSON) Unmarshal(data []byte, value interface{}) error { return json.Unmarshal(data, value) }
+
+type YAML struct{}
+
+func (YAML) Extension() string                              { return ".yaml" }
+func (YAML) Marshal(value interface{}) ([]byte, error)      { return yaml.Marshal(value) }
+func (YAML) Unmarshal(data []byte, value interface{}) error { return yaml.Unmarshal(data, value) }
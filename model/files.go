@@ -0,0 +1,137 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Backend stores documents by collection and id. Writes are atomic: a reader
+// sees the old document or the new one, never a partial write.
+type Backend interface {
+	Read(collection, id string) ([]byte, error)
+	Write(collection, id string, data []byte) error
+	Delete(collection, id string) error
+	List(collection string) ([]string, error)
+	// Lock excludes other writers of the collection, in this process and
+	// others, until unlock is called.
+	Lock(collection string) (unlock func(), err error)
+	// Codec is how the repositories encode documents for this backend.
+	Codec() Codec
+}
+
+// Files stores every document as a file, `<root>/<collection>/<id><ext>`.
+type Files struct {
+	Root  string
+	codec Codec
+}
+
+func NewFiles(root string, codec Codec) *Files {
+	return &Files{Root: root, codec: codec}
+}
+
+func (self *Files) Codec() Codec { return self.codec }
+
+func (self *Files) path(collection, id string) string {
+	return filepath.Join(self.Root, collection, id+self.codec.Extension())
+}
+
+func (self *Files) Read(collection, id string) ([]byte, error) {
+	data, err := ioutil.ReadFile(self.path(collection, id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Write writes beside the document, syncs, and renames over it; the directory
+// is synced so the rename survives a crash.
+func (self *Files) Write(collection, id string, data []byte) error {
+	directory := filepath.Join(self.Root, collection)
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return err
+	}
+	temporary, err := ioutil.TempFile(directory, "."+id+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := temporary.Write(data); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(temporary.Name(), self.path(collection, id)); err != nil {
+		return err
+	}
+	return syncDirectory(directory)
+}
+
+func (self *Files) Delete(collection, id string) error {
+	err := os.Remove(self.path(collection, id))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	return syncDirectory(filepath.Join(self.Root, collection))
+}
+
+func (self *Files) List(collection string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(self.Root, collection))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		// NOTE: Dot files are the lock and writes in progress.
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, self.codec.Extension()) {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, self.codec.Extension()))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Lock takes an flock on `<collection>/.lock`, which also excludes other
+// processes such as the cli while the daemon writes.
+func (self *Files) Lock(collection string) (func(), error) {
+	directory := filepath.Join(self.Root, collection)
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(directory, ".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+func syncDirectory(path string) error {
+	directory, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer directory.Close()
+	return directory.Sync()
+}
@@ -0,0 +1,304 @@
+package model
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"../fault"
+)
+
+// Indexes /////////////////////////////////////////////////////////////////////
+// NOTE: An index of a field is a collection, `<collection>.index.<field>`,
+// with a document per model, named by the key of the field then the model id,
+// so listing it lists the models in the order of the field. An index is only
+// used once it is built, which is recorded in `<collection>.indexes`; every
+// write through a repository, or Restore, keeps the ready ones up to date.
+
+// indexKeyLength is how much of a text value is indexed, longer values are
+// told apart by reading the models.
+const indexKeyLength = 48
+
+func indexes(collection string) string { return collection + ".indexes" }
+
+func index(collection, field string) string { return collection + ".index." + field }
+
+// Indexes returns the fields of a collection whose index is built.
+func Indexes(backend Backend, collection string) ([]string, error) {
+	fields, err := backend.List(indexes(collection))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// Reindex builds the index of a field of a collection, whatever model it
+// holds, and uses it from then on. The documents are indexed as they are
+// stored; a repository indexes them at its schema version.
+func Reindex(backend Backend, collection, field string) error {
+	return buildIndex(backend, collection, field, stored(backend, collection))
+}
+
+// buildIndex builds an index with the documents load returns.
+func buildIndex(backend Backend, collection, field string, load func(id string) (Document, error)) error {
+	if !fieldPattern.MatchString(field) {
+		return queryError("invalid field %q", field)
+	}
+	unlock, err := backend.Lock(collection)
+	if err != nil {
+		return fault.Wrap(err, fault.IO, "io.lock", "failed to lock %s", collection)
+	}
+	defer unlock()
+	if err := reindex(backend, collection, field, load); err != nil {
+		return fault.Wrap(err, fault.IO, "io.index", "failed to index %s by %s", collection, field)
+	}
+	return nil
+}
+
+// reindex builds an index; the caller holds the collection lock.
+func reindex(backend Backend, collection, field string, load func(id string) (Document, error)) error {
+	if err := backend.Delete(indexes(collection), field); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	entries, err := backend.List(index(collection, field))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := backend.Delete(index(collection, field), entry); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	ids, err := backend.List(collection)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		document, err := load(id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := putEntry(backend, collection, field, id, document); err != nil {
+			return err
+		}
+	}
+	data, err := backend.Codec().Marshal(map[string]string{"field": field})
+	if err != nil {
+		return err
+	}
+	return backend.Write(indexes(collection), field, data)
+}
+
+// updateIndexes moves a changed model in the ready indexes of its
+// collection; the caller holds the collection lock.
+func updateIndexes(backend Backend, collection string, change *Change) error {
+	fields, err := Indexes(backend, collection)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		old, new := indexKey(change.Old, field), indexKey(change.New, field)
+		if old == new {
+			continue
+		}
+		if old != "" {
+			err := backend.Delete(index(collection, field), old+"."+change.ID)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return err
+			}
+		}
+		if err := putEntry(backend, collection, field, change.ID, change.New); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putEntry(backend Backend, collection, field, id string, document Document) error {
+	key := indexKey(document, field)
+	if key == "" {
+		return nil
+	}
+	data, err := backend.Codec().Marshal(map[string]string{"id": id})
+	if err != nil {
+		return err
+	}
+	return backend.Write(index(collection, field), key+"."+id, data)
+}
+
+// indexKey is the key of the value of a field, ordered as the values are;
+// empty when there is no value, or not one that can be indexed.
+func indexKey(document Document, field string) string {
+	if document == nil {
+		return ""
+	}
+	value, ok := lookup(document, field)
+	if !ok {
+		return ""
+	}
+	switch typed := normalize(value).(type) {
+	case float64:
+		return numberKey(typed)
+	case bool:
+		return boolKey(typed)
+	case string:
+		return textKey(typed)
+	}
+	return ""
+}
+
+// numberKey is the bits of the number, flipped so they sort as numbers do.
+func numberKey(number float64) string {
+	bits := math.Float64bits(number)
+	if number < 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return fmt.Sprintf("n%016x", bits)
+}
+
+func boolKey(flag bool) string {
+	if flag {
+		return "b1"
+	}
+	return "b0"
+}
+
+func textKey(text string) string {
+	if indexKeyLength < len(text) {
+		text = text[:indexKeyLength]
+	}
+	return "s" + hex.EncodeToString([]byte(text))
+}
+
+// lookupIndex returns the ids of the models that may satisfy a predicate,
+// by the index of its field; not ok when the index does not narrow it.
+func lookupIndex(backend Backend, collection string, predicate Predicate) ([]string, bool, error) {
+	match := indexMatcher(predicate)
+	if match == nil {
+		return nil, false, nil
+	}
+	entries, err := backend.List(index(collection, predicate.Field))
+	if err != nil {
+		return nil, false, err
+	}
+	ids := make([]string, 0)
+	for _, entry := range entries {
+		key, id, found := strings.Cut(entry, ".")
+		if found && match(key) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, true, nil
+}
+
+// indexMatcher returns whether the key of an entry may satisfy a predicate;
+// nil when the predicate is also satisfied by models the index leaves out,
+// or it can not tell them apart.
+func indexMatcher(predicate Predicate) func(key string) bool {
+	if predicate.Operator == NotEqual || predicate.Operator == Contains ||
+		predicate.Operator == Equal && predicate.Value == "" {
+		return nil
+	}
+	number, numberErr := strconv.ParseFloat(predicate.Value, 64)
+	flag, flagErr := strconv.ParseBool(predicate.Value)
+	text := textKey(predicate.Value)
+	return func(key string) bool {
+		switch {
+		case strings.HasPrefix(key, "n"):
+			if predicate.Operator == Prefix {
+				return true
+			} else if numberErr != nil {
+				return false
+			}
+			order := strings.Compare(key, numberKey(number))
+			switch predicate.Operator {
+			case Equal:
+				return order == 0
+			case Less:
+				return order < 0
+			case LessOrEqual:
+				return order <= 0
+			case Greater:
+				return 0 < order
+			case GreaterOrEqual:
+				return 0 <= order
+			}
+		case strings.HasPrefix(key, "b"):
+			switch predicate.Operator {
+			case Equal:
+				return flagErr == nil && key == boolKey(flag)
+			case Prefix:
+				return true
+			}
+			return flagErr == nil
+		case strings.HasPrefix(key, "s"):
+			switch predicate.Operator {
+			case Equal:
+				return key == text
+			case Prefix:
+				// NOTE: A prefix longer than the key matches keys it starts
+				// with, the models tell the rest.
+				return strings.HasPrefix(key, text) || strings.HasPrefix(text, key)
+			}
+			// NOTE: Text is ordered by the models, times and all.
+			return true
+		}
+		return false
+	}
+}
+
+// indexedFields are the json names of the fields of a model tagged
+// `index:"true"`, those of embedded structs included.
+func indexedFields(model reflect.Type) []string {
+	var fields []string
+	for number := 0; number < model.NumField(); number++ {
+		field := model.Field(number)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			fields = append(fields, indexedFields(field.Type)...)
+			continue
+		}
+		if indexed, _ := strconv.ParseBool(field.Tag.Get("index")); !indexed || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// ensureIndex builds the index of a field unless it is built.
+func (self *Repository[T]) ensureIndex(field string) error {
+	fields, err := Indexes(self.backend, self.Collection)
+	if err != nil {
+		return fault.Wrap(err, fault.IO, "io.index", "failed to read the indexes of %s", self.Collection)
+	}
+	if contains(fields, field) {
+		return nil
+	}
+	return buildIndex(self.backend, self.Collection, field, self.load)
+}
+
+// load reads a model as a document at the current schema version, as the
+// indexes and queries see it.
+func (self *Repository[T]) load(id string) (Document, error) {
+	item, err := self.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return document(self.codec, item)
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"../kv"
+)
+
+// KV stores documents in a key-value store, under `<collection>/<id>`.
+type KV struct {
+	Store *kv.Store
+	codec Codec
+}
+
+func NewKV(store *kv.Store, codec Codec) *KV {
+	return &KV{Store: store, codec: codec}
+}
+
+func (self *KV) Codec() Codec { return self.codec }
+
+func key(collection, id string) string { return collection + "/" + id }
+
+func (self *KV) Read(collection, id string) ([]byte, error) {
+	data, err := self.Store.Get(key(collection, id))
+	if errors.Is(err, kv.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (self *KV) Write(collection, id string, data []byte) error {
+	return self.Store.Put(key(collection, id), data)
+}
+
+func (self *KV) Delete(collection, id string) error {
+	err := self.Store.Delete(key(collection, id))
+	if errors.Is(err, kv.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (self *KV) List(collection string) ([]string, error) {
+	keys, err := self.Store.Keys(key(collection, ""))
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, strings.TrimPrefix(key, collection+"/"))
+	}
+	return ids, nil
+}
+
+func (self *KV) Lock(collection string) (func(), error) {
+	return self.Store.Lock(collection)
+}
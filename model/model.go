@@ -1,5 +1,135 @@
 package model
 
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"../validate"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Models embed Model, which carries what the repository maintains: the id,
+// the revision and the timestamps. Everything else is the application's.
+//
+//   type Note struct {
+//     model.Model `yaml:",inline"`
+//     Title string `json:"title" yaml:"title" validate:"required,max=200"`
+//   }
+//
+//   notes := model.NewRepository[Note](app.Store, "notes")
+//
+// Models are checked with the validate package before they are written, an
+// invalid model fails with a usage error carrying validate.Errors.
+//
+////////////////////////////////////////////////////////////////////////////////
+
 type Model struct {
-	Title string
+	ID       string `json:"id" yaml:"id" validate:"id"`
+	Revision uint64 `json:"revision" yaml:"revision"`
+	// NOTE: Schema is the version of the model the document was written
+	// with, see Repository.Upgrade.
+	Schema  int       `json:"schema" yaml:"schema"`
+	Created time.Time `json:"created" yaml:"created"`
+	Updated time.Time `json:"updated" yaml:"updated"`
+}
+
+// Record is implemented by every model through the embedded Model.
+type Record interface {
+	Meta() *Model
+}
+
+func (self *Model) Meta() *Model { return self }
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already exists")
+	ErrConflict = errors.New("revision conflict")
+)
+
+// IDs //////////////////////////////////////////////////////////////////////
+// NOTE: IDs are 26 characters of Crockford base32: a millisecond timestamp
+// then random bits, so they sort by creation time and are safe as file names.
+const alphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+var (
+	idMu   sync.Mutex
+	lastID [16]byte
+)
+
+func NewID() string {
+	idMu.Lock()
+	defer idMu.Unlock()
+	var id [16]byte
+	milliseconds := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(id[0:2], uint16(milliseconds>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(milliseconds))
+	if milliseconds == timestamp(lastID) {
+		// NOTE: Within the same millisecond the random part is incremented,
+		// keeping ids created by one process in order.
+		id = lastID
+		for index := 15; 6 <= index; index-- {
+			id[index]++
+			if id[index] != 0 {
+				break
+			}
+		}
+	} else if _, err := rand.Read(id[6:]); err != nil {
+		panic(err)
+	}
+	lastID = id
+	return encode(id)
+}
+
+func timestamp(id [16]byte) uint64 {
+	return uint64(binary.BigEndian.Uint16(id[0:2]))<<32 | uint64(binary.BigEndian.Uint32(id[2:6]))
+}
+
+func encode(id [16]byte) string {
+	var text strings.Builder
+	// NOTE: 128 bits in 26 characters of 5 bits, the first holds 3 bits.
+	high, low := binary.BigEndian.Uint64(id[0:8]), binary.BigEndian.Uint64(id[8:16])
+	for shift := uint(125); ; shift -= 5 {
+		value := high >> (shift - 64)
+		if shift < 64 {
+			value = low>>shift | high<<(64-shift)
+		}
+		text.WriteByte(alphabet[value&31])
+		if shift == 0 {
+			break
+		}
+	}
+	return text.String()
+}
+
+func init() {
+	validate.Register("id", func(parent, value reflect.Value, param string) error {
+		// NOTE: A model is created without an id to get a new one.
+		if value.String() != "" && !ValidID(value.String()) {
+			return fmt.Errorf("must be at most 128 letters, digits, '-', '_' or '.', not starting with '.'")
+		}
+		return nil
+	})
+}
+
+// ValidID reports whether an id is safe to use as a file name.
+func ValidID(id string) bool {
+	if id == "" || len(id) > 128 || id[0] == '.' {
+		return false
+	}
+	for _, character := range id {
+		switch {
+		case 'a' <= character && character <= 'z', 'A' <= character && character <= 'Z',
+			'0' <= character && character <= '9', character == '-', character == '_', character == '.':
+		default:
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"../kv"
+)
+
+type note struct {
+	Model `yaml:",inline"`
+	Title string   `json:"title" yaml:"title" validate:"required,max=20"`
+	Tags  []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// backends returns a fresh backend of every kind, by name.
+func backends(t *testing.T) map[string]Backend {
+	t.Helper()
+	store, err := kv.Open(filepath.Join(t.TempDir(), "kv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return map[string]Backend{
+		"json files": NewFiles(t.TempDir(), JSON{}),
+		"yaml files": NewFiles(t.TempDir(), YAML{}),
+		"kv":         NewKV(store, JSON{}),
+	}
+}
+
+func TestNewID(t *testing.T) {
+	ids := make([]string, 1000)
+	for index := range ids {
+		ids[index] = NewID()
+		if len(ids[index]) != 26 || !ValidID(ids[index]) {
+			t.Fatalf("invalid id %q", ids[index])
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ids created in order do not sort in order")
+	}
+}
+
+func TestValidID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"01hx5k3", true},
+		{"note-1_a.b", true},
+		{"", false},
+		{".hidden", false},
+		{"../escape", false},
+		{"a/b", false},
+		{"spaced id", false},
+		{string(make([]byte, 129)), false},
+	}
+	for _, test := range tests {
+		if ValidID(test.id) != test.valid {
+			t.Errorf("%q valid %v, expected %v", test.id, !test.valid, test.valid)
+		}
+	}
+}
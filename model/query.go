@@ -0,0 +1,546 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"../fault"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// A Query selects models by their fields, sorts and pages them. It is data,
+// so the cli takes it as flags and the HTTP binding as a query string, in the
+// same syntax:
+//
+//   notes.Find(model.Query{}.Filter("title~=draft", "revision>1").OrderBy("-updated").Page(20, ""))
+//
+//   app-cli models list notes --where 'title~=draft,revision>1' --sort -updated --limit 20
+//   GET /api/query?collection=notes&where=title~=draft&sort=-updated&limit=20
+//
+// Predicates are `<field><operator><value>`, on the json names of the fields,
+// dotted for nested ones: = and != compare, < <= > >= order numbers, times
+// and text, ~= contains, ignoring case, and ^= starts with. Sorting is by the
+// fields given, `-` for descending, then by id, which is creation order.
+//
+// A page with more after it has Next, the cursor to pass as After for the
+// next one; unlike an offset, it does not skip or repeat models written in
+// between.
+//
+// Fields tagged `index:"true"` are indexed, so = and ^= on text, and any
+// comparison on numbers, only read the models that match:
+//
+//   type Note struct {
+//     model.Model
+//     Title string `json:"title" index:"true"`
+//   }
+////////////////////////////////////////////////////////////////////////////////
+
+type Operator string
+
+const (
+	Equal          Operator = "="
+	NotEqual       Operator = "!="
+	Less           Operator = "<"
+	LessOrEqual    Operator = "<="
+	Greater        Operator = ">"
+	GreaterOrEqual Operator = ">="
+	Contains       Operator = "~="
+	Prefix         Operator = "^="
+)
+
+// NOTE: Two character operators are matched before their one character
+// prefixes.
+var operators = []Operator{NotEqual, LessOrEqual, GreaterOrEqual, Contains, Prefix, Equal, Less, Greater}
+
+var fieldPattern = regexp.MustCompile(`^[A-Za-z0-9_]+(\.[A-Za-z0-9_]+)*$`)
+
+type Predicate struct {
+	Field    string
+	Operator Operator
+	Value    string
+}
+
+// ParsePredicate parses `<field><operator><value>`, such as `title~=draft`.
+func ParsePredicate(expression string) (Predicate, error) {
+	for index := range expression {
+		for _, operator := range operators {
+			if !strings.HasPrefix(expression[index:], string(operator)) {
+				continue
+			}
+			predicate := Predicate{
+				Field:    strings.TrimSpace(expression[:index]),
+				Operator: operator,
+				Value:    strings.TrimSpace(expression[index+len(operator):]),
+			}
+			if !fieldPattern.MatchString(predicate.Field) {
+				return predicate, queryError("invalid field %q in %q", predicate.Field, expression)
+			}
+			return predicate, nil
+		}
+	}
+	return Predicate{}, queryError("%q has no operator", expression)
+}
+
+func (self Predicate) String() string {
+	return self.Field + string(self.Operator) + self.Value
+}
+
+// Match is whether a document satisfies the predicate; a missing field only
+// satisfies != and = with an empty value.
+func (self Predicate) Match(document Document) bool {
+	value, ok := lookup(document, self.Field)
+	if !ok || value == nil {
+		return self.Operator == NotEqual && self.Value != "" || self.Operator == Equal && self.Value == ""
+	}
+	switch self.Operator {
+	case Contains:
+		return strings.Contains(strings.ToLower(text(value)), strings.ToLower(self.Value))
+	case Prefix:
+		return strings.HasPrefix(text(value), self.Value)
+	}
+	order, comparable := compareText(value, self.Value)
+	switch self.Operator {
+	case Equal:
+		return comparable && order == 0
+	case NotEqual:
+		return !comparable || order != 0
+	case Less:
+		return comparable && order < 0
+	case LessOrEqual:
+		return comparable && order <= 0
+	case Greater:
+		return comparable && 0 < order
+	case GreaterOrEqual:
+		return comparable && 0 <= order
+	}
+	return false
+}
+
+// Query is a selection of models, see the NOTE above. The zero query is every
+// model, by id.
+type Query struct {
+	Where  []string `json:"where,omitempty" yaml:"where,omitempty" help:"predicates, comma separated, e.g. title~=draft,revision>1"`
+	Sort   []string `json:"sort,omitempty" yaml:"sort,omitempty" help:"fields to sort by, comma separated, -field for descending"`
+	Limit  int      `json:"limit,omitempty" yaml:"limit,omitempty" help:"the most models in a page, all of them with 0" validate:"min=0"`
+	Offset int      `json:"offset,omitempty" yaml:"offset,omitempty" help:"models to skip" validate:"min=0"`
+	After  string   `json:"after,omitempty" yaml:"after,omitempty" help:"the cursor of the page before"`
+}
+
+func (self Query) Filter(predicates ...string) Query {
+	self.Where = append(append([]string{}, self.Where...), predicates...)
+	return self
+}
+
+func (self Query) OrderBy(fields ...string) Query {
+	self.Sort = append(append([]string{}, self.Sort...), fields...)
+	return self
+}
+
+// Page limits the query to a page, the one after the cursor of the page
+// before or the first with an empty cursor.
+func (self Query) Page(limit int, after string) Query {
+	self.Limit, self.After = limit, after
+	return self
+}
+
+func (self Query) Skip(offset int) Query {
+	self.Offset = offset
+	return self
+}
+
+// Page is the documents a query selected, with the cursor of the page after
+// them, if there is one.
+type Page struct {
+	Documents []Document `json:"documents" yaml:"documents"`
+	Next      string     `json:"next,omitempty" yaml:"next,omitempty"`
+}
+
+func (self Page) String() string {
+	var output strings.Builder
+	table := tabwriter.NewWriter(&output, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tREVISION\tUPDATED")
+	for _, document := range self.Documents {
+		fmt.Fprintf(table, "%v\t%v\t%v\n", document["id"], document["revision"], document["updated"])
+	}
+	table.Flush()
+	if self.Next != "" {
+		fmt.Fprintf(&output, "next page: --after %s\n", self.Next)
+	}
+	return output.String()
+}
+
+type sortField struct {
+	field      string
+	descending bool
+}
+
+// plan is a parsed query.
+type plan struct {
+	predicates []Predicate
+	sorts      []sortField
+	after      []interface{}
+}
+
+func (self Query) plan() (plan, error) {
+	var parsed plan
+	for _, expression := range self.Where {
+		predicate, err := ParsePredicate(expression)
+		if err != nil {
+			return parsed, err
+		}
+		parsed.predicates = append(parsed.predicates, predicate)
+	}
+	for _, field := range self.Sort {
+		sorted := sortField{field: strings.TrimPrefix(field, "-"), descending: strings.HasPrefix(field, "-")}
+		if !fieldPattern.MatchString(sorted.field) {
+			return parsed, queryError("invalid sort field %q", field)
+		}
+		parsed.sorts = append(parsed.sorts, sorted)
+	}
+	if self.Limit < 0 || self.Offset < 0 {
+		return parsed, queryError("limit and offset can not be negative")
+	}
+	if self.After != "" {
+		data, err := base64.RawURLEncoding.DecodeString(self.After)
+		if err == nil {
+			err = json.Unmarshal(data, &parsed.after)
+		}
+		if err != nil || len(parsed.after) != len(parsed.sorts)+1 {
+			return parsed, queryError("invalid cursor %q", self.After).
+				WithHint("pass the next cursor of a page of the same query")
+		}
+	}
+	return parsed, nil
+}
+
+func (self plan) match(document Document) bool {
+	for _, predicate := range self.predicates {
+		if !predicate.Match(document) {
+			return false
+		}
+	}
+	return true
+}
+
+// key is what a document is sorted by: the sort fields, then its id.
+func (self plan) key(document Document) []interface{} {
+	key := make([]interface{}, 0, len(self.sorts)+1)
+	for _, sorted := range self.sorts {
+		value, _ := lookup(document, sorted.field)
+		key = append(key, normalize(value))
+	}
+	return append(key, normalize(document["id"]))
+}
+
+func (self plan) compare(left, right []interface{}) int {
+	for index, value := range left {
+		order := compareValues(value, right[index])
+		if index < len(self.sorts) && self.sorts[index].descending {
+			order = -order
+		}
+		if order != 0 {
+			return order
+		}
+	}
+	return 0
+}
+
+func cursor(key []interface{}) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Find runs a query on the documents of a collection, whatever their model.
+func Find(backend Backend, collection string, query Query) (Page, error) {
+	return find(backend, collection, query, stored(backend, collection))
+}
+
+// stored loads the documents of a collection as they are stored.
+func stored(backend Backend, collection string) func(id string) (Document, error) {
+	return func(id string) (Document, error) {
+		data, err := backend.Read(collection, id)
+		if err != nil {
+			return nil, fault.Wrap(err, fault.IO, "io.store", "failed to access %s %q", collection, id)
+		}
+		document := make(Document)
+		if err := backend.Codec().Unmarshal(data, &document); err != nil {
+			return nil, fault.Wrap(err, fault.IO, "io.corrupt", "%s %q is unreadable", collection, id)
+		}
+		return document, nil
+	}
+}
+
+// Find runs a query on the models of the repository, at their current schema
+// version; it builds the indexes the model declares first, if they are not.
+func (self *Repository[T]) Find(query Query) ([]*T, string, error) {
+	for _, field := range self.indexed {
+		if err := self.ensureIndex(field); err != nil {
+			return nil, "", err
+		}
+	}
+	items := make(map[string]*T)
+	page, err := find(self.backend, self.Collection, query, func(id string) (Document, error) {
+		item, err := self.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		items[id] = item
+		return document(self.codec, item)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	found := make([]*T, 0, len(page.Documents))
+	for _, document := range page.Documents {
+		found = append(found, items[text(document["id"])])
+	}
+	return found, page.Next, nil
+}
+
+func find(backend Backend, collection string, query Query, load func(id string) (Document, error)) (Page, error) {
+	plan, err := query.plan()
+	if err != nil {
+		return Page{}, err
+	}
+	ids, err := candidates(backend, collection, plan.predicates)
+	if err != nil {
+		return Page{}, fault.Wrap(err, fault.IO, "io.list", "failed to list %s", collection)
+	}
+	var documents []Document
+	// NOTE: By id alone, the documents are read in order until the page is
+	// full; sorted by a field, every candidate is read first.
+	wanted := -1
+	if len(plan.sorts) == 0 && query.Limit != 0 {
+		wanted = query.Offset + query.Limit + 1
+	}
+	for _, id := range ids {
+		if plan.after != nil && len(plan.sorts) == 0 && compareValues(id, plan.after[0]) <= 0 {
+			continue
+		}
+		document, err := load(id)
+		// NOTE: A document deleted since it was listed is not an error.
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return Page{}, err
+		}
+		if !plan.match(document) {
+			continue
+		}
+		documents = append(documents, document)
+		if len(documents) == wanted {
+			break
+		}
+	}
+	keys := make(map[string][]interface{}, len(documents))
+	for _, document := range documents {
+		keys[text(document["id"])] = plan.key(document)
+	}
+	key := func(document Document) []interface{} { return keys[text(document["id"])] }
+	sort.SliceStable(documents, func(i, j int) bool { return plan.compare(key(documents[i]), key(documents[j])) < 0 })
+	if plan.after != nil && len(plan.sorts) != 0 {
+		start := sort.Search(len(documents), func(index int) bool { return 0 < plan.compare(key(documents[index]), plan.after) })
+		documents = documents[start:]
+	}
+	if query.Offset < len(documents) {
+		documents = documents[query.Offset:]
+	} else {
+		documents = nil
+	}
+	page := Page{Documents: documents}
+	if query.Limit != 0 && query.Limit < len(documents) {
+		page.Documents = documents[:query.Limit]
+		page.Next = cursor(key(page.Documents[query.Limit-1]))
+	}
+	if page.Documents == nil {
+		page.Documents = []Document{}
+	}
+	return page, nil
+}
+
+// candidates are the ids a query has to read: those the indexes of its
+// predicates agree on, or every id.
+func candidates(backend Backend, collection string, predicates []Predicate) ([]string, error) {
+	indexed, err := Indexes(backend, collection)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	narrowed := false
+	for _, predicate := range predicates {
+		if !contains(indexed, predicate.Field) {
+			continue
+		}
+		matching, ok, err := lookupIndex(backend, collection, predicate)
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			continue
+		}
+		if narrowed {
+			ids = intersect(ids, matching)
+		} else {
+			ids, narrowed = matching, true
+		}
+	}
+	if !narrowed {
+		ids, err = backend.List(collection)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func intersect(left, right []string) []string {
+	set := make(map[string]bool, len(right))
+	for _, id := range right {
+		set[id] = true
+	}
+	var both []string
+	for _, id := range left {
+		if set[id] {
+			both = append(both, id)
+		}
+	}
+	return both
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Values //////////////////////////////////////////////////////////////////////
+func lookup(document Document, field string) (interface{}, bool) {
+	var value interface{} = map[string]interface{}(document)
+	for _, name := range strings.Split(field, ".") {
+		switch object := value.(type) {
+		case map[string]interface{}:
+			value = object[name]
+		case Document:
+			value = object[name]
+		case map[interface{}]interface{}:
+			value = object[name]
+		default:
+			return nil, false
+		}
+		if value == nil {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// normalize makes the values of every codec compare alike: numbers are
+// float64, times RFC 3339 text.
+func normalize(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case int:
+		return float64(typed)
+	case int64:
+		return float64(typed)
+	case uint64:
+		return float64(typed)
+	case float32:
+		return float64(typed)
+	case time.Time:
+		return typed.Format(time.RFC3339Nano)
+	}
+	return value
+}
+
+func text(value interface{}) string {
+	switch typed := normalize(value).(type) {
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// compareText compares a value with the text of a predicate, as the type of
+// the value; not comparable when the text is not of that type.
+func compareText(value interface{}, given string) (int, bool) {
+	switch typed := normalize(value).(type) {
+	case float64:
+		number, err := strconv.ParseFloat(given, 64)
+		if err != nil {
+			return 0, false
+		}
+		return compareValues(typed, number), true
+	case bool:
+		flag, err := strconv.ParseBool(given)
+		if err != nil {
+			return 0, false
+		}
+		return compareValues(typed, flag), true
+	case string:
+		return compareValues(typed, given), true
+	}
+	return 0, false
+}
+
+// compareValues orders normalized values: missing first, then by type; text
+// that is two times compares as times.
+func compareValues(left, right interface{}) int {
+	left, right = normalize(left), normalize(right)
+	switch {
+	case left == nil && right == nil:
+		return 0
+	case left == nil:
+		return -1
+	case right == nil:
+		return 1
+	}
+	switch typed := left.(type) {
+	case float64:
+		if other, ok := right.(float64); ok {
+			return compareOrdered(typed, other)
+		}
+	case bool:
+		if other, ok := right.(bool); ok {
+			return compareOrdered(strconv.FormatBool(typed), strconv.FormatBool(other))
+		}
+	case string:
+		if other, ok := right.(string); ok {
+			leftTime, leftErr := time.Parse(time.RFC3339Nano, typed)
+			rightTime, rightErr := time.Parse(time.RFC3339Nano, other)
+			if leftErr == nil && rightErr == nil {
+				return leftTime.Compare(rightTime)
+			}
+			return strings.Compare(typed, other)
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%T", left), fmt.Sprintf("%T", right))
+}
+
+func compareOrdered[V float64 | string](left, right V) int {
+	switch {
+	case left < right:
+		return -1
+	case right < left:
+		return 1
+	}
+	return 0
+}
+
+func queryError(format string, args ...interface{}) *fault.Error {
+	return fault.UsageError("usage.invalid_query", format, args...).
+		WithHint("predicates are <field><operator><value>, with = != < <= > >= ~= or ^=, e.g. title~=draft")
+}
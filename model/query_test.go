@@ -0,0 +1,166 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"../fault"
+)
+
+type task struct {
+	Model `yaml:",inline"`
+	Title string `json:"title" yaml:"title" index:"true"`
+	Rank  int    `json:"rank" yaml:"rank" index:"true"`
+	Done  bool   `json:"done" yaml:"done"`
+}
+
+func TestParsePredicate(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   Predicate
+		valid      bool
+	}{
+		{"title=a", Predicate{"title", Equal, "a"}, true},
+		{"rank <= 3", Predicate{"rank", LessOrEqual, "3"}, true},
+		{"title~=a=b", Predicate{"title", Contains, "a=b"}, true},
+		{"owner.name^=al", Predicate{"owner.name", Prefix, "al"}, true},
+		{"title!=", Predicate{"title", NotEqual, ""}, true},
+		{"title", Predicate{}, false},
+		{"=a", Predicate{}, false},
+		{"ti tle=a", Predicate{}, false},
+	}
+	for _, test := range tests {
+		predicate, err := ParsePredicate(test.expression)
+		if !test.valid {
+			if fault.As(err).Code != "usage.invalid_query" {
+				t.Errorf("%q: parsed as %v, %v", test.expression, predicate, err)
+			}
+			continue
+		}
+		if err != nil || predicate != test.expected {
+			t.Errorf("%q: parsed as %#v, %v, expected %#v", test.expression, predicate, err, test.expected)
+		}
+	}
+}
+
+// titles are the titles of tasks, in order.
+func titles(tasks []*task) []string {
+	found := make([]string, 0, len(tasks))
+	for _, item := range tasks {
+		found = append(found, item.Title)
+	}
+	return found
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  Query
+		titles []string
+	}{
+		{"all", Query{}, []string{"alpha", "beta", "gamma", "delta", "alphabet"}},
+		{"equal", Query{}.Filter("title=beta"), []string{"beta"}},
+		{"prefix", Query{}.Filter("title^=alpha"), []string{"alpha", "alphabet"}},
+		{"contains", Query{}.Filter("title~=ALP"), []string{"alpha", "alphabet"}},
+		{"not equal", Query{}.Filter("title!=beta"), []string{"alpha", "gamma", "delta", "alphabet"}},
+		{"numbers", Query{}.Filter("rank>2"), []string{"gamma", "delta"}},
+		{"both", Query{}.Filter("rank>=2", "done=true"), []string{"delta"}},
+		{"missing", Query{}.Filter("title=omega"), []string{}},
+		{"sorted", Query{}.OrderBy("-rank", "title"), []string{"gamma", "delta", "beta", "alpha", "alphabet"}},
+		{"limited", Query{}.OrderBy("title").Page(2, ""), []string{"alpha", "alphabet"}},
+		{"skipped", Query{}.OrderBy("title").Skip(3), []string{"delta", "gamma"}},
+	}
+	for name, backend := range backends(t) {
+		tasks := NewRepository[task](backend, "tasks")
+		for _, item := range []task{{Title: "alpha", Rank: 1}, {Title: "beta", Rank: 2}, {Title: "gamma", Rank: 5},
+			{Title: "delta", Rank: 3, Done: true}, {Title: "alphabet", Rank: 1}} {
+			if err := tasks.Create(&item); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, test := range tests {
+			// NOTE: Without indexes first, then with the ones the model
+			// declares, which the first repository query builds.
+			page, err := Find(backend, "tasks", test.query)
+			if err != nil {
+				t.Fatalf("%s, %s: %v", name, test.name, err)
+			}
+			var unindexed []string
+			for _, document := range page.Documents {
+				unindexed = append(unindexed, document["title"].(string))
+			}
+			found, _, err := tasks.Find(test.query)
+			if err != nil {
+				t.Fatalf("%s, %s: %v", name, test.name, err)
+			}
+			if !reflect.DeepEqual(titles(found), test.titles) || len(unindexed) != len(test.titles) {
+				t.Errorf("%s, %s: found %v, %v without indexes, expected %v", name, test.name, titles(found), unindexed, test.titles)
+			}
+		}
+		if indexed, _ := Indexes(backend, "tasks"); !reflect.DeepEqual(indexed, []string{"rank", "title"}) {
+			t.Errorf("%s: indexed %v", name, indexed)
+		}
+		if _, _, err := tasks.Find(Query{}.Filter("title")); fault.As(err).Code != "usage.invalid_query" {
+			t.Errorf("%s: an invalid query returned %v", name, err)
+		}
+		if _, _, err := tasks.Find(Query{After: "nonsense"}); fault.As(err).Code != "usage.invalid_query" {
+			t.Errorf("%s: an invalid cursor returned %v", name, err)
+		}
+	}
+}
+
+func TestPages(t *testing.T) {
+	for _, sorted := range [][]string{nil, {"-rank"}} {
+		backend := backends(t)["kv"]
+		tasks := NewRepository[task](backend, "tasks")
+		for rank := 1; rank <= 5; rank++ {
+			tasks.Create(&task{Title: string(rune('a' + rank - 1)), Rank: rank})
+		}
+		var read []string
+		after := ""
+		for page := 0; page < 5; page++ {
+			found, next, err := tasks.Find(Query{Sort: sorted}.Page(2, after))
+			if err != nil {
+				t.Fatal(err)
+			}
+			read = append(read, titles(found)...)
+			if page == 0 {
+				// NOTE: A model created between pages is not read twice,
+				// nor does it shift the pages after it.
+				tasks.Create(&task{Title: "late", Rank: 0})
+			}
+			if after = next; after == "" {
+				break
+			}
+		}
+		expected := []string{"a", "b", "c", "d", "e", "late"}
+		if sorted != nil {
+			expected = []string{"e", "d", "c", "b", "a", "late"}
+		}
+		if !reflect.DeepEqual(read, expected) {
+			t.Errorf("sorted by %v: read %v, expected %v", sorted, read, expected)
+		}
+	}
+}
+
+func TestFindUpgraded(t *testing.T) {
+	for name, backend := range backends(t) {
+		store(t, backend, "tasks", Document{"id": "old", "revision": 1, "name": "alpha", "rank": 1})
+		store(t, backend, "tasks", Document{"id": "new", "revision": 1, "schema": 2, "title": "beta", "rank": 2})
+		tasks := NewRepository[task](backend, "tasks").Upgrade(1, renamed)
+		found, _, err := tasks.Find(Query{}.Filter("title=alpha"))
+		if err != nil || !reflect.DeepEqual(titles(found), []string{"alpha"}) {
+			t.Errorf("%s: an upgraded model was found as %v, %v", name, titles(found), err)
+		}
+		found, _, err = tasks.Find(Query{}.Filter("title^=a"))
+		if err != nil || !reflect.DeepEqual(titles(found), []string{"alpha"}) {
+			t.Errorf("%s: found %v, %v by prefix", name, titles(found), err)
+		}
+		if _, err := tasks.Migrate(false); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if found, _, err = tasks.Find(Query{}.Filter("title=alpha")); err != nil || len(found) != 1 {
+			t.Errorf("%s: a migrated model was found as %v, %v", name, titles(found), err)
+		}
+	}
+}
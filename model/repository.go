@@ -0,0 +1,244 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"../fault"
+	"../validate"
+)
+
+// Repository stores models of one type in a collection. Updates and deletes
+// carry the revision the caller read: when the stored revision has moved on,
+// someone else wrote in between and the write fails with ErrConflict instead
+// of losing their change.
+type Repository[T any] struct {
+	Collection string
+
+	ctx      context.Context
+	backend  Backend
+	codec    Codec
+	upgrades []Upgrade
+	indexed  []string
+}
+
+// NewRepository returns the repository of a collection. T must embed Model.
+func NewRepository[T any](backend Backend, collection string) *Repository[T] {
+	if _, ok := interface{}(new(T)).(Record); !ok {
+		panic(fmt.Sprintf("model: %s does not embed model.Model", reflect.TypeOf((*T)(nil)).Elem()))
+	}
+	return &Repository[T]{Collection: collection, ctx: context.Background(), backend: backend, codec: backend.Codec(),
+		indexed: indexedFields(reflect.TypeOf((*T)(nil)).Elem())}
+}
+
+// In returns the repository bound to a context: the changes it writes are
+// reported to the observer of the context, see Observing.
+func (self *Repository[T]) In(ctx context.Context) *Repository[T] {
+	bound := *self
+	bound.ctx = ctx
+	return &bound
+}
+
+func meta[T any](item *T) *Model { return interface{}(item).(Record).Meta() }
+
+// Create stores a new model, with a new id unless it has one.
+func (self *Repository[T]) Create(item *T) error {
+	model := meta(item)
+	if err := self.validate(item); err != nil {
+		return err
+	}
+	if model.ID == "" {
+		model.ID = NewID()
+	}
+	unlock, err := self.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := self.backend.Read(self.Collection, model.ID); err == nil {
+		return fault.Wrap(ErrExists, fault.Usage, "usage.exists", "%s %q", self.Collection, model.ID)
+	} else if !errors.Is(err, ErrNotFound) {
+		return self.ioError(err, model.ID)
+	}
+	now := time.Now().UTC()
+	previous := *model
+	model.Revision, model.Created, model.Updated = 1, now, now
+	model.Schema = self.Version()
+	if err := self.write(item); err != nil {
+		*model = previous
+		return err
+	}
+	return self.record(Created, nil, item)
+}
+
+func (self *Repository[T]) Get(id string) (*T, error) {
+	if !ValidID(id) {
+		return nil, fault.Wrap(ErrNotFound, fault.IO, "io.not_found", "%s %q", self.Collection, id)
+	}
+	data, err := self.backend.Read(self.Collection, id)
+	if err != nil {
+		return nil, self.ioError(err, id)
+	}
+	item, _, err := self.decode(id, data)
+	return item, err
+}
+
+// Update stores a changed model, it must carry the revision it was read at.
+func (self *Repository[T]) Update(item *T) error {
+	model := meta(item)
+	if err := self.validate(item); err != nil {
+		return err
+	}
+	unlock, err := self.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, err := self.Get(model.ID)
+	if err != nil {
+		return err
+	}
+	if err := self.check(meta(current), model.Revision); err != nil {
+		return err
+	}
+	previous := *model
+	model.Revision, model.Created, model.Updated = model.Revision+1, meta(current).Created, time.Now().UTC()
+	model.Schema = self.Version()
+	if err := self.write(item); err != nil {
+		*model = previous
+		return err
+	}
+	return self.record(Updated, current, item)
+}
+
+// Delete removes a model, if it is still at the revision; a zero revision
+// deletes whatever is stored.
+func (self *Repository[T]) Delete(id string, revision uint64) error {
+	unlock, err := self.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, err := self.Get(id)
+	if err != nil {
+		return err
+	}
+	if revision != 0 {
+		if err := self.check(meta(current), revision); err != nil {
+			return err
+		}
+	}
+	if err := self.backend.Delete(self.Collection, id); err != nil {
+		return self.ioError(err, id)
+	}
+	return self.record(Deleted, current, nil)
+}
+
+// Watch calls handler with the changes of the collection after cursor, see
+// model.Watch.
+func (self *Repository[T]) Watch(ctx context.Context, cursor uint64, handler func(Change) error) error {
+	return Watch(ctx, self.backend, self.Collection, cursor, handler)
+}
+
+// Decode returns the model a change carries, such as its New document.
+func (self *Repository[T]) Decode(document Document) (*T, error) {
+	if document == nil {
+		return nil, nil
+	}
+	data, err := self.codec.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	item := new(T)
+	err = self.codec.Unmarshal(data, item)
+	return item, err
+}
+
+// List returns every model of the collection, oldest first.
+func (self *Repository[T]) List() ([]*T, error) {
+	ids, err := self.backend.List(self.Collection)
+	if err != nil {
+		return nil, fault.Wrap(err, fault.IO, "io.list", "failed to list %s", self.Collection)
+	}
+	items := make([]*T, 0, len(ids))
+	for _, id := range ids {
+		item, err := self.Get(id)
+		// NOTE: A document deleted since it was listed is not an error.
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (self *Repository[T]) check(current *Model, revision uint64) error {
+	if current.Revision == revision {
+		return nil
+	}
+	return fault.Wrap(ErrConflict, fault.Unavailable, "unavailable.conflict",
+		"%s %q is at revision %d, not %d", self.Collection, current.ID, current.Revision, revision).
+		WithHint("it was changed since it was read, read it again and retry")
+}
+
+// record appends the change of a write; the model is written already, a
+// failure here only loses the change.
+func (self *Repository[T]) record(kind ChangeType, old, new *T) error {
+	change := Change{Type: kind}
+	var err error
+	if old != nil {
+		change.ID, change.Revision = meta(old).ID, meta(old).Revision
+		change.Old, err = document(self.codec, old)
+	}
+	if new != nil && err == nil {
+		change.ID, change.Revision = meta(new).ID, meta(new).Revision
+		change.New, err = document(self.codec, new)
+	}
+	if err == nil {
+		err = record(self.backend, self.Collection, &change)
+	}
+	if err != nil {
+		return fault.Wrap(err, fault.IO, "io.changes", "%s %q was written but its change was not recorded", self.Collection, change.ID)
+	}
+	observe(self.ctx, change)
+	return nil
+}
+
+func (self *Repository[T]) validate(item *T) error {
+	if err := validate.Struct(item); err != nil {
+		return fault.Wrap(err, fault.Usage, "usage.invalid", "invalid %s", self.Collection).
+			WithHint("fix the fields listed and retry")
+	}
+	return nil
+}
+
+func (self *Repository[T]) lock() (func(), error) {
+	unlock, err := self.backend.Lock(self.Collection)
+	if err != nil {
+		return nil, fault.Wrap(err, fault.IO, "io.lock", "failed to lock %s", self.Collection)
+	}
+	return unlock, nil
+}
+
+func (self *Repository[T]) write(item *T) error {
+	data, err := self.codec.Marshal(item)
+	if err != nil {
+		return fault.Wrap(err, fault.Internal, "internal.encode", "failed to encode %s %q", self.Collection, meta(item).ID)
+	}
+	if err := self.backend.Write(self.Collection, meta(item).ID, data); err != nil {
+		return self.ioError(err, meta(item).ID)
+	}
+	return nil
+}
+
+func (self *Repository[T]) ioError(err error, id string) error {
+	if errors.Is(err, ErrNotFound) {
+		return fault.Wrap(ErrNotFound, fault.IO, "io.not_found", "%s %q", self.Collection, id)
+	}
+	return fault.Wrap(err, fault.IO, "io.store", "failed to access %s %q", self.Collection, id)
+}
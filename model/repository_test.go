@@ -0,0 +1,97 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"../fault"
+	"../validate"
+)
+
+func TestRevisions(t *testing.T) {
+	for name, backend := range backends(t) {
+		notes := NewRepository[note](backend, "notes")
+		created := &note{Title: "first"}
+		if err := notes.Create(created); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if created.ID == "" || created.Revision != 1 || created.Created.IsZero() || !created.Created.Equal(created.Updated) {
+			t.Errorf("%s: created as %+v", name, created.Model)
+		}
+		read, err := notes.Get(created.ID)
+		if err != nil || read.Title != "first" || read.Revision != 1 {
+			t.Fatalf("%s: read %+v, %v", name, read, err)
+		}
+
+		stale := *read
+		read.Title = "second"
+		if err := notes.Update(read); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if read.Revision != 2 || !read.Created.Equal(created.Created) || read.Updated.Before(read.Created) {
+			t.Errorf("%s: updated as %+v", name, read.Model)
+		}
+		stale.Title = "lost"
+		err = notes.Update(&stale)
+		if !errors.Is(err, ErrConflict) || fault.As(err).HTTPStatus() != 409 || stale.Revision != 1 {
+			t.Errorf("%s: a stale update returned %v at revision %d", name, err, stale.Revision)
+		}
+		if err := notes.Delete(created.ID, 1); !errors.Is(err, ErrConflict) {
+			t.Errorf("%s: a stale delete returned %v", name, err)
+		}
+		if err := notes.Delete(created.ID, 2); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if _, err := notes.Get(created.ID); !errors.Is(err, ErrNotFound) || fault.As(err).Code != "io.not_found" {
+			t.Errorf("%s: a deleted note read as %v", name, err)
+		}
+		if err := notes.Delete(created.ID, 0); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: deleting twice returned %v", name, err)
+		}
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	for name, backend := range backends(t) {
+		notes := NewRepository[note](backend, "notes")
+		notes.Create(&note{Model: Model{ID: "taken"}, Title: "a"})
+		tests := []struct {
+			note  note
+			code  string
+			field string
+		}{
+			{note{Model: Model{ID: "taken"}, Title: "b"}, "usage.exists", ""},
+			{note{}, "usage.invalid", "title"},
+			{note{Title: "a title far too long for the limit"}, "usage.invalid", "title"},
+			{note{Model: Model{ID: "../escape"}, Title: "a"}, "usage.invalid", "id"},
+		}
+		for _, test := range tests {
+			err := notes.Create(&test.note)
+			if fault.As(err).Code != test.code {
+				t.Errorf("%s: creating %+v returned %v, expected %s", name, test.note, err, test.code)
+			}
+			if fields := validate.Fields(err); test.field != "" && (len(fields) != 1 || fields[0].Field != test.field) {
+				t.Errorf("%s: creating %+v failed on %v, expected %s", name, test.note, fields, test.field)
+			}
+		}
+		if _, err := notes.Get("../escape"); fault.As(err).Code != "io.not_found" {
+			t.Errorf("%s: reading an invalid id returned %v", name, err)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	for name, backend := range backends(t) {
+		notes := NewRepository[note](backend, "notes")
+		for _, title := range []string{"a", "b", "c"} {
+			if err := notes.Create(&note{Title: title}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		NewRepository[note](backend, "other").Create(&note{Title: "elsewhere"})
+		listed, err := notes.List()
+		if err != nil || len(listed) != 3 || listed[0].Title != "a" || listed[2].Title != "c" {
+			t.Errorf("%s: listed %v, %v", name, listed, err)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"../fault"
+)
+
+// Observers ////////////////////////////////////////////////////////////////
+type observerKey struct{}
+
+// Observing returns a context whose repositories report every change they
+// write, such as an undo journal collecting the changes of an action. An
+// observer already in the context is told as well.
+func Observing(ctx context.Context, observer func(Change)) context.Context {
+	if previous, ok := ctx.Value(observerKey{}).(func(Change)); ok {
+		next := observer
+		observer = func(change Change) {
+			previous(change)
+			next(change)
+		}
+	}
+	return context.WithValue(ctx, observerKey{}, observer)
+}
+
+func observe(ctx context.Context, change Change) {
+	if observer, ok := ctx.Value(observerKey{}).(func(Change)); ok {
+		observer(change)
+	}
+}
+
+// Restore writes a document back, or deletes it when restored is nil, if the
+// stored one is still at the revision, zero for none. The document is written
+// at the next revision and recorded as a change like any other write, the new
+// revision is returned. It is how a model is put back without knowing its
+// type, as an undo does.
+func Restore(ctx context.Context, backend Backend, collection, id string, restored Document, revision uint64) (uint64, error) {
+	unlock, err := backend.Lock(collection)
+	if err != nil {
+		return 0, fault.Wrap(err, fault.IO, "io.lock", "failed to lock %s", collection)
+	}
+	defer unlock()
+	codec := backend.Codec()
+	change := Change{ID: id}
+	current := new(Model)
+	data, err := backend.Read(collection, id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		current = nil
+	case err != nil:
+		return 0, fault.Wrap(err, fault.IO, "io.store", "failed to access %s %q", collection, id)
+	default:
+		if err := codec.Unmarshal(data, current); err != nil {
+			return 0, fault.Wrap(err, fault.IO, "io.decode", "failed to decode %s %q", collection, id)
+		}
+		change.Old = make(Document)
+		if err := codec.Unmarshal(data, &change.Old); err != nil {
+			return 0, fault.Wrap(err, fault.IO, "io.decode", "failed to decode %s %q", collection, id)
+		}
+	}
+	if at := revisionOf(current); at != revision {
+		return 0, fault.Wrap(ErrConflict, fault.Unavailable, "unavailable.conflict",
+			"%s %q is at revision %d, not %d", collection, id, at, revision).
+			WithHint("it was changed since, read it again and retry")
+	}
+	switch {
+	case restored == nil && current == nil:
+		return 0, nil
+	case restored == nil:
+		if err := backend.Delete(collection, id); err != nil {
+			return 0, fault.Wrap(err, fault.IO, "io.store", "failed to access %s %q", collection, id)
+		}
+		change.Type, change.Revision = Deleted, current.Revision
+	default:
+		change.New = make(Document, len(restored))
+		for key, value := range restored {
+			change.New[key] = value
+		}
+		change.New["revision"], change.New["updated"] = revision+1, time.Now().UTC()
+		change.Type, change.Revision = Updated, revision+1
+		if current == nil {
+			change.Type = Created
+		}
+		data, err := codec.Marshal(change.New)
+		if err != nil {
+			return 0, fault.Wrap(err, fault.Internal, "internal.encode", "failed to encode %s %q", collection, id)
+		}
+		if err := backend.Write(collection, id, data); err != nil {
+			return 0, fault.Wrap(err, fault.IO, "io.store", "failed to access %s %q", collection, id)
+		}
+	}
+	if err := record(backend, collection, &change); err != nil {
+		return 0, fault.Wrap(err, fault.IO, "io.changes", "%s %q was written but its change was not recorded", collection, id)
+	}
+	observe(ctx, change)
+	if change.New == nil {
+		return 0, nil
+	}
+	return change.Revision, nil
+}
+
+func revisionOf(model *Model) uint64 {
+	if model == nil {
+		return 0
+	}
+	return model.Revision
+}
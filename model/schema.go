@@ -0,0 +1,283 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"../fault"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Every stored document records the schema version of its model. A change
+// to a model that breaks its stored documents bumps the version by
+// registering the upgrade from the previous one, on the raw document:
+//
+//   notes := model.NewRepository[Note](app.Store, "notes")
+//   // version 2: name was renamed to title.
+//   notes.Upgrade(1, func(document model.Document) error {
+//     document["title"] = document["name"]
+//     delete(document, "name")
+//     return nil
+//   })
+//   app.Models.Add(notes)
+//
+// Documents are upgraded lazily, as they are read, and written at the new
+// version by the next update; `app-cli migrate` upgrades them all at once,
+// with `--dry-run` reporting what it would touch.
+////////////////////////////////////////////////////////////////////////////////
+
+// Document is a stored model as decoded by the codec, before it is a T.
+type Document map[string]interface{}
+
+// Documents reads every document of a collection, whatever its model.
+func Documents(backend Backend, collection string) ([]Document, error) {
+	ids, err := backend.List(collection)
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]Document, 0, len(ids))
+	for _, id := range ids {
+		data, err := backend.Read(collection, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		document := make(Document)
+		if err := backend.Codec().Unmarshal(data, &document); err != nil {
+			return nil, fault.Wrap(err, fault.IO, "io.corrupt", "%s %q is unreadable", collection, id)
+		}
+		documents = append(documents, document)
+	}
+	return documents, nil
+}
+
+// Upgrade changes a document from one schema version to the next.
+type Upgrade func(Document) error
+
+// Documents stored before they recorded a version are version 1.
+const initialSchema = 1
+
+// Upgrade registers the upgrade from version `from` to `from+1`, which
+// becomes the current version. Upgrades are registered in order, from 1.
+func (self *Repository[T]) Upgrade(from int, upgrade Upgrade) *Repository[T] {
+	if from != self.Version() {
+		panic(fmt.Sprintf("model: %s upgrade from %d, current version is %d", self.Collection, from, self.Version()))
+	}
+	self.upgrades = append(self.upgrades, upgrade)
+	return self
+}
+
+// Version is the current schema version of the collection.
+func (self *Repository[T]) Version() int { return initialSchema + len(self.upgrades) }
+
+func (self *Repository[T]) Name() string { return self.Collection }
+
+func schemaOf(model *Model) int {
+	if model.Schema == 0 {
+		return initialSchema
+	}
+	return model.Schema
+}
+
+// decode reads a document, upgrading it when it is at an older version.
+func (self *Repository[T]) decode(id string, data []byte) (*T, int, error) {
+	item := new(T)
+	if err := self.codec.Unmarshal(data, item); err != nil {
+		return nil, 0, fault.Wrap(err, fault.IO, "io.corrupt", "%s %q is unreadable", self.Collection, id)
+	}
+	stored := schemaOf(meta(item))
+	switch {
+	case stored == self.Version():
+		return item, stored, nil
+	case self.Version() < stored:
+		return nil, stored, fault.IOError("io.schema", "%s %q is at schema version %d, newer than %d", self.Collection, id, stored, self.Version()).
+			WithHint("it was written by a newer version of the application")
+	}
+	document := make(Document)
+	if err := self.codec.Unmarshal(data, &document); err != nil {
+		return nil, stored, fault.Wrap(err, fault.IO, "io.corrupt", "%s %q is unreadable", self.Collection, id)
+	}
+	for version := stored; version < self.Version(); version++ {
+		if err := self.upgrades[version-initialSchema](document); err != nil {
+			return nil, stored, fault.Wrap(err, fault.Internal, "internal.upgrade",
+				"failed to upgrade %s %q from schema version %d", self.Collection, id, version)
+		}
+	}
+	upgraded, err := self.codec.Marshal(document)
+	if err != nil {
+		return nil, stored, fault.Wrap(err, fault.Internal, "internal.upgrade", "failed to encode upgraded %s %q", self.Collection, id)
+	}
+	item = new(T)
+	if err := self.codec.Unmarshal(upgraded, item); err != nil {
+		return nil, stored, fault.Wrap(err, fault.Internal, "internal.upgrade", "upgraded %s %q does not decode", self.Collection, id)
+	}
+	meta(item).Schema = self.Version()
+	return item, stored, nil
+}
+
+// Migrate upgrades every document at an older version and writes it back;
+// revisions are kept, the documents do not change in meaning. A dry run
+// upgrades without writing, so a failing upgrade is reported either way.
+func (self *Repository[T]) Migrate(dryRun bool) (Migration, error) {
+	migration := Migration{Collection: self.Collection, Version: self.Version(), DryRun: dryRun}
+	for version := initialSchema; version < self.Version(); version++ {
+		migration.Steps = append(migration.Steps, Step{From: version, To: version + 1})
+	}
+	unlock, err := self.lock()
+	if err != nil {
+		return migration, err
+	}
+	defer unlock()
+	ids, err := self.backend.List(self.Collection)
+	if err != nil {
+		return migration, fault.Wrap(err, fault.IO, "io.list", "failed to list %s", self.Collection)
+	}
+	for _, id := range ids {
+		data, err := self.backend.Read(self.Collection, id)
+		if err != nil {
+			return migration, self.ioError(err, id)
+		}
+		item, stored, err := self.decode(id, data)
+		if err != nil {
+			return migration, err
+		}
+		migration.Records++
+		if stored == self.Version() {
+			continue
+		}
+		migration.Upgraded++
+		for index := stored - initialSchema; index < len(migration.Steps); index++ {
+			migration.Steps[index].Records++
+		}
+		if !dryRun {
+			if err := self.write(item); err != nil {
+				return migration, err
+			}
+		}
+	}
+	if dryRun || migration.Upgraded == 0 {
+		return migration, nil
+	}
+	// NOTE: The documents were written back without their changes, the
+	// indexes built are built again.
+	fields, err := Indexes(self.backend, self.Collection)
+	if err == nil {
+		for _, field := range fields {
+			if err = reindex(self.backend, self.Collection, field, self.load); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return migration, fault.Wrap(err, fault.IO, "io.index", "%s was upgraded but not indexed again", self.Collection)
+	}
+	return migration, nil
+}
+
+// Migrations ///////////////////////////////////////////////////////////////
+// Migrator is a repository, whatever its model type.
+type Migrator interface {
+	Name() string
+	Version() int
+	Migrate(dryRun bool) (Migration, error)
+}
+
+type Step struct {
+	From    int `json:"from" yaml:"from"`
+	To      int `json:"to" yaml:"to"`
+	Records int `json:"records" yaml:"records"`
+}
+
+type Migration struct {
+	Collection string `json:"collection" yaml:"collection"`
+	Version    int    `json:"version" yaml:"version"`
+	Records    int    `json:"records" yaml:"records"`
+	Upgraded   int    `json:"upgraded" yaml:"upgraded"`
+	Steps      []Step `json:"steps" yaml:"steps"`
+	DryRun     bool   `json:"dry_run" yaml:"dry_run"`
+}
+
+type Migrations []Migration
+
+func (self Migrations) String() string {
+	if len(self) == 0 {
+		return "no models are registered"
+	}
+	var output strings.Builder
+	for index, migration := range self {
+		if 0 < index {
+			output.WriteString("\n")
+		}
+		verb := "upgraded"
+		if migration.DryRun {
+			verb = "would upgrade"
+		}
+		fmt.Fprintf(&output, "%s: %s %d of %d records to version %d\n",
+			migration.Collection, verb, migration.Upgraded, migration.Records, migration.Version)
+		for _, step := range migration.Steps {
+			fmt.Fprintf(&output, "  %d -> %d  %d records\n", step.From, step.To, step.Records)
+		}
+	}
+	return strings.TrimRight(output.String(), "\n")
+}
+
+// Registry lists the repositories of an application by collection.
+type Registry struct {
+	mu           sync.Mutex
+	repositories map[string]Migrator
+}
+
+func NewRegistry() *Registry {
+	return &Registry{repositories: make(map[string]Migrator)}
+}
+
+func (self *Registry) Add(repositories ...Migrator) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, repository := range repositories {
+		self.repositories[repository.Name()] = repository
+	}
+}
+
+func (self *Registry) Names() []string {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	names := make([]string, 0, len(self.repositories))
+	for name := range self.repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Migrate migrates the named collections, or all of them; it stops at the
+// first collection that fails, returning what was migrated until then.
+func (self *Registry) Migrate(dryRun bool, collections ...string) (Migrations, error) {
+	if len(collections) == 0 {
+		collections = self.Names()
+	}
+	migrations := make(Migrations, 0, len(collections))
+	for _, collection := range collections {
+		self.mu.Lock()
+		repository, ok := self.repositories[collection]
+		self.mu.Unlock()
+		if !ok {
+			err := fault.UsageError("usage.unknown_collection", "unknown collection %q", collection)
+			if names := self.Names(); 0 < len(names) {
+				return migrations, err.WithHint("the collections are: %s", strings.Join(names, ", "))
+			}
+			return migrations, err.WithHint("no models are registered")
+		}
+		migration, err := repository.Migrate(dryRun)
+		migrations = append(migrations, migration)
+		if err != nil {
+			return migrations, err
+		}
+	}
+	return migrations, nil
+}
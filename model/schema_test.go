@@ -0,0 +1,143 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"../fault"
+)
+
+// renamed upgrades notes from version 1, where the title was the name.
+func renamed(document Document) error {
+	document["title"] = document["name"]
+	delete(document, "name")
+	return nil
+}
+
+// store writes a document as an older version of the application would.
+func store(t *testing.T, backend Backend, collection string, document Document) {
+	t.Helper()
+	data, err := backend.Codec().Marshal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Write(collection, document["id"].(string), data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		document Document
+		title    string
+		code     string
+	}{
+		{"unversioned", Document{"id": "old", "revision": 1, "name": "alpha"}, "alpha", ""},
+		{"version 1", Document{"id": "old", "revision": 1, "schema": 1, "name": "alpha"}, "alpha", ""},
+		{"current", Document{"id": "old", "revision": 1, "schema": 2, "title": "beta"}, "beta", ""},
+		{"newer", Document{"id": "old", "revision": 1, "schema": 3, "title": "beta"}, "", "io.schema"},
+	}
+	for backendName, backend := range backends(t) {
+		for _, test := range tests {
+			collection := strings.ReplaceAll(test.name, " ", "-")
+			store(t, backend, collection, test.document)
+			notes := NewRepository[note](backend, collection).Upgrade(1, renamed)
+			read, err := notes.Get("old")
+			if test.code != "" || err != nil {
+				if err == nil || fault.As(err).Code != test.code {
+					t.Errorf("%s, %s: read returned %v, expected %s", backendName, test.name, err, test.code)
+				}
+				continue
+			}
+			if read.Title != test.title || read.Schema != 2 || read.Revision != 1 {
+				t.Errorf("%s, %s: read %q at version %d revision %d", backendName, test.name, read.Title, read.Schema, read.Revision)
+			}
+		}
+	}
+}
+
+func TestUpgradeFails(t *testing.T) {
+	for name, backend := range backends(t) {
+		store(t, backend, "notes", Document{"id": "old", "revision": 1, "name": "alpha"})
+		notes := NewRepository[note](backend, "notes").Upgrade(1, func(Document) error { return errors.New("no title") })
+		if _, err := notes.Get("old"); fault.As(err).Code != "internal.upgrade" {
+			t.Errorf("%s: a failing upgrade read as %v", name, err)
+		}
+		if _, err := notes.Migrate(true); fault.As(err).Code != "internal.upgrade" {
+			t.Errorf("%s: a dry run with a failing upgrade returned %v", name, err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	for name, backend := range backends(t) {
+		store(t, backend, "notes", Document{"id": "one", "revision": 1, "name": "alpha"})
+		store(t, backend, "notes", Document{"id": "two", "revision": 3, "schema": 2, "title": "beta"})
+		store(t, backend, "notes", Document{"id": "three", "revision": 1, "schema": 2, "names": []string{"gamma"}})
+		notes := NewRepository[note](backend, "notes").
+			Upgrade(1, renamed).
+			Upgrade(2, func(document Document) error {
+				if names, ok := document["names"]; ok {
+					document["title"] = names.([]interface{})[0]
+					delete(document, "names")
+				}
+				return nil
+			})
+		registry := NewRegistry()
+		registry.Add(notes)
+
+		dryRun, err := registry.Migrate(true)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		expected := "notes: would upgrade 3 of 3 records to version 3\n  1 -> 2  1 records\n  2 -> 3  3 records"
+		if dryRun.String() != expected {
+			t.Errorf("%s: the dry run reported\n%s\nexpected\n%s", name, dryRun, expected)
+		}
+		data, _ := backend.Read("notes", "one")
+		if strings.Contains(string(data), "title") {
+			t.Errorf("%s: a dry run wrote %s", name, data)
+		}
+
+		migrations, err := registry.Migrate(false, "notes")
+		if err != nil || migrations[0].Upgraded != 3 {
+			t.Fatalf("%s: migrated %v, %v", name, migrations, err)
+		}
+		for _, document := range mustDocuments(t, backend, "notes") {
+			if document["title"] == nil || document["name"] != nil || document["names"] != nil {
+				t.Errorf("%s: migrated to %v", name, document)
+			}
+		}
+		again, _ := registry.Migrate(true)
+		if again[0].Upgraded != 0 || again[0].Records != 3 {
+			t.Errorf("%s: migrating twice upgraded %d of %d", name, again[0].Upgraded, again[0].Records)
+		}
+		read, err := notes.Get("two")
+		if err != nil || read.Revision != 3 || read.Title != "beta" {
+			t.Errorf("%s: a migration changed %+v, %v", name, read, err)
+		}
+		if _, err := registry.Migrate(false, "missing"); fault.As(err).Code != "usage.unknown_collection" {
+			t.Errorf("%s: migrating an unknown collection returned %v", name, err)
+		}
+	}
+}
+
+func mustDocuments(t *testing.T, backend Backend, collection string) []Document {
+	t.Helper()
+	documents, err := Documents(backend, collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return documents
+}
+
+func TestUpgradeOrder(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("an upgrade registered out of order did not panic")
+		}
+	}()
+	NewRepository[note](NewFiles(t.TempDir(), JSON{}), "notes").Upgrade(2, renamed)
+}
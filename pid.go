@@ -0,0 +1,57 @@
+package application
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func (self *Application) PIDFile() string {
+	return fmt.Sprintf("%s/%s.pid", self.Runtime.Path, self.Name)
+}
+
+// WritePID writes the PID file atomically: the new file is written beside the
+// old one and renamed over it, so a reader sees either the old or the new PID,
+// never a partial file. This is what lets an upgrade swap PID files.
+func (self *Application) WritePID() error {
+	path := self.PIDFile()
+	temporary, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporary.Name())
+	if _, err := fmt.Fprintf(temporary, "%d\n", os.Getpid()); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Sync(); err != nil {
+		temporary.Close()
+		return err
+	}
+	if err := temporary.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporary.Name(), path)
+}
+
+// ReadPID returns the PID in the PID file, 0 when there is none.
+func (self *Application) ReadPID() int {
+	data, err := ioutil.ReadFile(self.PIDFile())
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// RemovePID removes the PID file only if it still names this process; after
+// an upgrade it belongs to the new process.
+func (self *Application) RemovePID() error {
+	if self.ReadPID() != os.Getpid() {
+		return nil
+	}
+	return os.Remove(self.PIDFile())
+}
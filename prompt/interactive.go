@@ -0,0 +1,96 @@
+package prompt
+
+import (
+	"fmt"
+	"os"
+
+	"../terminal"
+)
+
+// NOTE: Raw mode disables the terminal's own line handling, so every line is
+// terminated with an explicit carriage return and redrawn in place using ANSI
+// cursor movement.
+func (self *Prompt) interactive(file *os.File, question string, options []string, multiple bool) ([]int, error) {
+	state, err := terminal.MakeRaw(file)
+	if err != nil {
+		return nil, err
+	}
+	defer terminal.Restore(file, state)
+
+	help := "arrows to move, enter to select"
+	if multiple {
+		help = "arrows to move, space to toggle, a to toggle all, enter to accept"
+	}
+	fmt.Fprintf(self.Output, "%s (%s)\r\n", question, help)
+
+	cursor, checked := 0, make([]bool, len(options))
+	self.draw(options, cursor, checked, multiple, false)
+	for {
+		key, r, err := terminal.ReadKey(self.reader)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case terminal.Up:
+			cursor = (cursor + len(options) - 1) % len(options)
+		case terminal.Down, terminal.Tab:
+			cursor = (cursor + 1) % len(options)
+		case terminal.Home:
+			cursor = 0
+		case terminal.End:
+			cursor = len(options) - 1
+		case terminal.Interrupt, terminal.EOF, terminal.Escape:
+			fmt.Fprint(self.Output, "\r\n")
+			return nil, ErrInterrupted
+		case terminal.Enter:
+			if !multiple {
+				return []int{cursor}, nil
+			}
+			var selected []int
+			for index, ok := range checked {
+				if ok {
+					selected = append(selected, index)
+				}
+			}
+			return selected, nil
+		case terminal.Character:
+			switch {
+			case multiple && r == ' ':
+				checked[cursor] = !checked[cursor]
+			case multiple && r == 'a':
+				all := true
+				for _, ok := range checked {
+					all = all && ok
+				}
+				for index := range checked {
+					checked[index] = !all
+				}
+			case r == 'k':
+				cursor = (cursor + len(options) - 1) % len(options)
+			case r == 'j':
+				cursor = (cursor + 1) % len(options)
+			}
+		}
+		self.draw(options, cursor, checked, multiple, true)
+	}
+}
+
+func (self *Prompt) draw(options []string, cursor int, checked []bool, multiple, redraw bool) {
+	if redraw {
+		fmt.Fprintf(self.Output, "\x1b[%dA", len(options))
+	}
+	for index, option := range options {
+		pointer := "  "
+		if index == cursor {
+			pointer = "> "
+		}
+		box := ""
+		if multiple {
+			box = "[ ] "
+			if checked[index] {
+				box = "[x] "
+			}
+		}
+		fmt.Fprintf(self.Output, "\r\x1b[K%s%s%s\r\n", pointer, box, option)
+	}
+}
@@ -0,0 +1,199 @@
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"../fault"
+	"../terminal"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Prompts read from and write to the application IO. When the input is a
+// terminal, selection uses raw mode and arrow keys, and passwords are read
+// without echo. Anything else (a pipe, a file, or scripted input fed into
+// `IO.Input` by a test) falls back to plain line based answers, one per line:
+//
+//   confirm       y, yes, n, no (empty line takes the default)
+//   select        the number of the option
+//   multiselect   comma separated numbers (empty line selects nothing)
+//   password      the password
+//
+////////////////////////////////////////////////////////////////////////////////
+
+var (
+	ErrNoInput = fault.UsageError("prompt.no_input", "no input available to answer prompt").
+			WithHint("answer on standard input, or pass --yes to confirm non-interactively")
+	ErrInterrupted = fault.UsageError("prompt.interrupted", "prompt interrupted")
+)
+
+type Prompt struct {
+	Input  io.Reader
+	Output io.Writer
+	// NOTE: Yes auto-confirms every confirmation, it is set by `--yes`.
+	Yes bool
+
+	reader *bufio.Reader
+}
+
+func New(input io.Reader, output io.Writer) *Prompt {
+	return &Prompt{
+		Input:  input,
+		Output: output,
+		reader: bufio.NewReader(input),
+	}
+}
+
+func (self *Prompt) terminal() (*os.File, bool) {
+	if file, ok := self.Input.(*os.File); ok && terminal.IsTerminal(file) {
+		return file, true
+	}
+	return nil, false
+}
+
+func (self *Prompt) readLine() (string, error) {
+	line, err := self.reader.ReadString('\n')
+	if err == io.EOF && 0 < len(line) {
+		err = nil
+	} else if err == io.EOF {
+		return "", ErrNoInput
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Text ///////////////////////////////////////////////////////////////////////
+func (self *Prompt) Text(question, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(self.Output, "%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Fprintf(self.Output, "%s: ", question)
+	}
+	answer, err := self.readLine()
+	if err != nil {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// Confirm ////////////////////////////////////////////////////////////////////
+func (self *Prompt) Confirm(question string, defaultAnswer bool) (bool, error) {
+	choices := "y/N"
+	if defaultAnswer {
+		choices = "Y/n"
+	}
+	if self.Yes {
+		fmt.Fprintf(self.Output, "%s [%s]: yes\n", question, choices)
+		return true, nil
+	}
+	for {
+		fmt.Fprintf(self.Output, "%s [%s]: ", question, choices)
+		answer, err := self.readLine()
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			return defaultAnswer, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+		fmt.Fprintln(self.Output, "please answer yes or no")
+	}
+}
+
+// Password ///////////////////////////////////////////////////////////////////
+func (self *Prompt) Password(question string) (string, error) {
+	fmt.Fprintf(self.Output, "%s: ", question)
+	if file, ok := self.terminal(); ok {
+		state, err := terminal.DisableEcho(file)
+		if err != nil {
+			return "", err
+		}
+		defer terminal.Restore(file, state)
+		defer fmt.Fprintln(self.Output)
+	}
+	return self.readLine()
+}
+
+// Select /////////////////////////////////////////////////////////////////////
+func (self *Prompt) Select(question string, options []string) (int, error) {
+	if len(options) == 0 {
+		return -1, fault.InternalError("prompt.no_options", "select requires at least one option")
+	}
+	if file, ok := self.terminal(); ok {
+		selected, err := self.interactive(file, question, options, false)
+		if err != nil {
+			return -1, err
+		}
+		return selected[0], nil
+	}
+	for {
+		self.list(question, options)
+		fmt.Fprintf(self.Output, "choose [1-%d]: ", len(options))
+		answer, err := self.readLine()
+		if err != nil {
+			return -1, err
+		}
+		if choice, err := strconv.Atoi(strings.TrimSpace(answer)); err == nil && 1 <= choice && choice <= len(options) {
+			return choice - 1, nil
+		}
+		fmt.Fprintf(self.Output, "please choose a number between 1 and %d\n", len(options))
+	}
+}
+
+// MultiSelect ////////////////////////////////////////////////////////////////
+func (self *Prompt) MultiSelect(question string, options []string) ([]int, error) {
+	if len(options) == 0 {
+		return nil, nil
+	}
+	if file, ok := self.terminal(); ok {
+		return self.interactive(file, question, options, true)
+	}
+	for {
+		self.list(question, options)
+		fmt.Fprintf(self.Output, "choose any of [1-%d], comma separated: ", len(options))
+		answer, err := self.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if selected, ok := parseChoices(answer, len(options)); ok {
+			return selected, nil
+		}
+		fmt.Fprintf(self.Output, "please choose numbers between 1 and %d\n", len(options))
+	}
+}
+
+func (self *Prompt) list(question string, options []string) {
+	fmt.Fprintln(self.Output, question)
+	for index, option := range options {
+		fmt.Fprintf(self.Output, "  %d) %s\n", index+1, option)
+	}
+}
+
+func parseChoices(answer string, count int) (selected []int, ok bool) {
+	seen := make(map[int]bool)
+	for _, field := range strings.Split(answer, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		choice, err := strconv.Atoi(field)
+		if err != nil || choice < 1 || count < choice {
+			return nil, false
+		}
+		if !seen[choice] {
+			seen[choice] = true
+			selected = append(selected, choice-1)
+		}
+	}
+	return selected, true
+}
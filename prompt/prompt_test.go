@@ -0,0 +1,145 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func scripted(input string) (*Prompt, *bytes.Buffer) {
+	output := new(bytes.Buffer)
+	return New(strings.NewReader(input), output), output
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		input         string
+		defaultAnswer bool
+		yes           bool
+		answer        bool
+		err           error
+	}{
+		{"y\n", false, false, true, nil},
+		{"YES\n", false, false, true, nil},
+		{"n\n", true, false, false, nil},
+		{"\n", true, false, true, nil},
+		{"\n", false, false, false, nil},
+		{"maybe\nyes\n", false, false, true, nil},
+		{"no", true, false, false, nil},
+		{"", false, false, false, ErrNoInput},
+		{"", false, true, true, nil},
+		{"n\n", false, true, true, nil},
+	}
+	for _, test := range tests {
+		prompt, output := scripted(test.input)
+		prompt.Yes = test.yes
+		answer, err := prompt.Confirm("continue?", test.defaultAnswer)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: error %v, expected %v", test.input, err, test.err)
+		}
+		if answer != test.answer {
+			t.Errorf("%q: answered %v, expected %v", test.input, answer, test.answer)
+		}
+		if !strings.HasPrefix(output.String(), "continue? [") {
+			t.Errorf("%q: wrote %q, expected the question", test.input, output.String())
+		}
+	}
+}
+
+func TestConfirmRetries(t *testing.T) {
+	prompt, output := scripted("maybe\nn\n")
+	if answer, err := prompt.Confirm("continue?", true); err != nil || answer {
+		t.Fatalf("answered %v, %v, expected false", answer, err)
+	}
+	if expected := "continue? [Y/n]: please answer yes or no\ncontinue? [Y/n]: "; output.String() != expected {
+		t.Errorf("wrote %q, expected %q", output.String(), expected)
+	}
+}
+
+func TestYesSkipsInput(t *testing.T) {
+	prompt, output := scripted("n\n")
+	prompt.Yes = true
+	if answer, _ := prompt.Confirm("delete?", false); !answer {
+		t.Error("--yes did not confirm")
+	}
+	if output.String() != "delete? [y/N]: yes\n" {
+		t.Errorf("wrote %q", output.String())
+	}
+	// NOTE: The input is left for the prompts that are not confirmations.
+	if text, _ := prompt.Text("name", ""); text != "n" {
+		t.Errorf("the next prompt read %q, expected n", text)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	options := []string{"red", "green", "blue"}
+	tests := []struct {
+		input    string
+		selected int
+		err      error
+	}{
+		{"1\n", 0, nil},
+		{"3\n", 2, nil},
+		{" 2 \n", 1, nil},
+		{"0\n4\nblue\n2\n", 1, nil},
+		{"9\n", -1, ErrNoInput},
+		{"", -1, ErrNoInput},
+	}
+	for _, test := range tests {
+		prompt, output := scripted(test.input)
+		selected, err := prompt.Select("color?", options)
+		if !errors.Is(err, test.err) || selected != test.selected {
+			t.Errorf("%q: selected %d, %v, expected %d, %v", test.input, selected, err, test.selected, test.err)
+		}
+		if !strings.HasPrefix(output.String(), "color?\n  1) red\n  2) green\n  3) blue\nchoose [1-3]: ") {
+			t.Errorf("%q: wrote %q", test.input, output.String())
+		}
+	}
+	if _, err := New(strings.NewReader("1\n"), new(bytes.Buffer)).Select("color?", nil); err == nil {
+		t.Error("select without options did not fail")
+	}
+}
+
+func TestMultiSelect(t *testing.T) {
+	options := []string{"red", "green", "blue"}
+	tests := []struct {
+		input    string
+		selected []int
+		err      error
+	}{
+		{"1,3\n", []int{0, 2}, nil},
+		{"3, 1, 3\n", []int{2, 0}, nil},
+		{"\n", nil, nil},
+		{"2,x\n2\n", []int{1}, nil},
+		{"4\n", nil, ErrNoInput},
+	}
+	for _, test := range tests {
+		prompt, _ := scripted(test.input)
+		selected, err := prompt.MultiSelect("colors?", options)
+		if !errors.Is(err, test.err) || !reflect.DeepEqual(selected, test.selected) {
+			t.Errorf("%q: selected %v, %v, expected %v, %v", test.input, selected, err, test.selected, test.err)
+		}
+	}
+}
+
+func TestScriptedSession(t *testing.T) {
+	prompt, _ := scripted("notes\n2\n1,2\ny\n")
+	name, err := prompt.Text("name", "app")
+	if err != nil || name != "notes" {
+		t.Fatalf("text %q, %v", name, err)
+	}
+	if selected, err := prompt.Select("kind?", []string{"cli", "daemon"}); err != nil || selected != 1 {
+		t.Fatalf("select %d, %v", selected, err)
+	}
+	if selected, err := prompt.MultiSelect("features?", []string{"http", "rpc"}); err != nil || !reflect.DeepEqual(selected, []int{0, 1}) {
+		t.Fatalf("multiselect %v, %v", selected, err)
+	}
+	if answer, err := prompt.Confirm("create?", false); err != nil || !answer {
+		t.Fatalf("confirm %v, %v", answer, err)
+	}
+	if _, err := prompt.Text("more", ""); !errors.Is(err, ErrNoInput) {
+		t.Errorf("read past the script: %v", err)
+	}
+}
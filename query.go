@@ -0,0 +1,26 @@
+package application
+
+import (
+	"context"
+
+	"./controller"
+	"./fault"
+	"./model"
+)
+
+type QueryParams struct {
+	Collection string `json:"collection" bind:"arg" help:"the collection, such as notes" validate:"required"`
+	model.Query
+}
+
+// queryAction runs a query on the models of any collection, see model.Query;
+// over HTTP it is `GET /api/query?collection=notes&where=title~=draft`.
+func (self *Application) queryAction() *controller.Action {
+	return controller.NewAction("query", "find the models of a collection by their fields", func(ctx context.Context, params QueryParams) (model.Page, error) {
+		if !model.ValidID(params.Collection) {
+			return model.Page{}, fault.UsageError("usage.invalid_collection", "invalid collection %q", params.Collection).
+				WithHint("name a collection, such as: query notes --where title~=draft")
+		}
+		return model.Find(self.Store, params.Collection, params.Query)
+	}).With("method", "GET")
+}
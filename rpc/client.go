@@ -0,0 +1,194 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"../fault"
+)
+
+const DefaultTimeout = 10 * time.Second
+
+type Client struct {
+	// NOTE: Timeout applies to calls whose context has no deadline.
+	Timeout time.Duration
+
+	conn    net.Conn
+	encoder *json.Encoder
+	mu      sync.Mutex
+	next    int64
+	pending map[string]chan Response
+	streams map[string]func(json.RawMessage)
+	err     error
+}
+
+// Socket returns the control socket path for an application runtime
+// directory.
+func Socket(runtime string) string { return filepath.Join(runtime, "control.sock") }
+
+// RuntimeDirectories lists where the runtime directory of an application can
+// be, in order: the user session, system mode, and the fallback without a
+// session.
+func RuntimeDirectories(name string) (directories []string) {
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		directories = append(directories, filepath.Join(runtime, name))
+	}
+	return append(directories,
+		filepath.Join("/run", name),
+		filepath.Join(os.TempDir(), name+"-"+strconv.Itoa(os.Getuid())),
+	)
+}
+
+// Discover finds the control socket of a running daemon.
+func Discover(name string) (string, error) {
+	for _, directory := range RuntimeDirectories(name) {
+		path := Socket(directory)
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path, nil
+		}
+	}
+	return "", fault.UnavailableError("unavailable.daemon", "no control socket found for %s", name).
+		WithHint("the daemon does not appear to be running, start it first")
+}
+
+func Dial(path string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, fault.Wrap(err, fault.Unavailable, "unavailable.daemon", "failed to connect to %s", path).
+			WithHint("the daemon does not appear to be running, start it first")
+	}
+	client := &Client{
+		Timeout: DefaultTimeout,
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+		pending: make(map[string]chan Response),
+		streams: make(map[string]func(json.RawMessage)),
+	}
+	go client.read()
+	return client, nil
+}
+
+// Connect discovers and dials the control socket of the named application.
+func Connect(name string) (*Client, error) {
+	path, err := Discover(name)
+	if err != nil {
+		return nil, err
+	}
+	return Dial(path, DefaultTimeout)
+}
+
+func (self *Client) Close() error { return self.conn.Close() }
+
+func (self *Client) read() {
+	scanner := bufio.NewScanner(self.conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var response Response
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			continue
+		}
+		if 0 < len(response.Stream) {
+			self.mu.Lock()
+			receive, ok := self.streams[string(response.Stream)]
+			self.mu.Unlock()
+			if ok {
+				receive(response.Params)
+			}
+			continue
+		}
+		self.mu.Lock()
+		waiting, ok := self.pending[string(response.ID)]
+		delete(self.pending, string(response.ID))
+		self.mu.Unlock()
+		if ok {
+			waiting <- response
+		}
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.err = fault.UnavailableError("unavailable.disconnected", "connection to the daemon was closed")
+	for id, waiting := range self.pending {
+		close(waiting)
+		delete(self.pending, id)
+	}
+}
+
+// Call invokes a method and decodes its result into result (which may be nil).
+// Errors returned by the daemon come back as the same typed errors.
+func (self *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	if _, ok := ctx.Deadline(); !ok && 0 < self.Timeout {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, self.Timeout)
+		defer cancel()
+	}
+	return self.call(ctx, method, params, result, nil)
+}
+
+// Stream invokes a streaming method: receive is called with every
+// notification, in order, until the stream ends with its result or the
+// context is done. Streams have no timeout.
+func (self *Client) Stream(ctx context.Context, method string, params interface{}, receive func(json.RawMessage), result interface{}) error {
+	return self.call(ctx, method, params, result, receive)
+}
+
+func (self *Client) call(ctx context.Context, method string, params, result interface{}, receive func(json.RawMessage)) error {
+	request := Request{JSONRPC: Version, Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fault.Wrap(err, fault.Usage, "rpc.invalid_params", "invalid params for %s", method)
+		}
+		request.Params = data
+	}
+
+	waiting := make(chan Response, 1)
+	self.mu.Lock()
+	if self.err != nil {
+		self.mu.Unlock()
+		return self.err
+	}
+	self.next++
+	request.ID = json.RawMessage(strconv.FormatInt(self.next, 10))
+	self.pending[string(request.ID)] = waiting
+	if receive != nil {
+		self.streams[string(request.ID)] = receive
+		defer func() {
+			self.mu.Lock()
+			delete(self.streams, string(request.ID))
+			self.mu.Unlock()
+		}()
+	}
+	err := self.encoder.Encode(request)
+	self.mu.Unlock()
+	if err != nil {
+		return fault.Wrap(err, fault.Unavailable, "unavailable.disconnected", "failed to send %s", method)
+	}
+
+	select {
+	case response, ok := <-waiting:
+		if !ok {
+			return fault.UnavailableError("unavailable.disconnected", "connection to the daemon was closed during %s", method)
+		}
+		if response.Error != nil {
+			return response.Error.Fault()
+		}
+		if result != nil && 0 < len(response.Result) {
+			if err := json.Unmarshal(response.Result, result); err != nil {
+				return fault.Wrap(err, fault.Internal, "rpc.invalid_result", "invalid result from %s", method)
+			}
+		}
+		return nil
+	case <-ctx.Done():
+		self.mu.Lock()
+		delete(self.pending, string(request.ID))
+		self.mu.Unlock()
+		return fault.Wrap(ctx.Err(), fault.Unavailable, "unavailable.timeout", "%s did not answer in time", method)
+	}
+}
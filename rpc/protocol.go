@@ -0,0 +1,111 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"../fault"
+	"../validate"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// JSON-RPC 2.0, one message per line over a unix socket. Application errors
+// keep their classification: the error data carries the fault class, machine
+// code and hint, so the client rebuilds the same typed error (and exit code)
+// the daemon produced.
+////////////////////////////////////////////////////////////////////////////////
+
+const Version = "2.0"
+
+// JSON-RPC 2.0 error codes.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+	// NOTE: Application errors are -32000 minus the sysexits code offset, so
+	// the code alone still tells the class apart.
+	ApplicationError = -32000
+)
+
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	// NOTE: Method and Params are only set on notifications sent by the
+	// server, which have no id. Notifications belonging to a stream carry the
+	// id of the request that opened it in Stream.
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Stream json.RawMessage `json:"stream,omitempty"`
+}
+
+type Error struct {
+	Code    int        `json:"code"`
+	Message string     `json:"message"`
+	Data    *ErrorData `json:"data,omitempty"`
+}
+
+type ErrorData struct {
+	Class  string          `json:"class"`
+	Code   string          `json:"code"`
+	Hint   string          `json:"hint,omitempty"`
+	Fields validate.Errors `json:"fields,omitempty"`
+}
+
+func (self *Error) Error() string { return self.Message }
+
+// NewError converts any error returned by a method into its wire form.
+func NewError(err error) *Error {
+	if rpcErr, ok := err.(*Error); ok {
+		return rpcErr
+	}
+	classified := fault.As(err)
+	return &Error{
+		Code:    ApplicationError - (classified.ExitCode() - fault.ExitUsage),
+		Message: err.Error(),
+		Data: &ErrorData{
+			Class:  classified.Class.String(),
+			Code:   classified.Code,
+			Hint:   classified.HintText(),
+			Fields: validate.Fields(err),
+		},
+	}
+}
+
+// Fault converts an error received over the wire back into a typed error.
+func (self *Error) Fault() *fault.Error {
+	if self.Data != nil {
+		err := fault.New(fault.ParseClass(self.Data.Class), self.Data.Code, "%s", self.Message)
+		if self.Data.Fields != nil {
+			// NOTE: The field errors are the cause again, as on the server.
+			message := strings.TrimSuffix(self.Message, ": "+self.Data.Fields.Error())
+			err = fault.Wrap(self.Data.Fields, err.Class, err.Code, "%s", message)
+		}
+		err.Hint = self.Data.Hint
+		return err
+	}
+	switch self.Code {
+	case MethodNotFound:
+		return fault.UsageError("rpc.method_not_found", "%s", self.Message)
+	case InvalidParams:
+		return fault.UsageError("rpc.invalid_params", "%s", self.Message)
+	default:
+		return fault.InternalError("rpc.protocol", "%s (code %d)", self.Message, self.Code)
+	}
+}
+
+func protocolError(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"../fault"
+	"../validate"
+)
+
+type echo struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+func testServer(t *testing.T) (*Server, *Client, context.CancelFunc) {
+	t.Helper()
+	server := NewServer()
+	server.Register("echo", "echo the params", Typed(func(ctx context.Context, params echo) (echo, error) {
+		return params, nil
+	}))
+	server.Register("fail", "fail as asked", Typed(func(ctx context.Context, params echo) (interface{}, error) {
+		switch params.Text {
+		case "invalid":
+			return nil, fault.Wrap(validate.Errors{{Field: "text", Rule: "required", Message: "is required"}},
+				fault.Usage, "usage.invalid", "invalid note")
+		case "busy":
+			return nil, fault.UnavailableError("unavailable.busy", "busy").WithHint("come back later")
+		}
+		return nil, errors.New("plain failure")
+	}))
+	server.Register("count", "stream numbers", Typed(func(ctx context.Context, params echo) (echo, error) {
+		for index := 1; index <= params.Count; index++ {
+			if err := Send(ctx, index); err != nil {
+				return echo{}, err
+			}
+		}
+		return echo{Text: "done", Count: params.Count}, nil
+	}))
+	server.Register("block", "wait until cancelled", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	path := Socket(t.TempDir())
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(ctx, conn)
+		}
+	}()
+	client, err := Dial(path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client, func() {
+		client.Close()
+		listener.Close()
+		cancel()
+	}
+}
+
+func TestCall(t *testing.T) {
+	_, client, stop := testServer(t)
+	defer stop()
+	var result echo
+	if err := client.Call(context.Background(), "echo", echo{Text: "hi", Count: 2}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result != (echo{Text: "hi", Count: 2}) {
+		t.Errorf("echoed %+v", result)
+	}
+	var methods []MethodInfo
+	if err := client.Call(context.Background(), "rpc.methods", nil, &methods); err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(methods))
+	for _, method := range methods {
+		names = append(names, method.Name)
+	}
+	if expected := []string{"block", "count", "echo", "fail", "rpc.methods"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("methods %q, expected %q", names, expected)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, client, stop := testServer(t)
+	defer stop()
+	tests := []struct {
+		method   string
+		params   interface{}
+		code     string
+		exitCode int
+		hint     string
+	}{
+		{"fail", echo{Text: "busy"}, "unavailable.busy", 69, "come back later"},
+		{"fail", echo{Text: "invalid"}, "usage.invalid", 64, fault.Usage.Hint()},
+		{"fail", echo{}, "internal.error", 70, fault.Internal.Hint()},
+		{"missing", nil, "rpc.method_not_found", 64, fault.Usage.Hint()},
+		{"echo", []int{1}, "rpc.invalid_params", 64, fault.Usage.Hint()},
+	}
+	for _, test := range tests {
+		err := client.Call(context.Background(), test.method, test.params, nil)
+		classified := fault.As(err)
+		if classified.Code != test.code || classified.ExitCode() != test.exitCode || classified.HintText() != test.hint {
+			t.Errorf("%s %v failed with %s %d %q, expected %s %d %q", test.method, test.params,
+				classified.Code, classified.ExitCode(), classified.HintText(), test.code, test.exitCode, test.hint)
+		}
+	}
+	err := client.Call(context.Background(), "fail", echo{Text: "invalid"}, nil)
+	if fields := validate.Fields(err); len(fields) != 1 || fields[0].Field != "text" {
+		t.Errorf("the field errors were lost: %v", fields)
+	}
+	if err.Error() != "invalid note: text is required" {
+		t.Errorf("message %q", err.Error())
+	}
+}
+
+func TestStream(t *testing.T) {
+	_, client, stop := testServer(t)
+	defer stop()
+	var received []int
+	var result echo
+	err := client.Stream(context.Background(), "count", echo{Count: 3}, func(data json.RawMessage) {
+		var number int
+		json.Unmarshal(data, &number)
+		received = append(received, number)
+	}, &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(received, []int{1, 2, 3}) || result.Text != "done" {
+		t.Errorf("streamed %v then %+v", received, result)
+	}
+	server := NewServer()
+	server.Register("count", "", Typed(func(ctx context.Context, params echo) (bool, error) {
+		return Streaming(ctx), Send(ctx, 1)
+	}))
+	if _, err := server.Call(context.Background(), "count", nil); err == nil {
+		t.Error("a call without a connection streamed")
+	}
+}
+
+func TestTimeoutAndDisconnect(t *testing.T) {
+	_, client, stop := testServer(t)
+	client.Timeout = 20 * time.Millisecond
+	if err := client.Call(context.Background(), "block", nil, nil); fault.As(err).Code != "unavailable.timeout" {
+		t.Errorf("a blocked call returned %v", err)
+	}
+	done := make(chan error)
+	client.Timeout = 0
+	go func() { done <- client.Call(context.Background(), "block", nil, nil) }()
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	if err := <-done; fault.As(err).Code != "unavailable.disconnected" {
+		t.Errorf("a call on a closed connection returned %v", err)
+	}
+}
+
+func TestDial(t *testing.T) {
+	_, err := Dial(filepath.Join(t.TempDir(), "missing.sock"), time.Second)
+	if fault.As(err).Code != "unavailable.daemon" || fault.ExitCode(err) != fault.ExitUnavailable {
+		t.Errorf("dialing a missing socket returned %v", err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if _, err := Discover("rpc-test-missing"); fault.As(err).Code != "unavailable.daemon" {
+		t.Errorf("discovering a missing daemon returned %v", err)
+	}
+}
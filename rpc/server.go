@@ -0,0 +1,208 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"../fault"
+	"../metrics"
+)
+
+// NOTE: A Method has the same shape as an action handler: a context and the
+// raw params in, a typed result (anything json encodable) or an error out.
+type Method func(ctx context.Context, params json.RawMessage) (interface{}, error)
+
+// Typed adapts a handler taking a typed input into a Method, decoding params
+// into the input; it is how application methods are registered.
+func Typed[I, O any](handler func(context.Context, I) (O, error)) Method {
+	return func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var input I
+		if 0 < len(params) && string(params) != "null" {
+			if err := json.Unmarshal(params, &input); err != nil {
+				return nil, protocolError(InvalidParams, "invalid params: %v", err)
+			}
+		}
+		return handler(ctx, input)
+	}
+}
+
+type MethodInfo struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+}
+
+type Server struct {
+	mu        sync.RWMutex
+	methods   map[string]registered
+	calls     *metrics.Counter
+	durations *metrics.Histogram
+}
+
+type registered struct {
+	info   MethodInfo
+	method Method
+}
+
+func NewServer() *Server {
+	server := &Server{methods: make(map[string]registered)}
+	server.Register("rpc.methods", "list the available methods", func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		return server.Methods(), nil
+	})
+	return server
+}
+
+func (self *Server) Register(name, description string, method Method) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.methods[name] = registered{info: MethodInfo{Name: name, Description: description}, method: method}
+}
+
+func (self *Server) Methods() (methods []MethodInfo) {
+	self.mu.RLock()
+	defer self.mu.RUnlock()
+	for _, method := range self.methods {
+		methods = append(methods, method.info)
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods
+}
+
+// Instrument counts calls by method and result, and observes their duration.
+func (self *Server) Instrument(registry *metrics.Registry) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.calls = registry.Counter("rpc_calls_total", "control socket calls by method and result", "method", "result")
+	self.durations = registry.Histogram("rpc_call_duration_seconds", "control socket call duration", nil, "method")
+}
+
+// Call dispatches a method in-process, it is what a connection does for every
+// request.
+func (self *Server) Call(ctx context.Context, name string, params json.RawMessage) (interface{}, error) {
+	self.mu.RLock()
+	method, ok := self.methods[name]
+	calls, durations := self.calls, self.durations
+	self.mu.RUnlock()
+	if !ok {
+		if calls != nil {
+			calls.Inc("unknown", fault.Usage.String())
+		}
+		return nil, protocolError(MethodNotFound, "unknown method %q", name)
+	}
+	if calls == nil {
+		return method.method(ctx, params)
+	}
+	started := time.Now()
+	result, err := method.method(ctx, params)
+	durations.Observe(time.Since(started).Seconds(), name)
+	if err != nil {
+		calls.Inc(name, fault.ClassOf(err).String())
+	} else {
+		calls.Inc(name, "ok")
+	}
+	return result, err
+}
+
+// Serve returns a connection handler for the server listener; connections are
+// closed when the context is done.
+func (self *Server) Serve(ctx context.Context) func(conn net.Conn) {
+	return func(conn net.Conn) { self.ServeConn(ctx, conn) }
+}
+
+// ServeConn reads requests until the connection is closed. Requests are
+// handled concurrently, responses are written as they complete. When the
+// context is done reading stops, but responses still in flight (such as the
+// answer to `stop`) are written before the connection is closed.
+func (self *Server) ServeConn(ctx context.Context, conn net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := ctx.Done()
+	go func() {
+		<-done
+		conn.SetReadDeadline(time.Now())
+	}()
+
+	connection := &connection{conn: conn, encoder: json.NewEncoder(conn)}
+	ctx = context.WithValue(ctx, connectionKey{}, connection)
+	var pending sync.WaitGroup
+	defer conn.Close()
+	defer pending.Wait()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	// NOTE: Once the connection is closed, streams still sending are
+	// cancelled; they are the only methods that outlive their client.
+	defer cancel()
+	for scanner.Scan() {
+		var request Request
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			connection.send(Response{JSONRPC: Version, Error: protocolError(ParseError, "parse error: %v", err)})
+			continue
+		}
+		if request.JSONRPC != Version || request.Method == "" {
+			connection.send(Response{JSONRPC: Version, ID: request.ID, Error: protocolError(InvalidRequest, "invalid request")})
+			continue
+		}
+		pending.Add(1)
+		go func(request Request) {
+			defer pending.Done()
+			ctx := context.WithValue(ctx, requestKey{}, request)
+			result, err := self.Call(ctx, request.Method, request.Params)
+			if len(request.ID) == 0 {
+				return
+			}
+			response := Response{JSONRPC: Version, ID: request.ID}
+			if err != nil {
+				response.Error = NewError(err)
+			} else if response.Result, err = json.Marshal(result); err != nil {
+				response.Result, response.Error = nil, NewError(err)
+			}
+			connection.send(response)
+		}(request)
+	}
+}
+
+type connectionKey struct{}
+
+type requestKey struct{}
+
+// Streaming reports whether the request being handled can stream, that is
+// it came over a connection with an id.
+func Streaming(ctx context.Context) bool {
+	_, ok := ctx.Value(connectionKey{}).(*connection)
+	request, requested := ctx.Value(requestKey{}).(Request)
+	return ok && requested && 0 < len(request.ID)
+}
+
+// Send streams a notification to the client of the request being handled,
+// tagged with the request id. A streaming method sends until its context is
+// done, the client went away or the daemon is stopping, then returns its
+// final result.
+func Send(ctx context.Context, params interface{}) error {
+	connection, ok := ctx.Value(connectionKey{}).(*connection)
+	request, requested := ctx.Value(requestKey{}).(Request)
+	if !ok || !requested || len(request.ID) == 0 {
+		return protocolError(InvalidRequest, "%s can only be called over a connection, with an id", request.Method)
+	}
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return connection.send(Response{JSONRPC: Version, Method: request.Method, Params: data, Stream: request.ID})
+}
+
+type connection struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	encoder *json.Encoder
+}
+
+func (self *connection) send(response Response) error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.encoder.Encode(response)
+}
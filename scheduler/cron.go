@@ -0,0 +1,218 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Schedules are standard five field cron expressions, evaluated in local time:
+//
+//   minute  hour  day-of-month  month  day-of-week
+//   */15    9-17  *             *      mon-fri
+//
+// Fields take `*`, values, ranges `a-b`, steps `*/n` and `a-b/n`, and lists
+// `a,b`; months and weekdays also take names. As in cron, when both the day of
+// month and the day of week are restricted a day matching either runs the job.
+// The descriptors @yearly, @monthly, @weekly, @daily, @hourly and
+// `@every <duration>` are supported too.
+////////////////////////////////////////////////////////////////////////////////
+
+// Schedule returns the next activation after a time, or the zero time when
+// there is none.
+type Schedule interface {
+	Next(time.Time) time.Time
+}
+
+type Every time.Duration
+
+func (self Every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(self))
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = field{name: "minute", min: 0, max: 59}
+	hours   = field{name: "hour", min: 0, max: 23}
+	days    = field{name: "day of month", min: 1, max: 31}
+	months  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	weekdays = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Cron is a parsed cron expression, each field a bit set of the values it
+// matches.
+type Cron struct {
+	minute, hour, day, month, weekday uint64
+	// NOTE: A `*` day field does not restrict, see the day matching rule.
+	anyDay, anyWeekday bool
+}
+
+func Parse(expression string) (Schedule, error) {
+	expression = strings.TrimSpace(expression)
+	if strings.HasPrefix(expression, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expression, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval in %q: %w", expression, err)
+		}
+		if interval < time.Second {
+			return nil, fmt.Errorf("interval in %q is shorter than a second", expression)
+		}
+		return Every(interval), nil
+	}
+	if descriptor, ok := descriptors[expression]; ok {
+		expression = descriptor
+	}
+	if strings.HasPrefix(expression, "@") {
+		return nil, fmt.Errorf("unknown descriptor %q", expression)
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%q must have 5 fields: minute hour day-of-month month day-of-week", expression)
+	}
+	var cron Cron
+	var err error
+	if cron.minute, err = minutes.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if cron.hour, err = hours.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if cron.day, err = days.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if cron.month, err = months.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if cron.weekday, err = weekdays.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	// NOTE: 7 is also sunday.
+	if cron.weekday&(1<<7) != 0 {
+		cron.weekday |= 1
+	}
+	cron.anyDay, cron.anyWeekday = isAny(fields[2]), isAny(fields[4])
+	return cron, nil
+}
+
+func isAny(field string) bool { return field == "*" || field == "?" }
+
+func (self field) parse(expression string) (bits uint64, err error) {
+	for _, part := range strings.Split(expression, ",") {
+		step := 1
+		if index := strings.IndexByte(part, '/'); 0 <= index {
+			if step, err = strconv.Atoi(part[index+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q in %s field", part[index+1:], self.name)
+			}
+			part = part[:index]
+		}
+		low, high := self.min, self.max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			if low, err = self.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if high, err = self.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range %q in %s field", part, self.name)
+			}
+		default:
+			if low, err = self.value(part); err != nil {
+				return 0, err
+			}
+			// NOTE: `5/10` means from 5 to the end, every 10.
+			if step == 1 {
+				high = low
+			}
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (self field) value(text string) (int, error) {
+	if value, ok := self.names[strings.ToLower(text)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < self.min || self.max < value {
+		return 0, fmt.Errorf("invalid value %q in %s field, expected %d-%d", text, self.name, self.min, self.max)
+	}
+	return value, nil
+}
+
+func (self Cron) dayMatches(t time.Time) bool {
+	day := self.day&(1<<uint(t.Day())) != 0
+	weekday := self.weekday&(1<<uint(t.Weekday())) != 0
+	if self.anyDay || self.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Next finds the next matching minute by advancing the coarsest field that
+// does not match, wrapping around to the start when a field overflows.
+func (self Cron) Next(after time.Time) time.Time {
+	location := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// NOTE: An expression such as `0 0 30 2 *` never matches.
+	limit := t.Year() + 5
+
+wrap:
+	if limit < t.Year() {
+		return time.Time{}
+	}
+	for self.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !self.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for self.hour&(1<<uint(t.Hour())) == 0 {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for self.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	return t
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func at(value string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04:05", value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expression string
+		after      string
+		next       string
+	}{
+		{"* * * * *", "2026-01-02 10:30:15", "2026-01-02 10:31:00"},
+		{"@hourly", "2026-01-02 10:30:00", "2026-01-02 11:00:00"},
+		{"@daily", "2026-12-31 23:59:00", "2027-01-01 00:00:00"},
+		{"@yearly", "2026-03-01 00:00:00", "2027-01-01 00:00:00"},
+		{"*/15 9-17 * * mon-fri", "2026-01-02 10:07:00", "2026-01-02 10:15:00"},
+		{"*/15 9-17 * * mon-fri", "2026-01-02 17:50:00", "2026-01-05 09:00:00"},
+		{"5/20 * * * *", "2026-01-02 10:05:00", "2026-01-02 10:25:00"},
+		{"0,30 8 * * *", "2026-01-02 08:00:00", "2026-01-02 08:30:00"},
+		{"0 0 * * 7", "2026-01-02 00:00:00", "2026-01-04 00:00:00"},
+		{"0 0 * * sun", "2026-01-02 00:00:00", "2026-01-04 00:00:00"},
+		// NOTE: With both day fields restricted either one matches: Friday
+		// the 9th comes before Tuesday the 13th.
+		{"0 12 13 * fri", "2026-01-02 12:00:00", "2026-01-09 12:00:00"},
+		{"0 12 13 * *", "2026-01-02 12:00:00", "2026-01-13 12:00:00"},
+		{"0 0 29 2 *", "2026-01-01 00:00:00", "2028-02-29 00:00:00"},
+		{"0 0 1 jan-mar/2 *", "2026-01-15 00:00:00", "2026-03-01 00:00:00"},
+		{"0 0 30 2 *", "2026-01-01 00:00:00", ""},
+		{"@every 90s", "2026-01-02 10:00:00", "2026-01-02 10:01:30"},
+	}
+	for _, test := range tests {
+		schedule, err := Parse(test.expression)
+		if err != nil {
+			t.Errorf("%q: %v", test.expression, err)
+			continue
+		}
+		next := schedule.Next(at(test.after))
+		if test.next == "" {
+			if !next.IsZero() {
+				t.Errorf("%q after %s: %s, expected never", test.expression, test.after, next)
+			}
+			continue
+		}
+		if !next.Equal(at(test.next)) {
+			t.Errorf("%q after %s: %s, expected %s", test.expression, test.after, next, test.next)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@fortnightly",
+		"@every 10ms",
+		"@every often",
+	} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("%q parsed", expression)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"../kv"
+)
+
+// Keep is how many runs of each job the history keeps.
+const Keep = 20
+
+type Run struct {
+	Job      string        `json:"job" yaml:"job"`
+	Started  time.Time     `json:"started" yaml:"started"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	Error    string        `json:"error,omitempty" yaml:"error,omitempty"`
+	// NOTE: Trigger is "schedule" or "manual".
+	Trigger string `json:"trigger" yaml:"trigger"`
+}
+
+func (self Run) Failed() bool { return self.Error != "" }
+
+// History keeps the latest runs of every job in a key-value store in the
+// state directory, so it survives restarts and can be read without the daemon.
+type History struct {
+	Store *kv.Store
+}
+
+func NewHistory(store *kv.Store) *History {
+	return &History{Store: store}
+}
+
+const historyPrefix = "runs/"
+
+// Record adds a run to the runs of its job.
+func (self *History) Record(run Run) error {
+	unlock, err := self.Store.Lock("history")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	runs := append(self.Runs(run.Job), run)
+	if Keep < len(runs) {
+		runs = runs[len(runs)-Keep:]
+	}
+	data, err := json.Marshal(runs)
+	if err != nil {
+		return err
+	}
+	return self.Store.Put(historyPrefix+run.Job, data)
+}
+
+// Runs returns the runs of a job, oldest first; an unreadable history has
+// none.
+func (self *History) Runs(job string) []Run {
+	var runs []Run
+	if data, err := self.Store.Get(historyPrefix + job); err == nil {
+		json.Unmarshal(data, &runs)
+	}
+	return runs
+}
+
+// Jobs are the names of the jobs with a history.
+func (self *History) Jobs() []string {
+	keys, _ := self.Store.Keys(historyPrefix)
+	jobs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		jobs = append(jobs, strings.TrimPrefix(key, historyPrefix))
+	}
+	return jobs
+}
@@ -0,0 +1,332 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"../fault"
+	"../log"
+	"../metrics"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Jobs run under the scheduler context, the application context, so they see
+// a shutdown. A job is never run twice at the same time: an activation while
+// the previous run is still going is skipped and counted.
+//
+//   app.Jobs.Add(scheduler.Job{
+//     Name:     "cleanup",
+//     Schedule: "@daily",
+//     Jitter:   10 * time.Minute,
+//     Run:      func(ctx context.Context) error { ... },
+//   })
+//
+////////////////////////////////////////////////////////////////////////////////
+
+type Job struct {
+	Name     string
+	Schedule string
+	// NOTE: Jitter delays each run by a random duration up to it, so daemons
+	// on many hosts do not all run the job at the same second.
+	Jitter time.Duration
+	// NOTE: Timeout cancels the run context, zero means no timeout.
+	Timeout time.Duration
+	Run     func(context.Context) error
+}
+
+type Scheduler struct {
+	History *History
+	Log     *log.Logger
+	// NOTE: OnRun is called with every finished run, after it is recorded.
+	OnRun func(Run)
+
+	mu       sync.Mutex
+	context  context.Context
+	entries  map[string]*entry
+	started  bool
+	running  sync.WaitGroup
+	runs     *metrics.Counter
+	skipped  *metrics.Counter
+	duration *metrics.Histogram
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+	trigger  chan struct{}
+	next     time.Time
+	running  bool
+}
+
+func New(ctx context.Context, history *History, logger *log.Logger) *Scheduler {
+	return &Scheduler{History: history, Log: logger, context: ctx, entries: make(map[string]*entry)}
+}
+
+// Instrument counts runs by job and result, skipped runs, and observes run
+// durations.
+func (self *Scheduler) Instrument(registry *metrics.Registry) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.runs = registry.Counter("jobs_runs_total", "job runs by job and result", "job", "result")
+	self.skipped = registry.Counter("jobs_skipped_total", "activations skipped while the job was still running", "job")
+	self.duration = registry.Histogram("jobs_run_duration_seconds", "job run duration", []float64{0.1, 1, 10, 60, 300, 1800, 3600}, "job")
+}
+
+// Add schedules a job, it starts with the scheduler or right away when the
+// scheduler is already started.
+func (self *Scheduler) Add(job Job) error {
+	schedule, err := Parse(job.Schedule)
+	if err != nil {
+		return fault.Wrap(err, fault.Config, "config.schedule", "invalid schedule for job %q", job.Name)
+	}
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if _, ok := self.entries[job.Name]; ok {
+		return fault.InternalError("internal.job", "job %q is already scheduled", job.Name)
+	}
+	entry := &entry{job: job, schedule: schedule, trigger: make(chan struct{}, 1)}
+	self.entries[job.Name] = entry
+	if self.started {
+		go self.loop(entry)
+	}
+	return nil
+}
+
+func (self *Scheduler) Start() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.started {
+		return
+	}
+	self.started = true
+	for _, entry := range self.entries {
+		go self.loop(entry)
+	}
+}
+
+// Wait blocks until running jobs return, they are cancelled with the
+// scheduler context; it is a shutdown hook.
+func (self *Scheduler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		self.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Trigger runs a job now, outside its schedule.
+func (self *Scheduler) Trigger(name string) error {
+	self.mu.Lock()
+	entry, ok := self.entries[name]
+	self.mu.Unlock()
+	if !ok {
+		return fault.UsageError("usage.unknown_job", "no job named %q", name).
+			WithHint("list the jobs with: jobs")
+	}
+	if !self.start(entry, "manual") {
+		return fault.UnavailableError("unavailable.job_running", "job %q is already running", name)
+	}
+	return nil
+}
+
+func (self *Scheduler) loop(entry *entry) {
+	for {
+		now := time.Now()
+		next := entry.schedule.Next(now)
+		if next.IsZero() {
+			self.Log.Warn("job will never run again", "job", entry.job.Name, "schedule", entry.job.Schedule)
+			return
+		}
+		if 0 < entry.job.Jitter {
+			next = next.Add(time.Duration(rand.Int63n(int64(entry.job.Jitter))))
+		}
+		self.mu.Lock()
+		entry.next = next
+		self.mu.Unlock()
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-self.context.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			if !self.start(entry, "schedule") {
+				self.Log.Warn("job still running, skipping", "job", entry.job.Name)
+				if self.skipped != nil {
+					self.skipped.Inc(entry.job.Name)
+				}
+			}
+		}
+	}
+}
+
+// start runs a job in the background unless it is already running.
+func (self *Scheduler) start(entry *entry, trigger string) bool {
+	self.mu.Lock()
+	if entry.running || self.context.Err() != nil {
+		self.mu.Unlock()
+		return false
+	}
+	entry.running = true
+	self.running.Add(1)
+	self.mu.Unlock()
+
+	go func() {
+		defer self.running.Done()
+		run := self.run(entry.job, trigger)
+		self.mu.Lock()
+		entry.running = false
+		self.mu.Unlock()
+		if err := self.History.Record(run); err != nil {
+			self.Log.Error("failed to record the job history", "job", run.Job, "error", err)
+		}
+		if self.OnRun != nil {
+			self.OnRun(run)
+		}
+	}()
+	return true
+}
+
+func (self *Scheduler) run(job Job, trigger string) (run Run) {
+	ctx, cancel := self.context, context.CancelFunc(func() {})
+	if 0 < job.Timeout {
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+	}
+	defer cancel()
+
+	run = Run{Job: job.Name, Started: time.Now(), Trigger: trigger}
+	logger := self.Log.With("job", job.Name)
+	logger.Debug("job started", "trigger", trigger)
+	defer func() {
+		// NOTE: A panicking job fails its run, it does not take the daemon
+		// down with it.
+		if recovered := recover(); recovered != nil {
+			run.Error = fmt.Sprintf("panic: %v", recovered)
+			logger.Error("job panicked", "panic", recovered, "stack", string(debug.Stack()))
+		}
+		run.Duration = time.Since(run.Started)
+		result := "ok"
+		if run.Failed() {
+			result = "error"
+			logger.Error("job failed", "duration", run.Duration, "error", run.Error)
+		} else {
+			logger.Info("job finished", "duration", run.Duration)
+		}
+		if self.runs != nil {
+			self.runs.Inc(job.Name, result)
+			self.duration.Observe(run.Duration.Seconds(), job.Name)
+		}
+	}()
+	if err := job.Run(ctx); err != nil {
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// Status /////////////////////////////////////////////////////////////////////
+type Status struct {
+	Name     string    `json:"name" yaml:"name"`
+	Schedule string    `json:"schedule" yaml:"schedule"`
+	Running  bool      `json:"running" yaml:"running"`
+	Next     time.Time `json:"next,omitempty" yaml:"next,omitempty"`
+	Last     *Run      `json:"last,omitempty" yaml:"last,omitempty"`
+	Failures int       `json:"failures" yaml:"failures"`
+}
+
+type Statuses []Status
+
+// Jobs returns the status of the scheduled jobs, by name.
+func (self *Scheduler) Jobs() Statuses {
+	self.mu.Lock()
+	statuses := make(Statuses, 0, len(self.entries))
+	for name, entry := range self.entries {
+		statuses = append(statuses, Status{Name: name, Schedule: entry.job.Schedule, Running: entry.running, Next: entry.next})
+	}
+	self.mu.Unlock()
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	for index := range statuses {
+		statuses[index].withHistory(self.History.Runs(statuses[index].Name))
+	}
+	return statuses
+}
+
+// FromHistory returns the status of the jobs found in a history, for when the
+// daemon is not running.
+func FromHistory(history *History) Statuses {
+	var statuses Statuses
+	for _, name := range history.Jobs() {
+		status := Status{Name: name}
+		status.withHistory(history.Runs(name))
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (self *Status) withHistory(runs []Run) {
+	if 0 < len(runs) {
+		last := runs[len(runs)-1]
+		self.Last = &last
+	}
+	for _, run := range runs {
+		if run.Failed() {
+			self.Failures++
+		}
+	}
+}
+
+func (self Statuses) String() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%-16s %-16s %-20s %-20s %-10s %s", "JOB", "SCHEDULE", "NEXT", "LAST", "DURATION", "RESULT")
+	for _, status := range self {
+		next, last, duration, result := "-", "never", "-", "-"
+		if !status.Next.IsZero() {
+			next = status.Next.Format("2006-01-02 15:04:05")
+		}
+		if status.Last != nil {
+			last = status.Last.Started.Format("2006-01-02 15:04:05")
+			duration = status.Last.Duration.Round(time.Millisecond).String()
+			result = "ok"
+			if status.Last.Failed() {
+				result = "error: " + status.Last.Error
+			}
+		}
+		if status.Running {
+			result = "running"
+		}
+		schedule := status.Schedule
+		if schedule == "" {
+			schedule = "-"
+		}
+		fmt.Fprintf(&text, "\n%-16s %-16s %-20s %-20s %-10s %s", status.Name, schedule, next, last, duration, result)
+	}
+	return text.String()
+}
+
+type Runs []Run
+
+func (self Runs) String() string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%-20s %-10s %-8s %s", "STARTED", "DURATION", "TRIGGER", "RESULT")
+	for _, run := range self {
+		result := "ok"
+		if run.Failed() {
+			result = "error: " + run.Error
+		}
+		fmt.Fprintf(&text, "\n%-20s %-10s %-8s %s", run.Started.Format("2006-01-02 15:04:05"),
+			run.Duration.Round(time.Millisecond), run.Trigger, result)
+	}
+	return text.String()
+}
@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"../fault"
+	"../kv"
+	"../log"
+)
+
+func testScheduler(t *testing.T) (*Scheduler, chan Run) {
+	t.Helper()
+	store, err := kv.Open(filepath.Join(t.TempDir(), "jobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	scheduler := New(ctx, NewHistory(store), log.New(log.Error))
+	finished := make(chan Run, 10)
+	scheduler.OnRun = func(run Run) { finished <- run }
+	return scheduler, finished
+}
+
+func TestTrigger(t *testing.T) {
+	scheduler, finished := testScheduler(t)
+	release := make(chan struct{})
+	jobs := []Job{
+		{Name: "ok", Schedule: "@yearly", Run: func(context.Context) error { return nil }},
+		{Name: "failing", Schedule: "@yearly", Run: func(context.Context) error { return errors.New("disk full") }},
+		{Name: "panicking", Schedule: "@yearly", Run: func(context.Context) error { panic("boom") }},
+		{Name: "slow", Schedule: "@yearly", Run: func(context.Context) error {
+			<-release
+			return nil
+		}},
+	}
+	for _, job := range jobs {
+		if err := scheduler.Add(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		job   string
+		error string
+	}{
+		{"ok", ""},
+		{"failing", "disk full"},
+		{"panicking", "panic: boom"},
+	}
+	for _, test := range tests {
+		if err := scheduler.Trigger(test.job); err != nil {
+			t.Fatalf("%s: %v", test.job, err)
+		}
+		run := <-finished
+		if run.Job != test.job || run.Error != test.error || run.Trigger != "manual" {
+			t.Errorf("%s ran as %+v, expected error %q", test.job, run, test.error)
+		}
+		if runs := scheduler.History.Runs(test.job); len(runs) != 1 || runs[0].Error != test.error {
+			t.Errorf("%s recorded %+v", test.job, runs)
+		}
+	}
+
+	if err := scheduler.Trigger("slow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Trigger("slow"); fault.As(err).Code != "unavailable.job_running" {
+		t.Errorf("a running job was started again: %v", err)
+	}
+	close(release)
+	<-finished
+	if err := scheduler.Trigger("missing"); fault.As(err).Code != "usage.unknown_job" {
+		t.Errorf("triggering a missing job returned %v", err)
+	}
+
+	statuses := scheduler.Jobs()
+	if len(statuses) != 4 || statuses[0].Name != "failing" || statuses[0].Failures != 1 || statuses[0].Last == nil {
+		t.Errorf("statuses %+v", statuses)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	scheduler, _ := testScheduler(t)
+	noop := func(context.Context) error { return nil }
+	tests := []struct {
+		job  Job
+		code string
+	}{
+		{Job{Name: "cleanup", Schedule: "@daily", Run: noop}, ""},
+		{Job{Name: "cleanup", Schedule: "@daily", Run: noop}, "internal.job"},
+		{Job{Name: "broken", Schedule: "every day", Run: noop}, "config.schedule"},
+	}
+	for _, test := range tests {
+		err := scheduler.Add(test.job)
+		if (test.code == "" && err != nil) || (test.code != "" && fault.As(err).Code != test.code) {
+			t.Errorf("adding %s %q returned %v, expected %q", test.job.Name, test.job.Schedule, err, test.code)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	scheduler, finished := testScheduler(t)
+	scheduler.Add(Job{Name: "tick", Schedule: "@every 1s", Run: func(context.Context) error { return nil }})
+	scheduler.Start()
+	select {
+	case run := <-finished:
+		if run.Trigger != "schedule" {
+			t.Errorf("triggered by %s", run.Trigger)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("a scheduled job did not run")
+	}
+	if next := scheduler.Jobs()[0].Next; next.IsZero() {
+		t.Error("the next run is not reported")
+	}
+}
+
+func TestHistoryKeep(t *testing.T) {
+	scheduler, _ := testScheduler(t)
+	for index := 0; index < Keep+5; index++ {
+		scheduler.History.Record(Run{Job: "backup", Started: time.Unix(int64(index), 0)})
+	}
+	runs := scheduler.History.Runs("backup")
+	if len(runs) != Keep || runs[0].Started.Unix() != 5 {
+		t.Errorf("kept %d runs starting at %d", len(runs), runs[0].Started.Unix())
+	}
+	if jobs := scheduler.History.Jobs(); len(jobs) != 1 || jobs[0] != "backup" {
+		t.Errorf("jobs %v", jobs)
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"crypto/tls"
+	"time"
+)
+
+type Config struct {
+	ReadTimeout  time.Duration `yaml:"read_timeout" json:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout" json:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" json:"idle_timeout"`
+	// NOTE: DrainTimeout is how long shutdown waits for open connections to
+	// finish before closing them.
+	DrainTimeout   time.Duration `yaml:"drain_timeout" json:"drain_timeout" validate:"min=0s"`
+	MaxConnections int           `yaml:"max_connections" json:"max_connections" validate:"min=0"`
+	// NOTE: When a port is taken the following ports are tried instead of
+	// failing, up to PortSearch of them; 0 disables the search.
+	PortSearch int `yaml:"port_search" json:"port_search" validate:"min=0"`
+	TLS        TLS `yaml:"tls" json:"tls"`
+	// NOTE: Declared listeners can be bound by name with Configured, and are
+	// matched by name to sockets passed by a service manager.
+	Listeners []ListenerConfig `yaml:"listeners,omitempty" json:"listeners,omitempty"`
+}
+
+type ListenerConfig struct {
+	Name    string `yaml:"name" json:"name" validate:"required,pattern=^[a-z0-9_-]+$"`
+	Network string `yaml:"network" json:"network" validate:"required,enum=http|tcp|unix"`
+	Address string `yaml:"address" json:"address" validate:"required"`
+}
+
+type TLS struct {
+	Certificate string `yaml:"certificate" json:"certificate" validate:"required_with=Key"`
+	Key         string `yaml:"key" json:"key" validate:"required_with=Certificate"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ReadTimeout:    30 * time.Second,
+		WriteTimeout:   30 * time.Second,
+		IdleTimeout:    2 * time.Minute,
+		DrainTimeout:   15 * time.Second,
+		MaxConnections: 1024,
+		PortSearch:     100,
+		Listeners: []ListenerConfig{
+			{Name: "web", Network: "http", Address: "localhost:8080"},
+		},
+	}
+}
+
+func (self TLS) Enabled() bool { return self.Certificate != "" && self.Key != "" }
+
+func (self TLS) Load() (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(self.Certificate, self.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Listening sockets can be inherited from two places: a parent appd during an
+// upgrade (see upgrade.go), or a service manager doing socket activation:
+//
+//   LISTEN_PID       must be this process, otherwise the variables are ignored
+//   LISTEN_FDS       number of sockets, starting at fd 3
+//   LISTEN_FDNAMES   colon separated names (FileDescriptorName= in the unit)
+//
+// Named sockets are matched to listeners by name. Without names, sockets are
+// matched to the configured listeners in declaration order. A listener with no
+// inherited socket binds one itself.
+//
+// To launch the daemon with inherited fds from a test harness, pass the
+// sockets as extra files and set LISTEN_PID through an exec that keeps the
+// pid: `sh -c 'LISTEN_PID=$$ LISTEN_FDS=1 LISTEN_FDNAMES=web exec appd'`.
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	envListenPID   = "LISTEN_PID"
+	envListenFDs   = "LISTEN_FDS"
+	envListenNames = "LISTEN_FDNAMES"
+)
+
+var (
+	inheritOnce sync.Once
+	inheritMu   sync.Mutex
+	inherited   map[string]*os.File
+	unnamed     []*os.File
+)
+
+// inherit takes ownership of inherited sockets, once per process; the
+// environment is cleared so they are not passed on to children.
+func inherit() {
+	inheritOnce.Do(func() {
+		inherited = make(map[string]*os.File)
+		names := os.Getenv(envInheritNames)
+		os.Unsetenv(envInheritNames)
+		if names != "" {
+			for index, name := range strings.Split(names, ":") {
+				inherited[name] = os.NewFile(uintptr(firstFD+index), name)
+			}
+			return
+		}
+		activated()
+	})
+}
+
+func activated() {
+	pid, count, names := os.Getenv(envListenPID), os.Getenv(envListenFDs), os.Getenv(envListenNames)
+	os.Unsetenv(envListenPID)
+	os.Unsetenv(envListenFDs)
+	os.Unsetenv(envListenNames)
+	if pid != strconv.Itoa(os.Getpid()) {
+		return
+	}
+	fds, err := strconv.Atoi(count)
+	if err != nil || fds <= 0 {
+		return
+	}
+	var fdNames []string
+	if names != "" {
+		fdNames = strings.Split(names, ":")
+	}
+	for index := 0; index < fds; index++ {
+		fd := firstFD + index
+		syscall.CloseOnExec(fd)
+		// NOTE: systemd names sockets without FileDescriptorName= "unknown".
+		if index < len(fdNames) && fdNames[index] != "" && fdNames[index] != "unknown" {
+			inherited[fdNames[index]] = os.NewFile(uintptr(fd), fdNames[index])
+		} else {
+			unnamed = append(unnamed, os.NewFile(uintptr(fd), "unnamed"))
+		}
+	}
+}
+
+// adopt returns the inherited listener with the given name, if any.
+func adopt(name string) (net.Listener, bool) {
+	inherit()
+	inheritMu.Lock()
+	file, ok := inherited[name]
+	delete(inherited, name)
+	inheritMu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return fileListener(file)
+}
+
+// adoptUnnamed returns the unnamed inherited socket at a position, matching
+// the position of a configured listener.
+func adoptUnnamed(index int) (net.Listener, bool) {
+	inherit()
+	inheritMu.Lock()
+	if index < 0 || len(unnamed) <= index || unnamed[index] == nil {
+		inheritMu.Unlock()
+		return nil, false
+	}
+	file := unnamed[index]
+	unnamed[index] = nil
+	inheritMu.Unlock()
+	return fileListener(file)
+}
+
+func fileListener(file *os.File) (net.Listener, bool) {
+	defer file.Close()
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, false
+	}
+	if unix, ok := listener.(*net.UnixListener); ok {
+		unix.SetUnlinkOnClose(false)
+	}
+	return listener, true
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestActivation(t *testing.T) {
+	socket, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+	file, err := socket.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	tests := []struct {
+		name    string
+		pid     string
+		env     []string
+		adopted bool
+	}{
+		{"named", "$$", []string{"LISTEN_FDS=1", "LISTEN_FDNAMES=web"}, true},
+		{"unnamed", "$$", []string{"LISTEN_FDS=1"}, true},
+		{"unknown name", "$$", []string{"LISTEN_FDS=1", "LISTEN_FDNAMES=unknown"}, true},
+		{"other name", "$$", []string{"LISTEN_FDS=1", "LISTEN_FDNAMES=admin"}, false},
+		{"other pid", "1", []string{"LISTEN_FDS=1", "LISTEN_FDNAMES=web"}, false},
+		{"absent", "", nil, false},
+	}
+	for _, test := range tests {
+		// NOTE: LISTEN_PID must name the child, so it is set by a shell that
+		// then execs the test binary in its place.
+		script := `exec "$0"`
+		if test.pid != "" {
+			script = "LISTEN_PID=" + test.pid + " " + script
+		}
+		command := exec.Command("sh", "-c", script, os.Args[0])
+		command.Env = append(append(os.Environ(), childEnv+"=activated"), test.env...)
+		command.ExtraFiles = []*os.File{file}
+		command.Stderr = os.Stderr
+		output, err := command.Output()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		address := strings.TrimSpace(string(output))
+		if adopted := address == socket.Addr().String(); adopted != test.adopted {
+			t.Errorf("%s: served %s, adopted %v, expected %v", test.name, address, adopted, test.adopted)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Handler func(conn net.Conn)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Listener is a managed net.Listener: it limits the number of open
+// connections, tracks them so shutdown can drain them, and (for raw
+// connections handed to a Handler) applies the read and write timeouts as
+// deadlines on every read and write. ServeStreams leaves reads without one,
+// for clients that wait on the server for as long as it has things to send.
+////////////////////////////////////////////////////////////////////////////////
+
+type Listener struct {
+	Name    string
+	Network string
+
+	listener net.Listener
+	config   Config
+	slots    chan struct{}
+	// NOTE: deadlines are only applied to raw connections, http.Server sets
+	// its own; readDeadlines is off for streams.
+	deadlines     bool
+	readDeadlines bool
+
+	accepted atomic.Uint64
+
+	mu          sync.Mutex
+	connections map[*conn]struct{}
+	active      sync.WaitGroup
+	closed      bool
+	done        chan struct{}
+	// NOTE: handedOff is set once a new process has taken over the socket
+	// during an upgrade.
+	handedOff bool
+}
+
+func newListener(name, network string, listener net.Listener, config Config) *Listener {
+	managed := &Listener{
+		Name:        name,
+		Network:     network,
+		listener:    listener,
+		config:      config,
+		connections: make(map[*conn]struct{}),
+		done:        make(chan struct{}),
+	}
+	if 0 < config.MaxConnections {
+		managed.slots = make(chan struct{}, config.MaxConnections)
+	}
+	return managed
+}
+
+func (self *Listener) Addr() net.Addr  { return self.listener.Addr() }
+func (self *Listener) Address() string { return self.listener.Addr().String() }
+
+func (self *Listener) HandedOff() bool {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return self.handedOff
+}
+
+func (self *Listener) Connections() int {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return len(self.connections)
+}
+
+// Accepted is the number of connections accepted since the listener was
+// bound.
+func (self *Listener) Accepted() uint64 { return self.accepted.Load() }
+
+// Accept waits for a free connection slot before accepting, so a full server
+// applies backpressure in the kernel backlog instead of refusing clients.
+func (self *Listener) Accept() (net.Conn, error) {
+	if self.slots != nil {
+		select {
+		case self.slots <- struct{}{}:
+		case <-self.done:
+			return nil, net.ErrClosed
+		}
+	}
+	accepted, err := self.listener.Accept()
+	if err != nil {
+		self.release()
+		return nil, err
+	}
+	tracked := &conn{Conn: accepted, listener: self}
+	self.mu.Lock()
+	if self.closed {
+		self.mu.Unlock()
+		accepted.Close()
+		self.release()
+		return nil, net.ErrClosed
+	}
+	self.connections[tracked] = struct{}{}
+	self.active.Add(1)
+	self.mu.Unlock()
+	self.accepted.Add(1)
+	return tracked, nil
+}
+
+func (self *Listener) release() {
+	if self.slots != nil {
+		<-self.slots
+	}
+}
+
+func (self *Listener) Close() error {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	if self.closed {
+		return nil
+	}
+	self.closed = true
+	close(self.done)
+	return self.listener.Close()
+}
+
+// Serve accepts connections in the background and hands each one to the
+// handler in its own goroutine; the connection is closed when it returns.
+func (self *Listener) Serve(handler Handler) {
+	self.deadlines, self.readDeadlines = true, true
+	self.serve(handler)
+}
+
+// ServeStreams is Serve for connections a client keeps open while it waits,
+// such as a control socket streaming events: reads have no deadline, the
+// client is gone once it closes the connection, but writes keep theirs so a
+// client that stops reading is dropped.
+func (self *Listener) ServeStreams(handler Handler) {
+	self.deadlines = true
+	self.serve(handler)
+}
+
+func (self *Listener) serve(handler Handler) {
+	go func() {
+		for {
+			accepted, err := self.Accept()
+			if err != nil {
+				if temporary, ok := err.(interface{ Temporary() bool }); ok && temporary.Temporary() {
+					time.Sleep(10 * time.Millisecond)
+					continue
+				}
+				return
+			}
+			go func() {
+				defer accepted.Close()
+				handler(accepted)
+			}()
+		}
+	}()
+}
+
+// Drain stops accepting and waits for open connections to finish; once the
+// context is done the remaining connections are closed.
+func (self *Listener) Drain(ctx context.Context) error {
+	self.Close()
+	finished := make(chan struct{})
+	go func() {
+		self.active.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		self.mu.Lock()
+		for tracked := range self.connections {
+			tracked.Conn.Close()
+		}
+		self.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Connection /////////////////////////////////////////////////////////////////
+type conn struct {
+	net.Conn
+	listener *Listener
+	once     sync.Once
+}
+
+func (self *conn) Read(data []byte) (int, error) {
+	if self.listener.readDeadlines {
+		timeout := self.listener.config.ReadTimeout
+		if timeout == 0 {
+			timeout = self.listener.config.IdleTimeout
+		}
+		if 0 < timeout {
+			self.Conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+	}
+	return self.Conn.Read(data)
+}
+
+func (self *conn) Write(data []byte) (int, error) {
+	if self.listener.deadlines && 0 < self.listener.config.WriteTimeout {
+		self.Conn.SetWriteDeadline(time.Now().Add(self.listener.config.WriteTimeout))
+	}
+	return self.Conn.Write(data)
+}
+
+func (self *conn) Close() error {
+	err := self.Conn.Close()
+	self.once.Do(func() {
+		self.listener.mu.Lock()
+		delete(self.listener.connections, self)
+		self.listener.mu.Unlock()
+		self.listener.release()
+		self.listener.active.Done()
+	})
+	return err
+}
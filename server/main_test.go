@@ -0,0 +1,61 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// NOTE: Tests that need a second process run the test binary again with
+// childEnv set to the part to play; TestMain plays it instead of the tests.
+const childEnv = "SERVER_TEST_CHILD"
+
+func TestMain(m *testing.M) {
+	child, ok := children[os.Getenv(childEnv)]
+	if !ok {
+		os.Exit(m.Run())
+	}
+	os.Unsetenv(childEnv)
+	if err := child(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+var children = map[string]func() error{
+	"activated": activatedChild,
+	"upgrade":   upgradedChild,
+	"not-ready": func() error { return nil },
+}
+
+// activatedChild binds the configured listener named web and prints the
+// address it serves.
+func activatedChild() error {
+	config := testConfig()
+	config.Listeners = []ListenerConfig{{Name: "web", Network: "tcp", Address: "127.0.0.1:0"}}
+	listener, err := New(config).Configured("web")
+	if err != nil {
+		return err
+	}
+	fmt.Println(listener.Address())
+	return nil
+}
+
+// upgradedChild adopts the listener of the parent, serves it and tells the
+// parent it is ready.
+func upgradedChild() error {
+	server := New(testConfig())
+	listener, err := server.TCP("127.0.0.1", 0)
+	if err != nil {
+		return err
+	}
+	listener.Serve(func(conn net.Conn) { fmt.Fprintf(conn, "child %d\n", os.Getpid()) })
+	if err := Ready(); err != nil {
+		return err
+	}
+	time.Sleep(10 * time.Second)
+	return nil
+}
@@ -0,0 +1,304 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"../fault"
+	"../metrics"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Following the design notes: when a port is already taken the server does not
+// fail, it checks the next port and keeps going until it finds a free one. The
+// address actually bound is reported through OnListen so the application knows
+// where it is reachable.
+//
+//   listener, _ := app.Server.TCP("localhost", 8080)
+//   listener.Serve(func(conn net.Conn) { ... })
+//
+//   web, _ := app.Server.HTTP("localhost", 8080, handler)
+//   web.Start()
+//
+////////////////////////////////////////////////////////////////////////////////
+
+type Server struct {
+	Config Config
+	// NOTE: OnListen is called with every listener once it is bound, with its
+	// effective address.
+	OnListen func(listener *Listener)
+
+	mu        sync.Mutex
+	listeners []*Listener
+	https     []*HTTP
+	metrics   *metrics.Registry
+}
+
+func New(config Config) *Server {
+	return &Server{Config: config}
+}
+
+func (self *Server) Listeners() []*Listener {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	return append([]*Listener{}, self.listeners...)
+}
+
+func (self *Server) Listener(name string) *Listener {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	for _, listener := range self.listeners {
+		if listener.Name == name {
+			return listener
+		}
+	}
+	return nil
+}
+
+// Listen binds a named listener on any network supported by net.Listen. A
+// socket inherited under the same name (see inherit.go) is adopted instead.
+func (self *Server) Listen(name, network, address string) (*Listener, error) {
+	if listener, ok := adopt(name); ok {
+		return self.manage(name, network, listener), nil
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, classify(err, address)
+	}
+	return self.manage(name, network, listener), nil
+}
+
+func (self *Server) manage(name, network string, listener net.Listener) *Listener {
+	managed := newListener(name, network, listener, self.Config)
+	self.mu.Lock()
+	self.listeners = append(self.listeners, managed)
+	self.mu.Unlock()
+	if self.OnListen != nil {
+		self.OnListen(managed)
+	}
+	return managed
+}
+
+func (self *Server) TCP(host string, port int) (*Listener, error) {
+	return self.tcp(fmt.Sprintf("tcp-%d", port), host, port)
+}
+
+func (self *Server) tcp(name, host string, port int) (*Listener, error) {
+	if listener, ok := adopt(name); ok {
+		return self.manage(name, "tcp", listener), nil
+	}
+	var err error
+	for attempt := 0; attempt <= self.Config.PortSearch; attempt++ {
+		var listener net.Listener
+		if listener, err = net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port+attempt))); err == nil {
+			return self.manage(name, "tcp", listener), nil
+		}
+		if port == 0 || !errors.Is(err, syscall.EADDRINUSE) {
+			break
+		}
+	}
+	return nil, classify(err, net.JoinHostPort(host, strconv.Itoa(port)))
+}
+
+// Unix binds a unix socket, replacing a stale socket file left behind by a
+// process that is no longer listening on it.
+func (self *Server) Unix(path string) (*Listener, error) {
+	return self.unix(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), path)
+}
+
+func (self *Server) unix(name, path string) (*Listener, error) {
+	if listener, ok := adopt(name); ok {
+		return self.manage(name, "unix", listener), nil
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if existing, err := net.Dial("unix", path); err == nil {
+			existing.Close()
+			return nil, fault.UnavailableError("unavailable.socket_in_use", "%s is in use by another process", path).
+				WithHint("another instance is already running")
+		}
+		os.Remove(path)
+	}
+	listener, err := self.Listen(name, "unix", path)
+	if err != nil {
+		return nil, err
+	}
+	os.Chmod(path, 0660)
+	return listener, nil
+}
+
+// Instrument reports the listeners and their connections on a registry, and
+// the requests of HTTP listeners set up after this call.
+func (self *Server) Instrument(registry *metrics.Registry) {
+	self.mu.Lock()
+	self.metrics = registry
+	self.mu.Unlock()
+	registry.Collect("server_connections", "open connections by listener", metrics.GaugeType, func() []metrics.Sample {
+		return self.samples(func(listener *Listener) float64 { return float64(listener.Connections()) })
+	})
+	registry.Collect("server_connections_accepted_total", "accepted connections by listener", metrics.CounterType, func() []metrics.Sample {
+		return self.samples(func(listener *Listener) float64 { return float64(listener.Accepted()) })
+	})
+}
+
+func (self *Server) samples(value func(*Listener) float64) []metrics.Sample {
+	listeners := self.Listeners()
+	samples := make([]metrics.Sample, 0, len(listeners))
+	for _, listener := range listeners {
+		samples = append(samples, metrics.Sample{
+			Labels: []metrics.Label{{Name: "listener", Value: listener.Name}},
+			Value:  value(listener),
+		})
+	}
+	return samples
+}
+
+// Configured /////////////////////////////////////////////////////////////////
+// Configured binds the listener declared under the name in the config, or
+// adopts the socket a service manager passed for it.
+func (self *Server) Configured(name string) (*Listener, error) {
+	for index, declared := range self.Config.Listeners {
+		if declared.Name != name {
+			continue
+		}
+		if listener, ok := adoptUnnamed(index); ok {
+			return self.manage(name, declared.Network, listener), nil
+		}
+		switch declared.Network {
+		case "unix":
+			return self.unix(name, declared.Address)
+		case "tcp", "http", "":
+			host, port, err := net.SplitHostPort(declared.Address)
+			if err != nil {
+				return nil, fault.Wrap(err, fault.Config, "config.listener", "invalid address for listener %q", name)
+			}
+			number, err := strconv.Atoi(port)
+			if err != nil {
+				return nil, fault.Wrap(err, fault.Config, "config.listener", "invalid port for listener %q", name)
+			}
+			return self.tcp(name, host, number)
+		default:
+			return nil, fault.ConfigError("config.listener", "listener %q has unsupported network %q", name, declared.Network).
+				WithHint("use tcp, http or unix")
+		}
+	}
+	return nil, fault.ConfigError("config.listener", "no listener named %q is configured", name)
+}
+
+// HTTP ///////////////////////////////////////////////////////////////////////
+type HTTP struct {
+	*Listener
+	Server *http.Server
+
+	tls *tls.Config
+	err error
+}
+
+func (self *Server) HTTP(host string, port int, handler http.Handler) (*HTTP, error) {
+	listener, err := self.tcp(fmt.Sprintf("http-%d", port), host, port)
+	if err != nil {
+		return nil, err
+	}
+	return self.Handle(listener, handler)
+}
+
+// Handle serves HTTP on an already bound listener, such as a configured one.
+func (self *Server) Handle(listener *Listener, handler http.Handler) (*HTTP, error) {
+	self.mu.Lock()
+	registry := self.metrics
+	self.mu.Unlock()
+	if registry != nil {
+		handler = registry.InstrumentHandler(listener.Name, handler)
+	}
+	web := &HTTP{
+		Listener: listener,
+		Server: &http.Server{
+			Handler:      handler,
+			ReadTimeout:  self.Config.ReadTimeout,
+			WriteTimeout: self.Config.WriteTimeout,
+			IdleTimeout:  self.Config.IdleTimeout,
+		},
+	}
+	if self.Config.TLS.Enabled() {
+		config, err := self.Config.TLS.Load()
+		if err != nil {
+			listener.Close()
+			return nil, fault.Wrap(err, fault.Config, "config.tls", "failed to load the tls certificate")
+		}
+		web.tls = config
+	}
+	self.mu.Lock()
+	self.https = append(self.https, web)
+	self.mu.Unlock()
+	return web, nil
+}
+
+func (self *HTTP) Start() {
+	var listener net.Listener = self.Listener
+	if self.tls != nil {
+		listener = tls.NewListener(listener, self.tls)
+	}
+	go func() {
+		if err := self.Server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			self.err = err
+		}
+	}()
+}
+
+func (self *HTTP) Err() error { return self.err }
+
+// Shutdown ///////////////////////////////////////////////////////////////////
+// Shutdown stops accepting on every listener and drains open connections in
+// parallel until the context is done.
+func (self *Server) Shutdown(ctx context.Context) error {
+	self.mu.Lock()
+	listeners, https := self.listeners, self.https
+	self.listeners, self.https = nil, nil
+	self.mu.Unlock()
+
+	served := make(map[*Listener]*HTTP)
+	for _, web := range https {
+		served[web.Listener] = web
+	}
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener *Listener) {
+			if web, ok := served[listener]; ok {
+				web.Server.Shutdown(ctx)
+			}
+			err := listener.Drain(ctx)
+			if listener.Network == "unix" && !listener.HandedOff() {
+				os.Remove(listener.Address())
+			}
+			errs <- err
+		}(listener)
+	}
+	var err error
+	for range listeners {
+		if drainErr := <-errs; drainErr != nil && err == nil {
+			err = drainErr
+		}
+	}
+	return err
+}
+
+func classify(err error, address string) error {
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		return fault.Wrap(err, fault.Unavailable, "unavailable.address_in_use", "%s is already in use", address)
+	case errors.Is(err, syscall.EACCES):
+		return fault.Wrap(err, fault.Permission, "permission.bind", "not allowed to listen on %s", address).
+			WithHint("ports below 1024 require privileges, choose a higher port")
+	}
+	return fault.Wrap(err, fault.Unavailable, "unavailable.listen", "failed to listen on %s", address)
+}
@@ -0,0 +1,277 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"../fault"
+)
+
+func testConfig() Config {
+	config := DefaultConfig()
+	config.Listeners = nil
+	config.DrainTimeout = time.Second
+	return config
+}
+
+func TestPortSearch(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	port := taken.Addr().(*net.TCPAddr).Port
+	tests := []struct {
+		search int
+		code   string
+	}{
+		{10, ""},
+		{0, "unavailable.address_in_use"},
+	}
+	for _, test := range tests {
+		config := testConfig()
+		config.PortSearch = test.search
+		server := New(config)
+		var announced *Listener
+		server.OnListen = func(listener *Listener) { announced = listener }
+		listener, err := server.TCP("127.0.0.1", port)
+		if test.code != "" {
+			if fault.As(err).Code != test.code {
+				t.Errorf("with a search of %d: %v, expected %s", test.search, err, test.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("with a search of %d: %v", test.search, err)
+		}
+		bound := listener.Addr().(*net.TCPAddr).Port
+		if bound <= port || port+test.search < bound {
+			t.Errorf("bound port %d, expected one after %d", bound, port)
+		}
+		if announced != listener || server.Listener("tcp-"+strconv.Itoa(port)) != listener {
+			t.Error("the listener was not announced and registered")
+		}
+		server.Shutdown(context.Background())
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+	// NOTE: A socket file nobody listens on is stale and replaced.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	server := New(testConfig())
+	listener, err := server.Unix(path)
+	if err != nil {
+		t.Fatalf("a stale socket was not replaced: %v", err)
+	}
+	if listener.Name != "control" {
+		t.Errorf("named %q", listener.Name)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0660 {
+		t.Errorf("socket mode %v, %v", info.Mode(), err)
+	}
+	if _, err := New(testConfig()).Unix(path); fault.As(err).Code != "unavailable.socket_in_use" {
+		t.Errorf("a socket in use was taken over: %v", err)
+	}
+	server.Shutdown(context.Background())
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the socket was not removed on shutdown: %v", err)
+	}
+}
+
+func TestConfigured(t *testing.T) {
+	config := testConfig()
+	config.Listeners = []ListenerConfig{
+		{Name: "web", Network: "http", Address: "127.0.0.1:0"},
+		{Name: "control", Network: "unix", Address: filepath.Join(t.TempDir(), "control.sock")},
+		{Name: "broken", Network: "tcp", Address: "no port"},
+		{Name: "udp", Network: "udp", Address: "127.0.0.1:0"},
+	}
+	server := New(config)
+	defer server.Shutdown(context.Background())
+	tests := []struct {
+		name string
+		code string
+	}{
+		{"web", ""},
+		{"control", ""},
+		{"broken", "config.listener"},
+		{"udp", "config.listener"},
+		{"missing", "config.listener"},
+	}
+	for _, test := range tests {
+		listener, err := server.Configured(test.name)
+		switch {
+		case test.code == "" && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.code == "" && listener.Name != test.name:
+			t.Errorf("%s: bound as %s", test.name, listener.Name)
+		case test.code != "" && fault.As(err).Code != test.code:
+			t.Errorf("%s: %v, expected %s", test.name, err, test.code)
+		}
+	}
+}
+
+func TestDrain(t *testing.T) {
+	server := New(testConfig())
+	listener, err := server.TCP("127.0.0.1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	listener.Serve(func(conn net.Conn) {
+		conn.Write([]byte("hello\n"))
+		<-release
+		conn.Write([]byte("bye\n"))
+	})
+	client, err := net.Dial("tcp", listener.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	reader := bufio.NewReader(client)
+	if line, _ := reader.ReadString('\n'); line != "hello\n" {
+		t.Fatalf("read %q", line)
+	}
+	if listener.Connections() != 1 || listener.Accepted() != 1 {
+		t.Errorf("%d connections, %d accepted", listener.Connections(), listener.Accepted())
+	}
+	drained := make(chan error)
+	go func() { drained <- server.Shutdown(context.Background()) }()
+	select {
+	case err := <-drained:
+		t.Fatalf("shutdown returned with a connection open: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := net.Dial("tcp", listener.Address()); err == nil {
+		t.Error("the listener still accepts while draining")
+	}
+	close(release)
+	if line, _ := reader.ReadString('\n'); line != "bye\n" {
+		t.Errorf("the open connection was cut: %q", line)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("drain failed: %v", err)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	server := New(testConfig())
+	listener, _ := server.TCP("127.0.0.1", 0)
+	listener.Serve(func(conn net.Conn) { io.Copy(io.Discard, conn) })
+	client, err := net.Dial("tcp", listener.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for listener.Connections() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown returned %v, expected the deadline", err)
+	}
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Error("the connection was not closed after the drain timeout")
+	}
+}
+
+func TestMaxConnections(t *testing.T) {
+	config := testConfig()
+	config.MaxConnections = 1
+	server := New(config)
+	defer server.Shutdown(context.Background())
+	listener, _ := server.TCP("127.0.0.1", 0)
+	listener.Serve(func(conn net.Conn) {
+		conn.Write([]byte("x"))
+		io.Copy(io.Discard, conn)
+	})
+	first, _ := net.Dial("tcp", listener.Address())
+	first.Read(make([]byte, 1))
+	second, _ := net.Dial("tcp", listener.Address())
+	defer second.Close()
+	second.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := second.Read(make([]byte, 1)); err == nil {
+		t.Fatal("a connection over the limit was served")
+	}
+	first.Close()
+	second.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := second.Read(make([]byte, 1)); err != nil {
+		t.Errorf("the waiting connection was not served once a slot was free: %v", err)
+	}
+}
+
+func TestReadDeadlines(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams bool
+		read    string
+	}{
+		{"serve", false, ""},
+		{"serve streams", true, "late\n"},
+	}
+	for _, test := range tests {
+		config := testConfig()
+		config.ReadTimeout = 30 * time.Millisecond
+		server := New(config)
+		listener, _ := server.TCP("127.0.0.1", 0)
+		read := make(chan string, 1)
+		handler := func(conn net.Conn) {
+			line, _ := bufio.NewReader(conn).ReadString('\n')
+			read <- line
+		}
+		if test.streams {
+			listener.ServeStreams(handler)
+		} else {
+			listener.Serve(handler)
+		}
+		client, _ := net.Dial("tcp", listener.Address())
+		time.Sleep(100 * time.Millisecond)
+		client.Write([]byte("late\n"))
+		if line := <-read; line != test.read {
+			t.Errorf("%s: read %q after the read timeout, expected %q", test.name, line, test.read)
+		}
+		client.Close()
+		server.Shutdown(context.Background())
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	server := New(testConfig())
+	web, err := server.HTTP("127.0.0.1", 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web.Start()
+	response, err := http.Get("http://" + web.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("served %q", body)
+	}
+	if err := server.Shutdown(context.Background()); err != nil || web.Err() != nil {
+		t.Errorf("shutdown %v, %v", err, web.Err())
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"../fault"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Zero-downtime upgrade: the running process re-executes its binary (the new
+// one, once it has been replaced on disk) and passes its listening sockets as
+// inherited file descriptors, so the kernel keeps queueing connections while
+// the new process starts. The new process adopts the sockets by listener name,
+// and signals readiness over a pipe; only then does the old process stop
+// accepting and drain its connections.
+//
+//   fd 3 .. 3+n-1   listening sockets, named in APPLICATION_INHERIT_NAMES
+//   fd 3+n          readiness pipe, written once by Ready()
+//
+////////////////////////////////////////////////////////////////////////////////
+
+const (
+	envInheritNames = "APPLICATION_INHERIT_NAMES"
+	envReadyFD      = "APPLICATION_READY_FD"
+	firstFD         = 3
+)
+
+// Ready tells the parent process of an upgrade that this process has adopted
+// the sockets and is serving; it does nothing when not started by an upgrade.
+func Ready() error {
+	value := os.Getenv(envReadyFD)
+	os.Unsetenv(envReadyFD)
+	if value == "" {
+		return nil
+	}
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fault.InternalError("upgrade.ready", "invalid %s %q", envReadyFD, value)
+	}
+	pipe := os.NewFile(uintptr(fd), "ready")
+	defer pipe.Close()
+	_, err = fmt.Fprintf(pipe, "ready %d\n", os.Getpid())
+	return err
+}
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+// Upgrade starts a new copy of the executable with the listening sockets and
+// waits for it to become ready. On success every listener is marked as handed
+// off, so the following Shutdown drains connections without removing socket
+// files the new process now serves.
+func (self *Server) Upgrade(timeout time.Duration) (*os.Process, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fault.Wrap(err, fault.IO, "upgrade.executable", "failed to locate the executable")
+	}
+
+	listeners := self.Listeners()
+	var files []*os.File
+	var names []string
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, listener := range listeners {
+		source, ok := listener.listener.(filer)
+		if !ok {
+			continue
+		}
+		file, err := source.File()
+		if err != nil {
+			return nil, fault.Wrap(err, fault.IO, "upgrade.socket", "failed to pass on listener %s", listener.Name)
+		}
+		files, names = append(files, file), append(names, listener.Name)
+	}
+
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, fault.Wrap(err, fault.IO, "upgrade.pipe", "failed to create the readiness pipe")
+	}
+	defer reader.Close()
+
+	// NOTE: os/exec takes each file's Fd(), which switches the socket, shared
+	// with the listener still accepting here, to blocking mode; an accept
+	// blocked in the kernel would then not return on Close. The raw fds are
+	// passed instead.
+	fds := []uintptr{os.Stdin.Fd(), os.Stdout.Fd(), os.Stderr.Fd()}
+	for _, file := range append(append([]*os.File{}, files...), writer) {
+		fds = append(fds, rawFD(file))
+	}
+	pid, err := syscall.ForkExec(executable, os.Args, &syscall.ProcAttr{
+		Env: append(withoutInheritance(os.Environ()),
+			envInheritNames+"="+strings.Join(names, ":"),
+			envReadyFD+"="+strconv.Itoa(firstFD+len(files)),
+		),
+		Files: fds,
+	})
+	writer.Close()
+	if err != nil {
+		return nil, fault.Wrap(err, fault.Internal, "upgrade.start", "failed to start %s", executable)
+	}
+	process, _ := os.FindProcess(pid)
+
+	ready := make(chan error, 1)
+	go func() {
+		line, err := bufio.NewReader(reader).ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, "ready") {
+			ready <- fault.UnavailableError("upgrade.not_ready", "the new process exited before it was ready")
+			return
+		}
+		ready <- nil
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(timeout):
+		err = fault.UnavailableError("upgrade.timeout", "the new process was not ready within %s", timeout)
+	}
+	if err != nil {
+		process.Kill()
+		process.Wait()
+		return nil, err
+	}
+	go process.Wait()
+
+	for _, listener := range listeners {
+		listener.handOff()
+	}
+	return process, nil
+}
+
+func (self *Listener) handOff() {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+	self.handedOff = true
+	if unix, ok := self.listener.(*net.UnixListener); ok {
+		unix.SetUnlinkOnClose(false)
+	}
+}
+
+// rawFD returns the descriptor of a file without changing its mode, as Fd
+// would.
+func rawFD(file *os.File) (fd uintptr) {
+	if raw, err := file.SyscallConn(); err == nil {
+		raw.Control(func(descriptor uintptr) { fd = descriptor })
+	}
+	return fd
+}
+
+func withoutInheritance(environment []string) (filtered []string) {
+	for _, variable := range environment {
+		if !strings.HasPrefix(variable, envInheritNames+"=") && !strings.HasPrefix(variable, envReadyFD+"=") {
+			filtered = append(filtered, variable)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"../fault"
+)
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		child string
+		code  string
+	}{
+		{"upgrade", ""},
+		{"not-ready", "upgrade.not_ready"},
+	}
+	for _, test := range tests {
+		t.Setenv(childEnv, test.child)
+		server := New(testConfig())
+		listener, err := server.TCP("127.0.0.1", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener.Serve(func(conn net.Conn) { fmt.Fprintln(conn, "parent") })
+		process, err := server.Upgrade(5 * time.Second)
+		if test.code != "" {
+			if fault.As(err).Code != test.code || listener.HandedOff() {
+				t.Errorf("%s: upgrade returned %v, handed off %v", test.child, err, listener.HandedOff())
+			}
+			server.Shutdown(context.Background())
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.child, err)
+		}
+		t.Cleanup(func() { process.Kill() })
+		if !listener.HandedOff() {
+			t.Errorf("%s: the listener was not handed off", test.child)
+		}
+		server.Shutdown(context.Background())
+		conn, err := net.Dial("tcp", listener.Address())
+		if err != nil {
+			t.Fatalf("%s: the socket closed with the parent: %v", test.child, err)
+		}
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+		if expected := fmt.Sprintf("child %d\n", process.Pid); line != expected {
+			t.Errorf("%s: served %q after the upgrade, expected %q", test.child, line, expected)
+		}
+	}
+}
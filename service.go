@@ -0,0 +1,23 @@
+package application
+
+import (
+	"./service"
+)
+
+// Unit describes the daemon for service file generation, with the system-mode
+// paths and the listeners declared in the config.
+func (self *Application) Unit(exec string, arguments ...string) service.Unit {
+	unit := service.Unit{
+		Name:        self.Name,
+		Description: self.Name + " daemon",
+		Version:     self.Version.String(),
+		Exec:        exec,
+		Arguments:   arguments,
+		User:        self.Name,
+		Paths:       service.SystemPaths(self.Name),
+	}
+	if self.Settings != nil {
+		unit.Listeners = self.Settings.Server.Listeners
+	}
+	return unit
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"../server"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Service files are generated from a Unit, which is filled in from the
+// Application (see Application.Unit). Generation is deterministic: the same
+// Unit always produces the same bytes, so the output can be golden-tested and
+// diffed against what is installed.
+//
+// In system mode the daemon is pointed at its directories through the
+// environment variables systemd sets for StateDirectory=, RuntimeDirectory=
+// and ConfigurationDirectory=; the OpenRC and runit scripts export the same
+// variables so every init system gets the same layout.
+////////////////////////////////////////////////////////////////////////////////
+
+type Init string
+
+const (
+	Systemd Init = "systemd"
+	OpenRC  Init = "openrc"
+	Runit   Init = "runit"
+)
+
+func ParseInit(value string) (Init, error) {
+	switch Init(value) {
+	case Systemd, OpenRC, Runit:
+		return Init(value), nil
+	}
+	return "", fmt.Errorf("unknown init system %q", value)
+}
+
+// Paths are the system-mode directories of the daemon.
+type Paths struct {
+	Config  string `yaml:"config" json:"config"`
+	Data    string `yaml:"data" json:"data"`
+	State   string `yaml:"state" json:"state"`
+	Runtime string `yaml:"runtime" json:"runtime"`
+}
+
+func SystemPaths(name string) Paths {
+	return Paths{
+		Config:  "/etc/" + name,
+		Data:    "/var/lib/" + name,
+		State:   "/var/lib/" + name + "/state",
+		Runtime: "/run/" + name,
+	}
+}
+
+type Unit struct {
+	Name        string                  `yaml:"name" json:"name"`
+	Description string                  `yaml:"description" json:"description"`
+	Version     string                  `yaml:"version" json:"version"`
+	Exec        string                  `yaml:"exec" json:"exec"`
+	Arguments   []string                `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+	User        string                  `yaml:"user" json:"user"`
+	Paths       Paths                   `yaml:"paths" json:"paths"`
+	Listeners   []server.ListenerConfig `yaml:"listeners,omitempty" json:"listeners,omitempty"`
+}
+
+// File is a generated file and where it is installed.
+type File struct {
+	Path    string `yaml:"path" json:"path"`
+	Mode    uint32 `yaml:"mode" json:"mode"`
+	Content string `yaml:"content" json:"content"`
+}
+
+type Files []File
+
+func (self Files) String() string {
+	var output strings.Builder
+	for index, file := range self {
+		if index > 0 {
+			output.WriteString("\n")
+		}
+		fmt.Fprintf(&output, "# ==> %s <==\n%s", file.Path, file.Content)
+	}
+	return strings.TrimSuffix(output.String(), "\n")
+}
+
+// Files returns the files to install for an init system.
+func (self Unit) Files(init Init) Files {
+	switch init {
+	case OpenRC:
+		return Files{{Path: "/etc/init.d/" + self.Name, Mode: 0755, Content: self.OpenRC()}}
+	case Runit:
+		return Files{{Path: "/etc/sv/" + self.Name + "/run", Mode: 0755, Content: self.Runit()}}
+	}
+	files := Files{{Path: "/etc/systemd/system/" + self.Name + ".service", Mode: 0644, Content: self.Systemd()}}
+	for _, listener := range self.sockets() {
+		files = append(files, File{
+			Path:    "/etc/systemd/system/" + self.socketUnit(listener),
+			Mode:    0644,
+			Content: self.SystemdSocket(listener),
+		})
+	}
+	return files
+}
+
+func (self Unit) command() string {
+	return strings.Join(append([]string{self.Exec}, self.Arguments...), " ")
+}
+
+func (self Unit) header(comment string) string {
+	return fmt.Sprintf("%s generated by `%s install-service` for %s %s\n", comment, self.Exec, self.Name, self.Version)
+}
+
+// environment is the layout exported by scripts for init systems that do not
+// set the systemd directory variables themselves.
+func (self Unit) environment() [][2]string {
+	return [][2]string{
+		{"CONFIGURATION_DIRECTORY", self.Paths.Config},
+		{"STATE_DIRECTORY", self.Paths.Data + ":" + self.Paths.State},
+		{"RUNTIME_DIRECTORY", self.Paths.Runtime},
+	}
+}
+
+// systemd ////////////////////////////////////////////////////////////////////
+func (self Unit) Systemd() string {
+	var unit strings.Builder
+	unit.WriteString(self.header("#"))
+	fmt.Fprintf(&unit, "[Unit]\nDescription=%s\n", self.Description)
+	unit.WriteString("After=network.target\n")
+	if sockets := self.sockets(); len(sockets) > 0 {
+		names := make([]string, 0, len(sockets))
+		for _, listener := range sockets {
+			names = append(names, self.socketUnit(listener))
+		}
+		fmt.Fprintf(&unit, "Requires=%s\nAfter=%s\n", strings.Join(names, " "), strings.Join(names, " "))
+	}
+
+	unit.WriteString("\n[Service]\nType=simple\n")
+	fmt.Fprintf(&unit, "ExecStart=%s\n", self.command())
+	unit.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	// NOTE: An upgrade (SIGUSR2) re-execs as a child that outlives the main
+	// process, which systemd would kill; restarting with socket activation
+	// keeps the listening sockets open instead.
+	unit.WriteString("Restart=on-failure\nKillSignal=SIGTERM\n")
+	fmt.Fprintf(&unit, "User=%s\nGroup=%s\n", self.User, self.User)
+	fmt.Fprintf(&unit, "ConfigurationDirectory=%s\n", relative("/etc/", self.Paths.Config))
+	fmt.Fprintf(&unit, "StateDirectory=%s %s\n", relative("/var/lib/", self.Paths.Data), relative("/var/lib/", self.Paths.State))
+	fmt.Fprintf(&unit, "RuntimeDirectory=%s\n", relative("/run/", self.Paths.Runtime))
+	unit.WriteString("UMask=0077\n")
+
+	unit.WriteString("\n# sandboxing\n")
+	unit.WriteString(strings.Join([]string{
+		"NoNewPrivileges=yes",
+		"CapabilityBoundingSet=",
+		"ProtectSystem=strict",
+		"ProtectHome=yes",
+		"PrivateTmp=yes",
+		"PrivateDevices=yes",
+		"ProtectKernelTunables=yes",
+		"ProtectKernelModules=yes",
+		"ProtectKernelLogs=yes",
+		"ProtectControlGroups=yes",
+		"ProtectClock=yes",
+		"ProtectHostname=yes",
+		"RestrictNamespaces=yes",
+		"RestrictRealtime=yes",
+		"RestrictSUIDSGID=yes",
+		"LockPersonality=yes",
+		"MemoryDenyWriteExecute=yes",
+		"RestrictAddressFamilies=AF_UNIX AF_INET AF_INET6",
+		"SystemCallArchitectures=native",
+		"SystemCallFilter=@system-service",
+	}, "\n"))
+
+	unit.WriteString("\n\n[Install]\nWantedBy=multi-user.target\n")
+	return unit.String()
+}
+
+// SystemdSocket is the socket unit for a declared listener. Each listener gets
+// its own unit so the socket is passed with the listener name, which is how
+// the server matches it (see server/inherit.go).
+func (self Unit) SystemdSocket(listener server.ListenerConfig) string {
+	var unit strings.Builder
+	unit.WriteString(self.header("#"))
+	fmt.Fprintf(&unit, "[Unit]\nDescription=%s (%s socket)\n", self.Description, listener.Name)
+	fmt.Fprintf(&unit, "PartOf=%s.service\n", self.Name)
+	unit.WriteString("\n[Socket]\n")
+	fmt.Fprintf(&unit, "ListenStream=%s\n", listenStream(listener))
+	fmt.Fprintf(&unit, "FileDescriptorName=%s\n", listener.Name)
+	fmt.Fprintf(&unit, "Service=%s.service\n", self.Name)
+	if listener.Network == "unix" {
+		fmt.Fprintf(&unit, "SocketUser=%s\nSocketMode=0660\n", self.User)
+	}
+	unit.WriteString("\n[Install]\nWantedBy=sockets.target\n")
+	return unit.String()
+}
+
+func (self Unit) sockets() (sockets []server.ListenerConfig) {
+	for _, listener := range self.Listeners {
+		switch listener.Network {
+		case "tcp", "http", "unix", "":
+			sockets = append(sockets, listener)
+		}
+	}
+	return sockets
+}
+
+func (self Unit) socketUnit(listener server.ListenerConfig) string {
+	return fmt.Sprintf("%s-%s.socket", self.Name, listener.Name)
+}
+
+// listenStream rewrites an address in the form systemd expects: it does not
+// resolve hostnames, so localhost is spelled as the loopback address.
+func listenStream(listener server.ListenerConfig) string {
+	if listener.Network == "unix" {
+		return listener.Address
+	}
+	host, port, err := net.SplitHostPort(listener.Address)
+	if err != nil {
+		return listener.Address
+	}
+	switch host {
+	case "localhost":
+		host = "127.0.0.1"
+	case "", "0.0.0.0", "::":
+		return port
+	}
+	return net.JoinHostPort(host, port)
+}
+
+func relative(prefix, path string) string {
+	return strings.TrimPrefix(path, prefix)
+}
+
+// OpenRC /////////////////////////////////////////////////////////////////////
+func (self Unit) OpenRC() string {
+	var script strings.Builder
+	script.WriteString("#!/sbin/openrc-run\n")
+	script.WriteString(self.header("#"))
+	fmt.Fprintf(&script, "\nname=%q\ndescription=%q\n", self.Name, self.Description)
+	fmt.Fprintf(&script, "command=%q\n", self.Exec)
+	if len(self.Arguments) > 0 {
+		fmt.Fprintf(&script, "command_args=%q\n", strings.Join(self.Arguments, " "))
+	}
+	fmt.Fprintf(&script, "command_user=\"%s:%s\"\n", self.User, self.User)
+	script.WriteString("command_background=true\n")
+	// NOTE: The daemon rewrites its own PID file, including after an upgrade,
+	// so pointing OpenRC at it keeps stop and reload working across upgrades.
+	fmt.Fprintf(&script, "pidfile=\"%s/%s.pid\"\n", self.Paths.Runtime, self.Name)
+	script.WriteString("extra_started_commands=\"reload upgrade\"\n\n")
+	for _, variable := range self.environment() {
+		fmt.Fprintf(&script, "export %s=%q\n", variable[0], variable[1])
+	}
+
+	script.WriteString("\ndepend() {\n\tneed net\n\tuse logger\n}\n\n")
+	script.WriteString("start_pre() {\n")
+	fmt.Fprintf(&script, "\tcheckpath --directory --mode 0755 --owner root:root %q\n", self.Paths.Config)
+	for _, path := range []string{self.Paths.Data, self.Paths.State, self.Paths.Runtime} {
+		fmt.Fprintf(&script, "\tcheckpath --directory --mode 0700 --owner %s:%s %q\n", self.User, self.User, path)
+	}
+	script.WriteString("}\n\n")
+	script.WriteString("reload() {\n\tebegin \"Reloading ${RC_SVCNAME}\"\n\tstart-stop-daemon --signal HUP --pidfile \"${pidfile}\"\n\teend $?\n}\n\n")
+	script.WriteString("upgrade() {\n\tebegin \"Upgrading ${RC_SVCNAME}\"\n\tstart-stop-daemon --signal USR2 --pidfile \"${pidfile}\"\n\teend $?\n}\n")
+	return script.String()
+}
+
+// runit //////////////////////////////////////////////////////////////////////
+func (self Unit) Runit() string {
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	script.WriteString(self.header("#"))
+	// NOTE: runit supervises the process it started, upgrades are not
+	// supported under runit; use `sv restart` instead.
+	script.WriteString("exec 2>&1\n\n")
+	for _, variable := range self.environment() {
+		fmt.Fprintf(&script, "export %s=%q\n", variable[0], variable[1])
+	}
+	script.WriteString("\n")
+	fmt.Fprintf(&script, "install -d -m 0755 -o root -g root %q\n", self.Paths.Config)
+	fmt.Fprintf(&script, "install -d -m 0700 -o %s -g %s %q %q %q\n\n", self.User, self.User, self.Paths.Data, self.Paths.State, self.Paths.Runtime)
+	fmt.Fprintf(&script, "exec chpst -u %s:%s %s\n", self.User, self.User, self.command())
+	return script.String()
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"../server"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func testUnit(listeners ...server.ListenerConfig) Unit {
+	return Unit{
+		Name:        "appd",
+		Description: "Application daemon",
+		Version:     "1.2.3",
+		Exec:        "/usr/bin/appd",
+		Arguments:   []string{"--system"},
+		User:        "appd",
+		Paths:       SystemPaths("appd"),
+		Listeners:   listeners,
+	}
+}
+
+var (
+	unixListener = server.ListenerConfig{Name: "control", Network: "unix", Address: "/run/appd/control.sock"}
+	tcpListener  = server.ListenerConfig{Name: "web", Network: "http", Address: "localhost:8080"}
+)
+
+func TestGolden(t *testing.T) {
+	tests := []struct {
+		golden string
+		output string
+	}{
+		{"systemd.service", testUnit().Files(Systemd).String()},
+		{"systemd-sockets.service", testUnit(tcpListener, unixListener).Files(Systemd).String()},
+		{"unix.socket", testUnit().SystemdSocket(unixListener)},
+		{"tcp.socket", testUnit().SystemdSocket(tcpListener)},
+		{"openrc", testUnit(tcpListener, unixListener).Files(OpenRC).String()},
+		{"runit", testUnit(tcpListener, unixListener).Files(Runit).String()},
+	}
+	for _, test := range tests {
+		path := filepath.Join("testdata", test.golden+".golden")
+		if *update {
+			if err := os.WriteFile(path, []byte(test.output), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v (run with -update to create it)", test.golden, err)
+		}
+		if test.output != string(expected) {
+			t.Errorf("%s differs from %s:\n%s", test.golden, path, test.output)
+		}
+	}
+}
+
+func TestListenStream(t *testing.T) {
+	tests := []struct {
+		network string
+		address string
+		stream  string
+	}{
+		{"tcp", "localhost:8080", "127.0.0.1:8080"},
+		{"tcp", ":8080", "8080"},
+		{"tcp", "0.0.0.0:8080", "8080"},
+		{"tcp", "[::1]:8080", "[::1]:8080"},
+		{"unix", "/run/appd/control.sock", "/run/appd/control.sock"},
+	}
+	for _, test := range tests {
+		stream := listenStream(server.ListenerConfig{Network: test.network, Address: test.address})
+		if stream != test.stream {
+			t.Errorf("%q listens on %q, expected %q", test.address, stream, test.stream)
+		}
+	}
+}
+
+func TestParseInit(t *testing.T) {
+	for _, value := range []string{"systemd", "openrc", "runit"} {
+		if init, err := ParseInit(value); err != nil || string(init) != value {
+			t.Errorf("%q parsed as %q, %v", value, init, err)
+		}
+	}
+	if _, err := ParseInit("upstart"); err == nil {
+		t.Error("an unknown init system parsed")
+	}
+}
@@ -0,0 +1,33 @@
+package application
+
+import (
+	"./kv"
+	"./model"
+)
+
+// NOTE: Stores are compacted once most of their bytes are overwritten
+// records, and there is enough of them to be worth it.
+const compactGarbage = 1 << 20
+
+// Compact compacts the model store, the job history and the journal when
+// they need it.
+func (self *Application) Compact() error {
+	stores := []*kv.Store{self.Jobs.History.Store, self.Journal.Store}
+	if store, ok := self.Store.(*model.KV); ok {
+		stores = append(stores, store.Store)
+	}
+	for _, store := range stores {
+		stats, err := store.Stats()
+		if err != nil {
+			return err
+		}
+		if stats.Garbage < compactGarbage || stats.Garbage < stats.Live {
+			continue
+		}
+		if err := store.Compact(); err != nil {
+			return err
+		}
+		self.Log.Named("store").Info("compacted", "path", store.Path, "reclaimed", stats.Garbage)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package terminal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var ErrInterrupted = errors.New("interrupted")
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Editor is a small emacs-style line editor: arrows, home/end (ctrl-a/e),
+// ctrl-u/k/w kill, up/down walk the history, tab completes the word under the
+// cursor. When the input is not a terminal it reads plain lines, so the same
+// loop works for piped scripts.
+////////////////////////////////////////////////////////////////////////////////
+
+type Editor struct {
+	Input  io.Reader
+	Output io.Writer
+	// NOTE: Complete receives the line up to the cursor and returns candidate
+	// replacements for the last word of it.
+	Complete func(before string) []string
+	History  []string
+
+	reader *bufio.Reader
+}
+
+func NewEditor(input io.Reader, output io.Writer) *Editor {
+	return &Editor{
+		Input:  input,
+		Output: output,
+		reader: bufio.NewReader(input),
+	}
+}
+
+func (self *Editor) Remember(line string) {
+	if line = strings.TrimSpace(line); line == "" {
+		return
+	}
+	if count := len(self.History); 0 < count && self.History[count-1] == line {
+		return
+	}
+	self.History = append(self.History, line)
+}
+
+func (self *Editor) ReadLine(prompt string) (string, error) {
+	file, ok := self.Input.(*os.File)
+	if !ok || !IsTerminal(file) {
+		fmt.Fprint(self.Output, prompt)
+		line, err := self.reader.ReadString('\n')
+		if err == io.EOF && 0 < len(line) {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	state, err := MakeRaw(file)
+	if err != nil {
+		return "", err
+	}
+	defer Restore(file, state)
+	return self.edit(prompt)
+}
+
+func (self *Editor) edit(prompt string) (string, error) {
+	var line []rune
+	cursor, position := 0, len(self.History)
+	draft := ""
+	self.refresh(prompt, line, cursor)
+	for {
+		key, r, err := ReadKey(self.reader)
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case Enter:
+			fmt.Fprint(self.Output, "\r\n")
+			return string(line), nil
+		case Interrupt:
+			fmt.Fprint(self.Output, "^C\r\n")
+			return "", ErrInterrupted
+		case EOF:
+			if len(line) == 0 {
+				fmt.Fprint(self.Output, "\r\n")
+				return "", io.EOF
+			}
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case Character:
+			line = append(line[:cursor], append([]rune{r}, line[cursor:]...)...)
+			cursor++
+		case Backspace:
+			if 0 < cursor {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case Delete:
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case Left:
+			if 0 < cursor {
+				cursor--
+			}
+		case Right:
+			if cursor < len(line) {
+				cursor++
+			}
+		case Home:
+			cursor = 0
+		case End:
+			cursor = len(line)
+		case KillLine:
+			line, cursor = line[cursor:], 0
+		case KillToEnd:
+			line = line[:cursor]
+		case DeleteWord:
+			start := cursor
+			for 0 < start && line[start-1] == ' ' {
+				start--
+			}
+			for 0 < start && line[start-1] != ' ' {
+				start--
+			}
+			line, cursor = append(line[:start], line[cursor:]...), start
+		case Clear:
+			fmt.Fprint(self.Output, "\x1b[H\x1b[2J")
+		case Up, Down:
+			if position == len(self.History) {
+				draft = string(line)
+			}
+			if key == Up && 0 < position {
+				position--
+			} else if key == Down && position < len(self.History) {
+				position++
+			}
+			if position == len(self.History) {
+				line = []rune(draft)
+			} else {
+				line = []rune(self.History[position])
+			}
+			cursor = len(line)
+		case Tab:
+			line, cursor = self.complete(prompt, line, cursor)
+		}
+		self.refresh(prompt, line, cursor)
+	}
+}
+
+func (self *Editor) refresh(prompt string, line []rune, cursor int) {
+	fmt.Fprintf(self.Output, "\r%s%s\x1b[K", prompt, string(line))
+	if back := len(line) - cursor; 0 < back {
+		fmt.Fprintf(self.Output, "\x1b[%dD", back)
+	}
+}
+
+func (self *Editor) complete(prompt string, line []rune, cursor int) ([]rune, int) {
+	if self.Complete == nil {
+		return line, cursor
+	}
+	before := string(line[:cursor])
+	start := strings.LastIndexAny(before, " \t") + 1
+	word := before[start:]
+	candidates := self.Complete(before)
+	if len(candidates) == 0 {
+		return line, cursor
+	}
+	replacement := commonPrefix(candidates)
+	if len(candidates) == 1 {
+		replacement += " "
+	}
+	if replacement == word {
+		sort.Strings(candidates)
+		fmt.Fprintf(self.Output, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return line, cursor
+	}
+	completed := []rune(before[:start] + replacement)
+	return append(completed, line[cursor:]...), len(completed)
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
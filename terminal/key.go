@@ -0,0 +1,114 @@
+package terminal
+
+import (
+	"bufio"
+)
+
+type Key int
+
+const (
+	Character Key = iota
+	Enter
+	Tab
+	Backspace
+	Delete
+	Escape
+	Up
+	Down
+	Left
+	Right
+	Home
+	End
+	Interrupt
+	EOF
+	KillLine
+	KillToEnd
+	DeleteWord
+	Clear
+)
+
+const (
+	controlA = 0x01
+	controlC = 0x03
+	controlD = 0x04
+	controlE = 0x05
+	controlK = 0x0b
+	controlL = 0x0c
+	controlU = 0x15
+	controlW = 0x17
+)
+
+// ReadKey reads a single key press from a terminal in raw mode, decoding the
+// ANSI escape sequences of the arrow, home/end and delete keys.
+func ReadKey(reader *bufio.Reader) (Key, rune, error) {
+	r, _, err := reader.ReadRune()
+	if err != nil {
+		return EOF, 0, err
+	}
+	switch r {
+	case '\r', '\n':
+		return Enter, r, nil
+	case '\t':
+		return Tab, r, nil
+	case 0x7f, 0x08:
+		return Backspace, r, nil
+	case controlA:
+		return Home, r, nil
+	case controlE:
+		return End, r, nil
+	case controlC:
+		return Interrupt, r, nil
+	case controlD:
+		return EOF, r, nil
+	case controlK:
+		return KillToEnd, r, nil
+	case controlL:
+		return Clear, r, nil
+	case controlU:
+		return KillLine, r, nil
+	case controlW:
+		return DeleteWord, r, nil
+	case 0x1b:
+		return readEscape(reader)
+	}
+	return Character, r, nil
+}
+
+func readEscape(reader *bufio.Reader) (Key, rune, error) {
+	// NOTE: A lone escape has nothing buffered behind it.
+	if reader.Buffered() == 0 {
+		return Escape, 0x1b, nil
+	}
+	introducer, _ := reader.ReadByte()
+	if introducer != '[' && introducer != 'O' {
+		return Escape, rune(introducer), nil
+	}
+	var sequence []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return Escape, 0, err
+		}
+		sequence = append(sequence, b)
+		if ('A' <= b && b <= 'Z') || b == '~' || 8 < len(sequence) {
+			break
+		}
+	}
+	switch string(sequence) {
+	case "A":
+		return Up, 0, nil
+	case "B":
+		return Down, 0, nil
+	case "C":
+		return Right, 0, nil
+	case "D":
+		return Left, 0, nil
+	case "H", "1~", "7~":
+		return Home, 0, nil
+	case "F", "4~", "8~":
+		return End, 0, nil
+	case "3~":
+		return Delete, 0, nil
+	}
+	return Escape, 0, nil
+}
@@ -0,0 +1,90 @@
+package terminal
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Minimal termios handling so interactive prompts do not depend on a third
+// party terminal library. Raw mode only changes what is needed for reading
+// single key presses; output post-processing is left untouched.
+////////////////////////////////////////////////////////////////////////////////
+
+type State struct {
+	termios syscall.Termios
+}
+
+func IsTerminal(file *os.File) bool {
+	_, err := getState(file.Fd())
+	return err == nil
+}
+
+// MakeRaw puts the terminal in raw mode (no line buffering, no echo, no signal
+// generation) and returns the previous state to be passed to Restore.
+func MakeRaw(file *os.File) (*State, error) {
+	state, err := getState(file.Fd())
+	if err != nil {
+		return nil, err
+	}
+	raw := state.termios
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := setTermios(file.Fd(), &raw); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// DisableEcho keeps line buffering but stops the terminal from echoing input,
+// which is what password entry needs.
+func DisableEcho(file *os.File) (*State, error) {
+	state, err := getState(file.Fd())
+	if err != nil {
+		return nil, err
+	}
+	silent := state.termios
+	silent.Lflag &^= syscall.ECHO
+	silent.Lflag |= syscall.ICANON | syscall.ISIG
+	silent.Iflag |= syscall.ICRNL
+	if err := setTermios(file.Fd(), &silent); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func Restore(file *os.File, state *State) error {
+	if state == nil {
+		return nil
+	}
+	return setTermios(file.Fd(), &state.termios)
+}
+
+func Size(file *os.File) (width, height int, err error) {
+	var size struct{ rows, columns, x, y uint16 }
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&size))); errno != 0 {
+		return 0, 0, errno
+	}
+	return int(size.columns), int(size.rows), nil
+}
+
+func getState(fd uintptr) (*State, error) {
+	state := &State{}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TCGETS), uintptr(unsafe.Pointer(&state.termios))); errno != 0 {
+		return nil, errno
+	}
+	return state, nil
+}
+
+func setTermios(fd uintptr, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TCSETS), uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"../fault"
+)
+
+// Rule checks a field value against the rule parameter, e.g. the `3` of
+// `min=3`; parent is the struct holding the field, for cross-field rules.
+type Rule func(parent, value reflect.Value, param string) error
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]Rule{
+		"required":         required,
+		"min":              minimum,
+		"max":              maximum,
+		"len":              length,
+		"enum":             enum,
+		"pattern":          pattern,
+		"eqfield":          compareField("eqfield"),
+		"nefield":          compareField("nefield"),
+		"gtfield":          compareField("gtfield"),
+		"gtefield":         compareField("gtefield"),
+		"ltfield":          compareField("ltfield"),
+		"ltefield":         compareField("ltefield"),
+		"required_with":    requiredWith(true),
+		"required_without": requiredWith(false),
+	}
+)
+
+// Register adds a named rule for the validate tags, replacing any rule of the
+// same name.
+func Register(name string, rule Rule) {
+	rulesMu.Lock()
+	rules[name] = rule
+	rulesMu.Unlock()
+	// NOTE: A type compiled before the rule existed is compiled again.
+	typesMu.Lock()
+	types = make(map[reflect.Type]compiled)
+	typesMu.Unlock()
+}
+
+type tagRule struct {
+	name  string
+	param string
+}
+
+func parseTag(tag string) (parsed []tagRule) {
+	for tag != "" {
+		var part string
+		// NOTE: A pattern may contain commas, it takes the rest of the tag.
+		if strings.HasPrefix(tag, "pattern=") {
+			part, tag = tag, ""
+		} else if index := strings.IndexByte(tag, ','); 0 <= index {
+			part, tag = tag[:index], tag[index+1:]
+		} else {
+			part, tag = tag, ""
+		}
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name != "" {
+			parsed = append(parsed, tagRule{name: name, param: param})
+		}
+	}
+	return parsed
+}
+
+// compiled are the rules of every field of a struct type, by field index, or
+// why its tags cannot be checked.
+type compiled struct {
+	tags [][]tagRule
+	err  error
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = make(map[reflect.Type]compiled)
+)
+
+// compile parses and checks the tags of a struct type the first time it is
+// validated, so a mistake in a tag is found before any value is checked.
+func compile(kind reflect.Type) ([][]tagRule, error) {
+	typesMu.RLock()
+	result, ok := types[kind]
+	typesMu.RUnlock()
+	if ok {
+		return result.tags, result.err
+	}
+	result.tags = make([][]tagRule, kind.NumField())
+	for index := 0; index < kind.NumField() && result.err == nil; index++ {
+		field := kind.Field(index)
+		result.tags[index] = parseTag(field.Tag.Get("validate"))
+		for _, rule := range result.tags[index] {
+			if err := rule.check(kind); err != nil {
+				result.err = fault.InternalError("internal.validate", "invalid validate tag on %s.%s: %v", kind, field.Name, err)
+				break
+			}
+		}
+	}
+	typesMu.Lock()
+	types[kind] = result
+	typesMu.Unlock()
+	return result.tags, result.err
+}
+
+// check finds what would fail the rule whatever the value: an unknown rule,
+// a limit that is not a number or a duration, a pattern that does not compile
+// or a field that is not there.
+func (self tagRule) check(parent reflect.Type) error {
+	rulesMu.RLock()
+	_, ok := rules[self.name]
+	rulesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown rule %q", self.name)
+	}
+	switch self.name {
+	case "min", "max", "len":
+		if _, err := parseLimit(self.param); err != nil {
+			return err
+		}
+	case "pattern":
+		if _, err := compilePattern(self.param); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", self.param, err)
+		}
+	case "eqfield", "nefield", "gtfield", "gtefield", "ltfield", "ltefield", "required_with", "required_without":
+		if _, ok := fieldIndex(parent, self.param); !ok {
+			return fmt.Errorf("%s refers to unknown field %q", self.name, self.param)
+		}
+	}
+	return nil
+}
+
+func (self tagRule) apply(parent, value reflect.Value) error {
+	rulesMu.RLock()
+	rule, ok := rules[self.name]
+	rulesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("has unknown rule %q", self.name)
+	}
+	// NOTE: Only `required` rules apply to an absent value, a nil pointer or
+	// interface; the others check what is there, zero values included.
+	if !strings.HasPrefix(self.name, "required") && absent(value) {
+		return nil
+	}
+	return rule(parent, value, self.param)
+}
+
+func absent(value reflect.Value) bool {
+	if !value.IsValid() {
+		return true
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+	return false
+}
+
+func isZero(value reflect.Value) bool {
+	if !value.IsValid() {
+		return true
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	}
+	return value.IsZero()
+}
+
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	return value
+}
+
+func required(parent, value reflect.Value, param string) error {
+	if isZero(value) {
+		return fmt.Errorf("is required")
+	}
+	return nil
+}
+
+// measure returns what min, max and len compare: a length or a number.
+func measure(value reflect.Value) (float64, string, bool) {
+	value = indirect(value)
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), " characters", true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(value.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), "", true
+	}
+	return 0, "", false
+}
+
+func bound(value reflect.Value, param string) (float64, float64, string, error) {
+	measured, unit, ok := measure(value)
+	if !ok {
+		return 0, 0, "", fmt.Errorf("cannot be measured")
+	}
+	limit, err := parseLimit(param)
+	return measured, limit, unit, err
+}
+
+func parseLimit(param string) (float64, error) {
+	if limit, err := strconv.ParseFloat(param, 64); err == nil {
+		return limit, nil
+	}
+	// NOTE: Durations take their limit as a duration, e.g. `min=1s`.
+	duration, err := time.ParseDuration(param)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit %q", param)
+	}
+	return float64(duration), nil
+}
+
+func minimum(parent, value reflect.Value, param string) error {
+	measured, limit, unit, err := bound(value, param)
+	if err != nil {
+		return err
+	}
+	if measured < limit {
+		return fmt.Errorf("must be at least %s%s", param, unit)
+	}
+	return nil
+}
+
+func maximum(parent, value reflect.Value, param string) error {
+	measured, limit, unit, err := bound(value, param)
+	if err != nil {
+		return err
+	}
+	if limit < measured {
+		return fmt.Errorf("must be at most %s%s", param, unit)
+	}
+	return nil
+}
+
+func length(parent, value reflect.Value, param string) error {
+	measured, limit, unit, err := bound(value, param)
+	if err != nil {
+		return err
+	}
+	if measured != limit {
+		return fmt.Errorf("must be exactly %s%s", param, unit)
+	}
+	return nil
+}
+
+func enum(parent, value reflect.Value, param string) error {
+	text := fmt.Sprint(indirect(value).Interface())
+	options := strings.Split(param, "|")
+	for _, option := range options {
+		if text == option {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(options, ", "))
+}
+
+var (
+	patternsMu sync.Mutex
+	patterns   = make(map[string]*regexp.Regexp)
+)
+
+func compilePattern(param string) (*regexp.Regexp, error) {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	if compiled, ok := patterns[param]; ok {
+		return compiled, nil
+	}
+	compiled, err := regexp.Compile(param)
+	if err == nil {
+		patterns[param] = compiled
+	}
+	return compiled, err
+}
+
+func pattern(parent, value reflect.Value, param string) error {
+	compiled, err := compilePattern(param)
+	if err != nil {
+		return fmt.Errorf("has an invalid pattern %s", param)
+	}
+	if !compiled.MatchString(fmt.Sprint(indirect(value).Interface())) {
+		return fmt.Errorf("must match %s", param)
+	}
+	return nil
+}
+
+func compareField(rule string) Rule {
+	return func(parent, value reflect.Value, param string) error {
+		other, name, ok := byName(parent, param)
+		if !ok {
+			return fmt.Errorf("refers to unknown field %s", param)
+		}
+		order, comparable := compare(rule, indirect(value), indirect(other))
+		if !comparable {
+			return fmt.Errorf("cannot be compared with %s", name)
+		}
+		switch {
+		case rule == "eqfield" && order != 0:
+			return fmt.Errorf("must equal %s", name)
+		case rule == "nefield" && order == 0:
+			return fmt.Errorf("must differ from %s", name)
+		case rule == "gtfield" && order <= 0:
+			return fmt.Errorf("must be after %s", name)
+		case rule == "gtefield" && order < 0:
+			return fmt.Errorf("must not be before %s", name)
+		case rule == "ltfield" && order >= 0:
+			return fmt.Errorf("must be before %s", name)
+		case rule == "ltefield" && order > 0:
+			return fmt.Errorf("must not be after %s", name)
+		}
+		return nil
+	}
+}
+
+// compare orders two values of the same type for a rule: -1, 0 or 1.
+func compare(rule string, left, right reflect.Value) (int, bool) {
+	if left.Type() != right.Type() {
+		return 0, false
+	}
+	if left.Type() == timeType {
+		return left.Interface().(time.Time).Compare(right.Interface().(time.Time)), true
+	}
+	if left.Kind() == reflect.String {
+		return strings.Compare(left.String(), right.String()), true
+	}
+	switch left.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array, reflect.Struct:
+		return 0, false
+	}
+	leftNumber, _, leftOK := measure(left)
+	rightNumber, _, rightOK := measure(right)
+	if !leftOK || !rightOK {
+		// NOTE: Other kinds, such as booleans, are only equal or not.
+		if left.Interface() == right.Interface() {
+			return 0, true
+		}
+		return 1, rule == "eqfield" || rule == "nefield"
+	}
+	switch {
+	case leftNumber < rightNumber:
+		return -1, true
+	case leftNumber > rightNumber:
+		return 1, true
+	}
+	return 0, true
+}
+
+func requiredWith(with bool) Rule {
+	return func(parent, value reflect.Value, param string) error {
+		other, name, ok := byName(parent, param)
+		if !ok {
+			return fmt.Errorf("refers to unknown field %s", param)
+		}
+		if isZero(other) == with || !isZero(value) {
+			return nil
+		}
+		if with {
+			return fmt.Errorf("is required with %s", name)
+		}
+		return fmt.Errorf("is required without %s", name)
+	}
+}
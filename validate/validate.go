@@ -0,0 +1,224 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// NOTE
+// Validation rules are declared in `validate` struct tags, separated by
+// commas, and checked by Struct; the config and the models use the same rules:
+//
+//   Title  string   `json:"title" validate:"required,max=200"`
+//   Status string   `json:"status" validate:"enum=open|closed"`
+//   Slug   string   `json:"slug" validate:"pattern=^[a-z0-9-]+$"`
+//   Until  time.Time `json:"until" validate:"gtfield=From"`
+//
+//   required                   not the zero value
+//   min=n, max=n, len=n        length of strings, slices and maps; numbers
+//                              are compared by value
+//   enum=a|b|c                 one of the values
+//   pattern=regexp             matches; it must be the last rule of the tag
+//   eqfield=F, nefield=F       equal to, different from another field
+//   gtfield=F, gtefield=F,     greater, less than another field (numbers,
+//   ltfield=F, ltefield=F      strings and times)
+//   required_with=F            required when the other field is set
+//   required_without=F         required when the other field is not set
+//
+// Rules other than the required ones skip nil pointers and interfaces but
+// check zero values: `min=1` refuses 0 and "", an optional field is a pointer.
+// The tags of a type are checked once, the first time it is validated.
+//
+// Register adds named rules. A type implementing Validator is checked by its
+// Validate method after its tags, for rules no tag can express.
+//
+// Fields are named as in their json tag, nested with dots and indexes, e.g.
+// `server.listeners[0].address`, so errors point at what the user wrote.
+////////////////////////////////////////////////////////////////////////////////
+
+// Validator is implemented by types with rules of their own. Validate may
+// return Errors, with fields relative to the type, or any error.
+type Validator interface {
+	Validate() error
+}
+
+type FieldError struct {
+	Field   string `json:"field" yaml:"field"`
+	Rule    string `json:"rule" yaml:"rule"`
+	Param   string `json:"param,omitempty" yaml:"param,omitempty"`
+	Message string `json:"message" yaml:"message"`
+}
+
+func (self FieldError) Error() string {
+	if self.Field == "" {
+		return self.Message
+	}
+	return self.Field + " " + self.Message
+}
+
+// Errors are the failed rules, in field order.
+type Errors []FieldError
+
+func (self Errors) Error() string {
+	messages := make([]string, 0, len(self))
+	for _, err := range self {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Fields returns the field errors in an error chain, if any.
+func Fields(err error) Errors {
+	var fields Errors
+	if errors.As(err, &fields) {
+		return fields
+	}
+	return nil
+}
+
+// Struct checks the rules of a struct, or a pointer to one. The error is nil
+// or Errors; tags that cannot be checked, such as an unknown rule, fail with
+// an internal error instead.
+func Struct(value interface{}) error {
+	var errs Errors
+	if err := check(reflect.ValueOf(value), "", &errs); err != nil {
+		return err
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func check(value reflect.Value, path string, errs *Errors) error {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.Type() == timeType {
+			return nil
+		}
+		return checkStruct(value, path, errs)
+	case reflect.Slice, reflect.Array:
+		for index := 0; index < value.Len(); index++ {
+			if err := check(value.Index(index), fmt.Sprintf("%s[%d]", path, index), errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			if err := check(value.MapIndex(key), join(path, fmt.Sprint(key.Interface())), errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkStruct(value reflect.Value, path string, errs *Errors) error {
+	kind := value.Type()
+	tags, err := compile(kind)
+	if err != nil {
+		return err
+	}
+	for index := 0; index < kind.NumField(); index++ {
+		field := kind.Field(index)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+		fieldPath := join(path, name)
+		if field.Anonymous {
+			// NOTE: Embedded structs, such as model.Model, are flattened the
+			// same way encoding/json flattens them.
+			fieldPath = path
+		}
+		for _, rule := range tags[index] {
+			if err := rule.apply(value, value.Field(index)); err != nil {
+				*errs = append(*errs, FieldError{Field: fieldPath, Rule: rule.name, Param: rule.param, Message: err.Error()})
+			}
+		}
+		if err := check(value.Field(index), fieldPath, errs); err != nil {
+			return err
+		}
+	}
+	if value.CanAddr() {
+		value = value.Addr()
+	}
+	// NOTE: The fields of an unexported embedded struct are checked, but its
+	// methods cannot be called through reflection.
+	if !value.CanInterface() {
+		return nil
+	}
+	if validator, ok := value.Interface().(Validator); ok {
+		*errs = append(*errs, prefix(path, validator.Validate())...)
+	}
+	return nil
+}
+
+func prefix(path string, err error) Errors {
+	if err == nil {
+		return nil
+	}
+	fields := Fields(err)
+	if fields == nil {
+		return Errors{{Field: path, Rule: "custom", Message: err.Error()}}
+	}
+	prefixed := make(Errors, 0, len(fields))
+	for _, field := range fields {
+		field.Field = join(path, field.Field)
+		prefixed = append(prefixed, field)
+	}
+	return prefixed
+}
+
+func join(path, name string) string {
+	switch {
+	case path == "":
+		return name
+	case name == "":
+		return path
+	}
+	return path + "." + name
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "yaml"} {
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// byName finds a sibling field by its Go or json name, for cross-field rules.
+func byName(parent reflect.Value, name string) (reflect.Value, string, bool) {
+	index, ok := fieldIndex(parent.Type(), name)
+	if !ok {
+		return reflect.Value{}, "", false
+	}
+	return parent.Field(index), fieldName(parent.Type().Field(index)), true
+}
+
+func fieldIndex(kind reflect.Type, name string) (int, bool) {
+	for index := 0; index < kind.NumField(); index++ {
+		field := kind.Field(index)
+		if field.Name == name || fieldName(field) == name {
+			return index, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,165 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"../fault"
+)
+
+type listener struct {
+	Name    string `json:"name" validate:"required,pattern=^[a-z]+$"`
+	Network string `json:"network" validate:"required,enum=tcp|unix"`
+}
+
+type settings struct {
+	Title     string        `json:"title" validate:"min=1,max=5"`
+	Count     int           `json:"count" validate:"min=1"`
+	Limit     *int          `json:"limit" validate:"min=1"`
+	Timeout   time.Duration `json:"timeout" validate:"min=1s"`
+	Tags      []string      `json:"tags" validate:"max=2"`
+	Code      string        `json:"code" validate:"len=3"`
+	From      time.Time     `json:"from"`
+	Until     time.Time     `json:"until" validate:"gtefield=From"`
+	Cert      string        `json:"cert" validate:"required_with=Key"`
+	Key       string        `json:"key"`
+	Listeners []listener    `json:"listeners"`
+	Nested    *listener     `json:"nested"`
+}
+
+func valid() settings {
+	return settings{Title: "a", Count: 1, Timeout: time.Second, Code: "abc"}
+}
+
+func TestStruct(t *testing.T) {
+	zero, two := 0, 2
+	now := time.Now()
+	tests := []struct {
+		name   string
+		change func(*settings)
+		fields []string
+	}{
+		{"valid", func(*settings) {}, nil},
+		{"zero values are checked", func(value *settings) { *value = settings{} }, []string{"title", "count", "timeout", "code"}},
+		{"nil pointers are not", func(value *settings) { value.Limit = nil }, nil},
+		{"pointers are checked", func(value *settings) { value.Limit = &zero }, []string{"limit"}},
+		{"set pointers pass", func(value *settings) { value.Limit = &two }, nil},
+		{"too long", func(value *settings) { value.Title = "abcdef" }, []string{"title"}},
+		{"characters not bytes", func(value *settings) { value.Title = "ééééé" }, nil},
+		{"too many", func(value *settings) { value.Tags = []string{"a", "b", "c"} }, []string{"tags"}},
+		{"duration", func(value *settings) { value.Timeout = time.Millisecond }, []string{"timeout"}},
+		{"before", func(value *settings) { value.From, value.Until = now, now.Add(-time.Hour) }, []string{"until"}},
+		{"required with", func(value *settings) { value.Key = "secret" }, []string{"cert"}},
+		{
+			"nested",
+			func(value *settings) {
+				value.Listeners = []listener{{Name: "web", Network: "tcp"}, {Name: "Web!", Network: "udp"}}
+			},
+			[]string{"listeners[1].name", "listeners[1].network"},
+		},
+		{"nested pointer", func(value *settings) { value.Nested = &listener{Name: "Web", Network: "udp"} }, []string{"nested.name", "nested.network"}},
+	}
+	for _, test := range tests {
+		value := valid()
+		test.change(&value)
+		err := Struct(&value)
+		var fields []string
+		for _, field := range Fields(err) {
+			fields = append(fields, field.Field)
+		}
+		if !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("%s: failed %v, expected %v: %v", test.name, fields, test.fields, err)
+		}
+		if err != nil && Fields(err) == nil {
+			t.Errorf("%s: not field errors: %v", test.name, err)
+		}
+	}
+}
+
+type broken struct {
+	Unknown string `validate:"unknownrule"`
+}
+
+type badLimit struct {
+	Count int `validate:"min=lots"`
+}
+
+type badField struct {
+	Until time.Time `validate:"gtfield=Since"`
+}
+
+type badPattern struct {
+	Slug string `validate:"pattern=[a-"`
+}
+
+func TestInvalidTags(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		message string
+	}{
+		{broken{}, `unknown rule "unknownrule"`},
+		{&badLimit{Count: 1}, `invalid limit "lots"`},
+		{badField{}, `unknown field "Since"`},
+		{badPattern{Slug: "a"}, `invalid pattern "[a-"`},
+		{[]badLimit{{}}, `invalid limit "lots"`},
+	}
+	for _, test := range tests {
+		for attempt := 0; attempt < 2; attempt++ {
+			err := Struct(test.value)
+			if fault.As(err).Code != "internal.validate" || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("%T: returned %v, expected an internal error about %s", test.value, err, test.message)
+			}
+		}
+	}
+}
+
+type custom struct {
+	Even int `json:"even" validate:"even"`
+}
+
+func TestRegister(t *testing.T) {
+	if err := Struct(custom{}); fault.As(err).Code != "internal.validate" {
+		t.Errorf("an unregistered rule returned %v", err)
+	}
+	Register("even", func(parent, value reflect.Value, param string) error {
+		if value.Int()%2 != 0 {
+			return errors.New("must be even")
+		}
+		return nil
+	})
+	if err := Struct(custom{Even: 2}); err != nil {
+		t.Errorf("a registered rule failed: %v", err)
+	}
+	if fields := Fields(Struct(custom{Even: 3})); len(fields) != 1 || fields[0].Error() != "even must be even" {
+		t.Errorf("failed with %v", fields)
+	}
+}
+
+type checked struct {
+	Low  int `json:"low"`
+	High int `json:"high"`
+}
+
+func (self checked) Validate() error {
+	if self.High < self.Low {
+		return Errors{{Field: "high", Rule: "custom", Message: "must not be below low"}}
+	}
+	return nil
+}
+
+type paging struct {
+	Limit int `json:"limit" validate:"max=100"`
+}
+
+func TestValidator(t *testing.T) {
+	value := struct {
+		Range checked `json:"range"`
+		paging
+	}{checked{Low: 2, High: 1}, paging{Limit: 200}}
+	if fields := Fields(Struct(&value)); len(fields) != 2 || fields[0].Field != "range.high" || fields[1].Field != "limit" {
+		t.Errorf("failed with %v", fields)
+	}
+}
@@ -0,0 +1,56 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"./fault"
+	"./model"
+	"./rpc"
+)
+
+type WatchParams struct {
+	Collection string `json:"collection"`
+	// NOTE: Cursor resumes after a change already seen; without it the
+	// stream starts with the next change.
+	Cursor *uint64 `json:"cursor,omitempty"`
+}
+
+// WatchResult is the cursor of the last change sent, to resume from.
+type WatchResult struct {
+	Cursor uint64 `json:"cursor" yaml:"cursor"`
+}
+
+func (self WatchResult) String() string { return fmt.Sprintf("resume with --cursor %d", self.Cursor) }
+
+// streamWatch streams the changes of a collection to a control socket client
+// until it disconnects or the daemon stops.
+func (self *Application) streamWatch(ctx context.Context, params WatchParams) (WatchResult, error) {
+	if !rpc.Streaming(ctx) {
+		return WatchResult{}, fault.UsageError("usage.streaming", "watch is a stream, it must be called over a connection with an id")
+	}
+	if !model.ValidID(params.Collection) {
+		return WatchResult{}, fault.UsageError("usage.invalid_collection", "invalid collection %q", params.Collection)
+	}
+	var result WatchResult
+	if params.Cursor != nil {
+		result.Cursor = *params.Cursor
+	} else {
+		latest, err := model.Latest(self.Store, params.Collection)
+		if err != nil {
+			return result, fault.Wrap(err, fault.IO, "io.changes", "failed to read the changes of %s", params.Collection)
+		}
+		result.Cursor = latest
+	}
+	err := model.Watch(ctx, self.Store, params.Collection, result.Cursor, func(change model.Change) error {
+		if err := rpc.Send(ctx, change); err != nil {
+			return err
+		}
+		result.Cursor = change.Cursor
+		return nil
+	})
+	if ctx.Err() != nil {
+		return result, nil
+	}
+	return result, err
+}